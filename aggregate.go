@@ -0,0 +1,122 @@
+package goqa
+
+import (
+	"math"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Aggregate coverage of the packages of a repository and ref within a directory subtree e.g. a module
+//
+// Packages are weighted by their number of statements; those without statement counts (e.g. from go test -json
+// summaries) are only taken into account when no package has any, as a plain mean.
+type Aggregate struct {
+	Repository string `json:"repository"`
+	Ref        string `json:"ref"`
+
+	// Path of the subtree; empty for the whole repository
+	Path string `json:"path"`
+
+	// Packages within the subtree
+	Packages int `json:"packages"`
+
+	// Weighted is the number of packages whose statements are known
+	Weighted   int `json:"weighted"`
+	Statements int `json:"statements"`
+	Covered    int `json:"covered"`
+
+	// Percentage truncated to an integer, like Coverage.Percentage
+	Percentage int `json:"percentage"`
+
+	// Precise percentage, to the first decimal
+	Precise float64 `json:"precise"`
+}
+
+// within tells if pkg is dir or one of its subdirectories; anything is within an empty dir
+func within(pkg, dir string) bool {
+	return dir == "" || pkg == dir || strings.HasPrefix(pkg, dir+"/")
+}
+
+// Aggregated coverage of a repository and ref within a subtree; coverage of other repositories and refs is ignored
+func Aggregated(repository, ref, dir string, covs []Coverage) Aggregate {
+	var (
+		a     = Aggregate{Repository: repository, Ref: ref, Path: dir}
+		total float64
+	)
+
+	for _, c := range covs {
+		if c.Repository != repository || c.Ref != ref || !within(c.Pkg, dir) {
+			continue
+		}
+
+		a.Packages++
+		total += c.Value()
+
+		if c.Statements != 0 {
+			a.Weighted++
+			a.Statements += c.Statements
+			a.Covered += c.Covered
+		}
+	}
+
+	switch {
+	case a.Statements != 0:
+		a.Precise = Percentage(a.Covered, a.Statements)
+	case a.Packages != 0:
+		a.Precise = math.Round(total*10/float64(a.Packages)) / 10
+	}
+
+	a.Percentage = int(a.Precise)
+
+	return a
+}
+
+// Subtrees of a repository and ref, sorted by path; every directory from the root common to all packages
+// down to the packages themselves has an aggregate, the whole repository comes first with an empty path
+func Subtrees(repository, ref string, covs []Coverage) []Aggregate {
+	var (
+		dirs = make(map[string]bool)
+		pkgs []string
+	)
+
+	for _, c := range covs {
+		if c.Repository == repository && c.Ref == ref {
+			pkgs = append(pkgs, c.Pkg)
+		}
+	}
+
+	if len(pkgs) == 0 {
+		return []Aggregate{}
+	}
+
+	var root = pkgs[0]
+	for _, p := range pkgs {
+		for !within(p, root) {
+			root = path.Dir(root)
+			if root == "." || root == "/" {
+				root = ""
+			}
+		}
+	}
+
+	for _, p := range pkgs {
+		for d := p; within(d, root) && d != "." && d != "/"; d = path.Dir(d) {
+			dirs[d] = true
+			if d == root {
+				break
+			}
+		}
+	}
+
+	var aggregates = []Aggregate{Aggregated(repository, ref, "", covs)}
+	for d := range dirs {
+		aggregates = append(aggregates, Aggregated(repository, ref, d, covs))
+	}
+
+	sort.Slice(aggregates, func(i, j int) bool {
+		return aggregates[i].Path < aggregates[j].Path
+	})
+
+	return aggregates
+}
@@ -0,0 +1,120 @@
+package goqa
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAggregated(t *testing.T) {
+	var covs = []Coverage{
+		{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo", Percentage: 50, Statements: 100, Covered: 50},
+		{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo/bar", Percentage: 100, Statements: 10, Covered: 10},
+		{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foobar", Percentage: 0, Statements: 90},
+		{Repository: "acme/foo", Ref: "develop", Pkg: "github.com/acme/foo", Percentage: 10, Statements: 10, Covered: 1},
+		{Repository: "acme/baz", Ref: "master", Pkg: "github.com/acme/baz", Percentage: 40},
+		{Repository: "acme/baz", Ref: "master", Pkg: "github.com/acme/baz/qux", Percentage: 81},
+		{Repository: "acme/qux", Ref: "develop", Pkg: "github.com/acme/qux", Percentage: 83, Precise: 83.3},
+		{Repository: "acme/qux", Ref: "develop", Pkg: "github.com/acme/qux/quux", Percentage: 83, Precise: 83.1},
+	}
+
+	tests := []struct {
+		name       string
+		repository string
+		ref        string
+		dir        string
+		want       Aggregate
+	}{
+		{
+			name:       "repository, weighted",
+			repository: "acme/foo",
+			ref:        "master",
+			want:       Aggregate{Repository: "acme/foo", Ref: "master", Packages: 3, Weighted: 3, Statements: 200, Covered: 60, Percentage: 30, Precise: 30},
+		},
+		{
+			name:       "subtree excludes siblings sharing a prefix",
+			repository: "acme/foo",
+			ref:        "master",
+			dir:        "github.com/acme/foo",
+			want:       Aggregate{Repository: "acme/foo", Ref: "master", Path: "github.com/acme/foo", Packages: 2, Weighted: 2, Statements: 110, Covered: 60, Percentage: 54, Precise: 54.5},
+		},
+		{
+			name:       "no statements, mean",
+			repository: "acme/baz",
+			ref:        "master",
+			want:       Aggregate{Repository: "acme/baz", Ref: "master", Packages: 2, Percentage: 60, Precise: 60.5},
+		},
+		{
+			name:       "no statements, mean of precise",
+			repository: "acme/qux",
+			ref:        "develop",
+			want:       Aggregate{Repository: "acme/qux", Ref: "develop", Packages: 2, Percentage: 83, Precise: 83.2},
+		},
+		{
+			name:       "nothing",
+			repository: "acme/qux",
+			ref:        "master",
+			want:       Aggregate{Repository: "acme/qux", Ref: "master"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Aggregated(tt.repository, tt.ref, tt.dir, covs); got != tt.want {
+				t.Errorf("Aggregated()\nwant = %+v\ngot  = %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSubtrees(t *testing.T) {
+	var covs = []Coverage{
+		{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo", Percentage: 50, Statements: 10, Covered: 5},
+		{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo/a/b", Percentage: 100, Statements: 10, Covered: 10},
+		{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo/c", Percentage: 0, Statements: 20},
+		{Repository: "acme/foo", Ref: "develop", Pkg: "github.com/acme/bar", Percentage: 0, Statements: 20},
+	}
+
+	tests := []struct {
+		name  string
+		ref   string
+		paths []string
+	}{
+		{
+			name:  "common root",
+			ref:   "master",
+			paths: []string{"", "github.com/acme/foo", "github.com/acme/foo/a", "github.com/acme/foo/a/b", "github.com/acme/foo/c"},
+		},
+		{
+			name:  "single package",
+			ref:   "develop",
+			paths: []string{"", "github.com/acme/bar"},
+		},
+		{
+			name:  "nothing",
+			ref:   "none",
+			paths: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got   = Subtrees("acme/foo", tt.ref, covs)
+				paths = []string{}
+			)
+
+			for _, a := range got {
+				paths = append(paths, a.Path)
+			}
+
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("Subtrees() paths\nwant = %v\ngot  = %v", tt.paths, paths)
+			}
+		})
+	}
+
+	var got = Subtrees("acme/foo", "master", covs)
+	if got[2].Path != "github.com/acme/foo/a" || got[2].Percentage != 100 || got[1].Percentage != 37 {
+		t.Errorf("Subtrees() aggregates = %+v", got)
+	}
+}
@@ -0,0 +1,147 @@
+package badge
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Label of coverage badges
+const Label = "coverage"
+
+// colors known by name, as on shields.io
+var colors = map[string]string{
+	"brightgreen": "#4c1",
+	"green":       "#97ca00",
+	"yellowgreen": "#a4a61d",
+	"yellow":      "#dfb317",
+	"orange":      "#fe7d37",
+	"red":         "#e05d44",
+	"lightgrey":   "#9f9f9f",
+}
+
+// Unknown is the color of badges without coverage
+const Unknown = "lightgrey"
+
+// Band of coverage from Min percentage, inclusive, up to the next band
+type Band struct {
+	Min   int    `json:"min"`
+	Color string `json:"color"`
+}
+
+// DefaultBands are used when none are configured
+var DefaultBands = []Band{
+	{Min: 0, Color: "red"},
+	{Min: 50, Color: "orange"},
+	{Min: 70, Color: "yellow"},
+	{Min: 80, Color: "yellowgreen"},
+	{Min: 90, Color: "green"},
+	{Min: 95, Color: "brightgreen"},
+}
+
+// Color of a percentage given bands in any order; either a name known by shields.io or a hex code
+func Color(bands []Band, percentage float64) string {
+	if len(bands) == 0 {
+		bands = DefaultBands
+	}
+
+	var sorted = make([]Band, len(bands))
+	copy(sorted, bands)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Min < sorted[j].Min
+	})
+
+	var color = Unknown
+	for _, b := range sorted {
+		if percentage >= float64(b.Min) {
+			color = b.Color
+		}
+	}
+
+	return color
+}
+
+// hex code of a color; names are looked up, anything else is taken as is
+func hex(color string) string {
+	if c, ok := colors[color]; ok {
+		return c
+	}
+
+	return color
+}
+
+// width of a text in Verdana 11px, close enough to what browsers render
+func width(text string) int {
+	var w float64
+	for _, r := range text {
+		switch {
+		case strings.ContainsRune("il.,:;|!' ", r):
+			w += 3.5
+		case strings.ContainsRune("mwMW%", r):
+			w += 10
+		case r >= 'A' && r <= 'Z':
+			w += 7.5
+		default:
+			w += 6.5
+		}
+	}
+
+	return int(w + 0.5)
+}
+
+// SVG of a flat badge, like those of shields.io
+func SVG(label, message, color string) []byte {
+	var (
+		lw = width(label) + 10
+		mw = width(message) + 10
+		tw = lw + mw
+	)
+
+	label, message = html.EscapeString(label), html.EscapeString(message)
+
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">`+
+		`<title>%[4]s: %[5]s</title>`+
+		`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`+
+		`<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[4]s</text><text x="%[7]d" y="14">%[4]s</text>`+
+		`<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[5]s</text><text x="%[8]d" y="14">%[5]s</text>`+
+		`</g></svg>`,
+		tw, lw, mw, label, message, html.EscapeString(hex(color)), lw/2, lw+mw/2,
+	))
+}
+
+// Shields is the json of a shields.io endpoint badge; https://shields.io/endpoint
+type Shields struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+// message of a coverage badge; nil percentage means unknown
+func message(percentage *float64) string {
+	if percentage == nil {
+		return "unknown"
+	}
+
+	return strconv.FormatFloat(*percentage, 'f', -1, 64) + "%"
+}
+
+// New shields.io endpoint badge of a coverage; nil percentage means unknown
+func New(bands []Band, percentage *float64) Shields {
+	var s = Shields{SchemaVersion: 1, Label: Label, Message: message(percentage), Color: Unknown}
+	if percentage != nil {
+		s.Color = Color(bands, *percentage)
+	}
+
+	return s
+}
+
+// SVG of the badge
+func (s Shields) SVG() []byte {
+	return SVG(s.Label, s.Message, s.Color)
+}
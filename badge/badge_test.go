@@ -0,0 +1,121 @@
+package badge
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColor(t *testing.T) {
+	var bands = []Band{
+		{Min: 80, Color: "green"},
+		{Min: 0, Color: "red"},
+		{Min: 50, Color: "#ff0"},
+	}
+
+	tests := []struct {
+		name       string
+		bands      []Band
+		percentage float64
+		want       string
+	}{
+		{name: "lowest band", bands: bands, percentage: 0, want: "red"},
+		{name: "band minimum is inclusive", bands: bands, percentage: 50, want: "#ff0"},
+		{name: "highest band", bands: bands, percentage: 100, want: "green"},
+		{name: "below all bands", bands: []Band{{Min: 10, Color: "green"}}, percentage: 5, want: Unknown},
+		{name: "default bands", bands: nil, percentage: 85, want: "yellowgreen"},
+		{name: "just below a band", bands: bands, percentage: 79.9, want: "#ff0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Color(tt.bands, tt.percentage); got != tt.want {
+				t.Errorf("Color() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	var (
+		p = 42.0
+		q = 83.3
+	)
+
+	tests := []struct {
+		name       string
+		percentage *float64
+		want       Shields
+	}{
+		{
+			name:       "unknown",
+			percentage: nil,
+			want:       Shields{SchemaVersion: 1, Label: "coverage", Message: "unknown", Color: "lightgrey"},
+		},
+		{
+			name:       "known",
+			percentage: &p,
+			want:       Shields{SchemaVersion: 1, Label: "coverage", Message: "42%", Color: "red"},
+		},
+		{
+			name:       "decimal",
+			percentage: &q,
+			want:       Shields{SchemaVersion: 1, Label: "coverage", Message: "83.3%", Color: "yellowgreen"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(nil, tt.percentage); got != tt.want {
+				t.Errorf("New()\nwant = %v\ngot  = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSVG(t *testing.T) {
+	tests := []struct {
+		name     string
+		label    string
+		message  string
+		color    string
+		contains []string
+	}{
+		{
+			name:     "named color",
+			label:    "coverage",
+			message:  "42%",
+			color:    "red",
+			contains: []string{`aria-label="coverage: 42%"`, `fill="#e05d44"`, `>42%</text>`},
+		},
+		{
+			name:     "hex color",
+			label:    "coverage",
+			message:  "100%",
+			color:    "#123456",
+			contains: []string{`fill="#123456"`},
+		},
+		{
+			name:     "escaped",
+			label:    "<script>",
+			message:  "a&b",
+			color:    `"red`,
+			contains: []string{"&lt;script&gt;", "a&amp;b", `fill="&#34;red"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got = string(SVG(tt.label, tt.message, tt.color))
+
+			if !strings.HasPrefix(got, "<svg ") || !strings.HasSuffix(got, "</svg>") {
+				t.Errorf("SVG() not an svg: %s", got)
+			}
+
+			for _, c := range tt.contains {
+				if !strings.Contains(got, c) {
+					t.Errorf("SVG() does not contain %s: %s", c, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,516 @@
+package disk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fluxynet/goqa"
+)
+
+const (
+	// DefaultSegmentSize in bytes from which a new segment of the log is started
+	DefaultSegmentSize = 4 * 1024 * 1024
+
+	// segmentExt of the files of the log, named after the offset of their first event
+	segmentExt = ".log"
+
+	// offsetFilename holds the offset of the first event not acknowledged yet
+	offsetFilename = "offset"
+)
+
+var (
+	// ErrClosed means the broker was closed
+	ErrClosed = errors.New("broker is closed")
+
+	// ErrCorrupt means a segment of the log cannot be read, other than the end of the last one
+	ErrCorrupt = errors.New("log segment is corrupt")
+)
+
+// record of an event in the log, one json per line
+type record struct {
+	Offset uint64          `json:"offset"`
+	Name   string          `json:"name"`
+	Event  json.RawMessage `json:"event"`
+}
+
+// listener of events; those of Deliveries acknowledge them
+type listener struct {
+	ctx        context.Context
+	events     chan goqa.Event
+	deliveries chan goqa.Delivery
+	patterns   []string
+}
+
+// New broker keeping events in a log within dir, started anew every segmentSize bytes; 0 for the default
+// events not acknowledged when it was last closed are delivered again to the first listeners
+func New(dir string, segmentSize int64) (*Disk, error) {
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var d = &Disk{
+		dir:     dir,
+		size:    segmentSize,
+		unacked: make(map[uint64]int),
+		done:    make(chan struct{}),
+	}
+
+	d.cond = sync.NewCond(&d.mut)
+
+	if err := d.open(); err != nil {
+		return nil, err
+	}
+
+	go d.dispatch()
+
+	return d, nil
+}
+
+// Disk is a goqa.Durable broker on a segmented append-only log; publishing does not wait for listeners
+type Disk struct {
+	dir  string
+	size int64
+
+	mut       sync.Mutex
+	cond      *sync.Cond // signalled when there is something to dispatch or the broker is closed
+	segments  []uint64   // offset of the first event of every segment, the last one being written
+	file      *os.File
+	written   int64 // bytes of the last segment
+	next      uint64
+	committed uint64         // every event before is acknowledged
+	queue     []record       // not dispatched yet
+	unacked   map[uint64]int // dispatched events => acknowledgements awaited
+	listeners []*listener
+	closed    bool
+	done      chan struct{}
+}
+
+// open the log, queuing the events not acknowledged and truncating a partly written one at the end
+func (d *Disk) open() error {
+	var b, err = os.ReadFile(filepath.Join(d.dir, offsetFilename))
+	if err == nil {
+		if d.committed, err = strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64); err != nil {
+			return fmt.Errorf("%w: %s", ErrCorrupt, offsetFilename)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	names, err := filepath.Glob(filepath.Join(d.dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		var base, err = strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), segmentExt), 10, 64)
+		if err == nil {
+			d.segments = append(d.segments, base)
+		}
+	}
+
+	sort.Slice(d.segments, func(i, j int) bool {
+		return d.segments[i] < d.segments[j]
+	})
+
+	d.next = d.committed
+	for i, base := range d.segments {
+		if d.next < base {
+			d.next = base
+		}
+
+		if err = d.read(base, i == len(d.segments)-1); err != nil {
+			return err
+		}
+	}
+
+	if len(d.segments) == 0 {
+		return d.roll()
+	}
+
+	d.file, err = os.OpenFile(d.segment(d.segments[len(d.segments)-1]), os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+// read a segment, queuing events not acknowledged; the last one may end with a partly written event
+func (d *Disk) read(base uint64, last bool) error {
+	var f, err = os.Open(d.segment(base))
+	if err != nil {
+		return err
+	}
+
+	defer goqa.Closed(f)
+
+	var (
+		reader = bufio.NewReader(f)
+		valid  int64 // bytes up to the last complete event
+	)
+
+	for {
+		var line, err = reader.ReadBytes('\n')
+
+		var r record
+		if err == nil && json.Unmarshal(line, &r) == nil {
+			valid += int64(len(line))
+			d.next = r.Offset + 1
+
+			if r.Offset >= d.committed {
+				d.queue = append(d.queue, r)
+			}
+
+			continue
+		}
+
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if len(line) == 0 {
+			break
+		}
+
+		if !last {
+			return fmt.Errorf("%w: %s", ErrCorrupt, d.segment(base))
+		}
+
+		log.Printf("truncating partly written event of %s\n", d.segment(base))
+		if err = os.Truncate(d.segment(base), valid); err != nil {
+			return err
+		}
+
+		break
+	}
+
+	if last {
+		d.written = valid
+	}
+
+	return nil
+}
+
+// segment file starting at an offset
+func (d *Disk) segment(base uint64) string {
+	return filepath.Join(d.dir, fmt.Sprintf("%020d%s", base, segmentExt))
+}
+
+// roll to a new segment starting at the next offset
+func (d *Disk) roll() error {
+	if d.file != nil {
+		if err := d.file.Close(); err != nil {
+			return err
+		}
+	}
+
+	var f, err = os.OpenFile(d.segment(d.next), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	d.file = f
+	d.written = 0
+	d.segments = append(d.segments, d.next)
+
+	return nil
+}
+
+// Publish an event once written to disk
+func (d *Disk) Publish(ctx context.Context, event goqa.Event) error {
+	var b, err = json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if d.closed {
+		return ErrClosed
+	}
+
+	if d.written >= d.size {
+		if err = d.roll(); err != nil {
+			return err
+		}
+	}
+
+	var r = record{Offset: d.next, Name: event.Name(), Event: b}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	line = append(line, '\n')
+
+	if _, err = d.file.Write(line); err != nil {
+		return err
+	}
+
+	if err = d.file.Sync(); err != nil {
+		return err
+	}
+
+	d.written += int64(len(line))
+	d.next++
+	d.queue = append(d.queue, r)
+	d.cond.Broadcast()
+
+	return nil
+}
+
+// Listen to events, which are acknowledged once received
+func (d *Disk) Listen(ctx context.Context) (<-chan goqa.Event, error) {
+	return d.ListenTopics(ctx)
+}
+
+// ListenTopics to events with a topic matching any of patterns, which are acknowledged once received
+func (d *Disk) ListenTopics(ctx context.Context, patterns ...string) (<-chan goqa.Event, error) {
+	var l = &listener{ctx: ctx, events: make(chan goqa.Event), patterns: patterns}
+	if err := d.add(l); err != nil {
+		return nil, err
+	}
+
+	return l.events, nil
+}
+
+// Deliveries of events, which are delivered again once restarted unless acknowledged
+func (d *Disk) Deliveries(ctx context.Context) (<-chan goqa.Delivery, error) {
+	var l = &listener{ctx: ctx, deliveries: make(chan goqa.Delivery)}
+	if err := d.add(l); err != nil {
+		return nil, err
+	}
+
+	return l.deliveries, nil
+}
+
+func (d *Disk) add(l *listener) error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if d.closed {
+		return ErrClosed
+	}
+
+	d.listeners = append(d.listeners, l)
+	d.cond.Broadcast()
+
+	return nil
+}
+
+// remove a listener whose context is done
+func (d *Disk) remove(l *listener) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	for i := range d.listeners {
+		if d.listeners[i] == l {
+			d.listeners = append(d.listeners[:i], d.listeners[i+1:]...)
+			break
+		}
+	}
+
+	if l.events != nil {
+		close(l.events)
+	} else {
+		close(l.deliveries)
+	}
+}
+
+// dispatch events in order to every listener, as long as there are some
+func (d *Disk) dispatch() {
+	for {
+		d.mut.Lock()
+		for !d.closed && (len(d.queue) == 0 || len(d.listeners) == 0) {
+			d.cond.Wait()
+		}
+
+		if d.closed {
+			for _, l := range d.listeners {
+				if l.events != nil {
+					close(l.events)
+				} else {
+					close(l.deliveries)
+				}
+			}
+
+			d.listeners = nil
+			d.mut.Unlock()
+
+			return
+		}
+
+		var (
+			r         = d.queue[0]
+			listeners = append([]*listener{}, d.listeners...)
+			awaited   int // acknowledgements of deliveries; events listened to are once received
+		)
+
+		for _, l := range listeners {
+			if l.deliveries != nil {
+				awaited++
+			}
+		}
+
+		d.queue = d.queue[1:]
+		d.unacked[r.Offset] = awaited
+		d.mut.Unlock()
+
+		var event, err = goqa.Decode(r.Name, r.Event)
+		if err != nil {
+			log.Printf("failed to decode event %d\n%s\n", r.Offset, err.Error())
+			_ = d.ack(r.Offset, awaited) // it would never be
+			continue
+		}
+
+		for _, l := range listeners {
+			if !goqa.Routed(event, l.patterns) {
+				continue
+			}
+
+			if !d.send(l, r.Offset, event) {
+				d.remove(l)
+				if l.deliveries != nil {
+					_ = d.ack(r.Offset, 1)
+				}
+			}
+		}
+
+		if awaited == 0 {
+			_ = d.ack(r.Offset, 0)
+		}
+	}
+}
+
+// send an event to a listener, false when the listener is done
+func (d *Disk) send(l *listener, offset uint64, event goqa.Event) bool {
+	if l.events != nil {
+		select {
+		case l.events <- event:
+			return true
+		case <-l.ctx.Done():
+			return false
+		case <-d.done:
+			return true
+		}
+	}
+
+	var once sync.Once
+	var delivery = goqa.Delivery{
+		Event: event,
+		Ack: func() error {
+			var err error
+			once.Do(func() {
+				err = d.ack(offset, 1)
+			})
+
+			return err
+		},
+	}
+
+	select {
+	case l.deliveries <- delivery:
+		return true
+	case <-l.ctx.Done():
+		return false
+	case <-d.done:
+		return true
+	}
+}
+
+// ack an event n times; once it is acknowledged by all, so are the events up to the first one which is not
+func (d *Disk) ack(offset uint64, n int) error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if d.closed {
+		return ErrClosed
+	}
+
+	if _, ok := d.unacked[offset]; !ok {
+		return nil
+	}
+
+	if d.unacked[offset] -= n; d.unacked[offset] > 0 {
+		return nil
+	}
+
+	delete(d.unacked, offset)
+
+	var limit = d.next
+	if len(d.queue) != 0 {
+		limit = d.queue[0].Offset
+	}
+
+	var committed = d.committed
+	for committed < limit {
+		if _, ok := d.unacked[committed]; ok {
+			break
+		}
+
+		committed++
+	}
+
+	if committed == d.committed {
+		return nil
+	}
+
+	d.committed = committed
+
+	return d.commit()
+}
+
+// commit the offset of the first event not acknowledged, removing segments whose events all are
+func (d *Disk) commit() error {
+	var (
+		name = filepath.Join(d.dir, offsetFilename)
+		tmp  = name + ".tmp"
+	)
+
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(d.committed, 10)), 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, name); err != nil {
+		return err
+	}
+
+	for len(d.segments) > 1 && d.segments[1] <= d.committed {
+		if err := os.Remove(d.segment(d.segments[0])); err != nil {
+			return err
+		}
+
+		d.segments = d.segments[1:]
+	}
+
+	return nil
+}
+
+// Close the broker; events not acknowledged are delivered again once opened anew
+func (d *Disk) Close() error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if d.closed {
+		return nil
+	}
+
+	d.closed = true
+	close(d.done)
+	d.cond.Broadcast()
+
+	return d.file.Close()
+}
@@ -0,0 +1,317 @@
+package disk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fluxynet/goqa"
+)
+
+func coverage(pkg string) goqa.CoverageEvent {
+	return goqa.CoverageEvent{Repository: "acme/foo", Ref: "refs/heads/master", Pkg: pkg, Percentage: 50, Precise: 50.5}
+}
+
+func open(t *testing.T, dir string, size int64) *Disk {
+	t.Helper()
+
+	var d, err = New(dir, size)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	return d
+}
+
+// receive n deliveries, failing after a while
+func receive(t *testing.T, c <-chan goqa.Delivery, n int) []goqa.Delivery {
+	t.Helper()
+
+	var got []goqa.Delivery
+	for len(got) < n {
+		select {
+		case d := <-c:
+			got = append(got, d)
+		case <-time.After(time.Second):
+			t.Fatalf("deliveries; want %d, got %d", n, len(got))
+		}
+	}
+
+	return got
+}
+
+// none are delivered for a while
+func none(t *testing.T, c <-chan goqa.Delivery) {
+	t.Helper()
+
+	select {
+	case d := <-c:
+		t.Errorf("delivery; want none, got %v", d.Event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Run("New", func(t *testing.T) {
+		var d = open(t, t.TempDir(), 0)
+		defer d.Close()
+
+		var _ goqa.Durable = d
+		if d.size != DefaultSegmentSize {
+			t.Errorf("New() size = %d", d.size)
+		}
+	})
+
+	t.Run("corrupt offset", func(t *testing.T) {
+		var dir = t.TempDir()
+		_ = os.WriteFile(filepath.Join(dir, offsetFilename), []byte("x"), 0644)
+
+		if _, err := New(dir, 0); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("New() error = %v, want %v", err, ErrCorrupt)
+		}
+	})
+}
+
+func TestDisk_Publish(t *testing.T) {
+	var d = open(t, t.TempDir(), 0)
+
+	// nobody listens yet, publishing must not wait
+	for _, pkg := range []string{"foo", "bar"} {
+		if err := d.Publish(context.Background(), coverage(pkg)); err != nil {
+			t.Errorf("Publish() error = %v", err)
+		}
+	}
+
+	var c, err = d.Deliveries(context.Background())
+	if err != nil {
+		t.Errorf("Deliveries() error = %v", err)
+		return
+	}
+
+	var got = receive(t, c, 2)
+	for i, want := range []goqa.Event{coverage("foo"), coverage("bar")} {
+		if !reflect.DeepEqual(got[i].Event, want) {
+			t.Errorf("delivery(%d)\nwant = %v\ngot  = %v", i, want, got[i].Event)
+		}
+	}
+
+	_ = d.Close()
+
+	if err = d.Publish(context.Background(), coverage("baz")); err != ErrClosed {
+		t.Errorf("Publish() error = %v, want %v", err, ErrClosed)
+	}
+
+	if _, ok := <-c; ok {
+		t.Errorf("deliveries not closed with the broker")
+	}
+}
+
+func TestDisk_Replay(t *testing.T) {
+	tests := []struct {
+		name  string
+		acked []int // indexes of the deliveries acknowledged before closing
+		want  []string
+	}{
+		{name: "none acknowledged", want: []string{"a", "b", "c"}},
+		{name: "all acknowledged", acked: []int{0, 1, 2}},
+		{name: "first acknowledged", acked: []int{0}, want: []string{"b", "c"}},
+		{name: "acknowledged out of order", acked: []int{1, 0}, want: []string{"c"}},
+		{name: "gap", acked: []int{0, 2}, want: []string{"b", "c"}}, // at least once
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				dir = t.TempDir()
+				d   = open(t, dir, 0)
+			)
+
+			for _, pkg := range []string{"a", "b", "c"} {
+				_ = d.Publish(context.Background(), coverage(pkg))
+			}
+
+			var c, _ = d.Deliveries(context.Background())
+			var got = receive(t, c, 3)
+
+			for _, i := range tt.acked {
+				if err := got[i].Ack(); err != nil {
+					t.Errorf("Ack() error = %v", err)
+				}
+			}
+
+			_ = d.Close()
+
+			d = open(t, dir, 0)
+			defer d.Close()
+
+			c, _ = d.Deliveries(context.Background())
+
+			var replayed []string
+			for _, r := range receive(t, c, len(tt.want)) {
+				replayed = append(replayed, r.Event.(goqa.CoverageEvent).Pkg)
+			}
+
+			none(t, c)
+
+			if !reflect.DeepEqual(replayed, tt.want) {
+				t.Errorf("replayed; want %v, got %v", tt.want, replayed)
+			}
+		})
+	}
+}
+
+func TestDisk_Listen(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		d   = open(t, dir, 0)
+	)
+
+	var events, _ = d.Listen(context.Background())
+	var deliveries, _ = d.Deliveries(context.Background())
+
+	_ = d.Publish(context.Background(), coverage("foo"))
+
+	select {
+	case e := <-events:
+		if !reflect.DeepEqual(e, coverage("foo")) {
+			t.Errorf("event\nwant = %v\ngot  = %v", coverage("foo"), e)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("event not received")
+	}
+
+	// the event is only acknowledged once every delivery is
+	_ = receive(t, deliveries, 1)[0].Ack()
+	_ = d.Close()
+
+	d = open(t, dir, 0)
+	defer d.Close()
+
+	deliveries, _ = d.Deliveries(context.Background())
+	none(t, deliveries)
+}
+
+func TestDisk_ListenTopics(t *testing.T) {
+	var d = open(t, t.TempDir(), 0)
+	defer d.Close()
+
+	var _ goqa.Router = d
+
+	var acme, _ = d.ListenTopics(context.Background(), "repo.acme/*")
+	var c, _ = d.Deliveries(context.Background())
+
+	for _, repo := range []string{"other/foo", "acme/foo"} {
+		_ = d.Publish(context.Background(), goqa.CoverageEvent{Repository: repo})
+	}
+
+	// every event is delivered, the routed listener only getting its own; listeners are sent events in turn
+	_ = receive(t, c, 1)
+
+	select {
+	case e := <-acme:
+		if want := (goqa.CoverageEvent{Repository: "acme/foo"}); e != want {
+			t.Errorf("event\nwant = %v\ngot  = %v", want, e)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("event not received")
+	}
+
+	_ = receive(t, c, 1)
+}
+
+func TestDisk_ListenDone(t *testing.T) {
+	var d = open(t, t.TempDir(), 0)
+	defer d.Close()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	var gone, _ = d.Deliveries(ctx)
+	var c, _ = d.Deliveries(context.Background())
+
+	cancel()
+	_ = d.Publish(context.Background(), coverage("foo"))
+
+	_ = receive(t, c, 1)
+
+	if _, ok := <-gone; ok {
+		t.Errorf("deliveries of a done context not closed")
+	}
+}
+
+func TestDisk_Segments(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		d   = open(t, dir, 1) // every event in its own segment
+	)
+
+	for _, pkg := range []string{"a", "b", "c"} {
+		_ = d.Publish(context.Background(), coverage(pkg))
+	}
+
+	var segments = func() int {
+		var names, _ = filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+		return len(names)
+	}
+
+	if got := segments(); got != 3 {
+		t.Errorf("segments; want 3, got %d", got)
+	}
+
+	var c, _ = d.Deliveries(context.Background())
+	var got = receive(t, c, 3)
+
+	_ = got[0].Ack()
+	_ = got[1].Ack()
+
+	// segments whose events are all acknowledged are removed, the last one is kept to be written
+	if got := segments(); got != 1 {
+		t.Errorf("segments; want 1, got %d", got)
+	}
+
+	_ = got[2].Ack()
+	_ = d.Close()
+
+	d = open(t, dir, 1)
+	defer d.Close()
+
+	_ = d.Publish(context.Background(), coverage("d"))
+
+	c, _ = d.Deliveries(context.Background())
+	if e := receive(t, c, 1)[0].Event.(goqa.CoverageEvent); e.Pkg != "d" {
+		t.Errorf("delivery; want d, got %s", e.Pkg)
+	}
+}
+
+func TestDisk_Truncated(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		d   = open(t, dir, 0)
+	)
+
+	_ = d.Publish(context.Background(), coverage("a"))
+	_ = d.Close()
+
+	// crashed while writing the next event
+	var f, _ = os.OpenFile(d.segment(0), os.O_WRONLY|os.O_APPEND, 0644)
+	_, _ = f.WriteString(`{"offset":1,"name":"EVENT_COV`)
+	_ = f.Close()
+
+	d = open(t, dir, 0)
+	defer d.Close()
+
+	_ = d.Publish(context.Background(), coverage("b"))
+
+	var c, _ = d.Deliveries(context.Background())
+
+	var replayed []string
+	for _, r := range receive(t, c, 2) {
+		replayed = append(replayed, r.Event.(goqa.CoverageEvent).Pkg)
+	}
+
+	if want := []string{"a", "b"}; !reflect.DeepEqual(replayed, want) {
+		t.Errorf("replayed; want %v, got %v", want, replayed)
+	}
+}
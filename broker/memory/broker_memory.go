@@ -2,69 +2,237 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 
 	"github.com/fluxynet/goqa"
 )
 
+// Policy when the queue of a listener is full
+type Policy int
+
+const (
+	// Block publishing until there is room, the listener is gone or the context of the publisher is done
+	Block Policy = iota
+
+	// DropOldest event queued to make room
+	DropOldest
+
+	// DropNewest event, the one being published
+	DropNewest
+
+	// Fail publishing with ErrFull, once delivered to the other listeners
+	Fail
+)
+
+// DefaultCapacity is the number of events queued per listener by default
+const DefaultCapacity = 64
+
+// policies by the name they are configured with
+var policies = map[string]Policy{
+	"":            Block,
+	"block":       Block,
+	"drop-oldest": DropOldest,
+	"drop-newest": DropNewest,
+	"error":       Fail,
+}
+
+var (
+	// ErrPolicy means a policy is unknown
+	ErrPolicy = errors.New("policy is unknown")
+
+	// ErrFull means the queue of a listener is full
+	ErrFull = errors.New("listener queue is full")
+
+	// ErrClosed means the broker was closed
+	ErrClosed = errors.New("broker is closed")
+)
+
+// ParsePolicy out of its name: block, drop-oldest, drop-newest or error; empty for block
+func ParsePolicy(name string) (Policy, error) {
+	var p, ok = policies[name]
+	if !ok {
+		return Block, ErrPolicy
+	}
+
+	return p, nil
+}
+
+type listener struct {
+	ctx      context.Context
+	events   chan goqa.Event
+	patterns []string
+}
+
+// Memory broker queuing up to capacity events per listener, applying policy when full
 type Memory struct {
-	listeners []chan goqa.Event
-	mutex     sync.Mutex
+	listeners []*listener
+	mutex     sync.RWMutex
+	capacity  int
+	policy    Policy
+	dropped   uint64
+	done      chan struct{}
+	once      sync.Once
 }
 
-func New() *Memory {
-	return &Memory{}
+// New memory broker queuing capacity events per listener; 0 for the default
+func New(capacity int, policy Policy) *Memory {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+
+	return &Memory{capacity: capacity, policy: policy, done: make(chan struct{})}
 }
 
+// Dropped is the number of events dropped as queues were full
+func (m *Memory) Dropped() uint64 {
+	return atomic.LoadUint64(&m.dropped)
+}
+
+// Listen to events until ctx is done, when the channel is closed
 func (m *Memory) Listen(ctx context.Context) (<-chan goqa.Event, error) {
-	var c = make(chan goqa.Event)
+	return m.ListenTopics(ctx)
+}
+
+// ListenTopics to events with a topic matching any of patterns until ctx is done, when the channel is closed
+func (m *Memory) ListenTopics(ctx context.Context, patterns ...string) (<-chan goqa.Event, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var l = &listener{ctx: ctx, events: make(chan goqa.Event, m.capacity), patterns: patterns}
 
 	defer m.mutex.Unlock()
 	m.mutex.Lock()
-	m.listeners = append(m.listeners, c)
 
-	return c, nil
+	select {
+	case <-m.done:
+		return nil, ErrClosed
+	default:
+	}
+
+	m.listeners = append(m.listeners, l)
+
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				m.remove(l)
+			case <-m.done:
+			}
+		}()
+	}
+
+	return l.events, nil
 }
 
-func (m *Memory) Publish(ctx context.Context, event goqa.Event) error {
+// remove a listener, closing its channel
+func (m *Memory) remove(l *listener) {
 	defer m.mutex.Unlock()
 	m.mutex.Lock()
 
-	var wg sync.WaitGroup
-	wg.Add(len(m.listeners))
-
 	for i := range m.listeners {
-		go func(l chan goqa.Event) {
-			defer wg.Done()
-			l <- event
-		}(m.listeners[i])
+		if m.listeners[i] == l {
+			m.listeners = append(m.listeners[:i], m.listeners[i+1:]...)
+			close(l.events)
+
+			return
+		}
 	}
+}
+
+// Publish an event to every listener, without waiting for them to read it unless their queue is full
+func (m *Memory) Publish(ctx context.Context, event goqa.Event) error {
+	defer m.mutex.RUnlock()
+	m.mutex.RLock()
 
-	wg.Wait()
+	select {
+	case <-m.done:
+		return ErrClosed
+	default:
+	}
 
-	return nil
+	var err error
+	for _, l := range m.listeners {
+		if !goqa.Routed(event, l.patterns) {
+			continue
+		}
+
+		switch e := m.send(ctx, l, event); e {
+		case nil:
+		case ErrFull:
+			err = e
+		default:
+			return e
+		}
+	}
+
+	return err
 }
 
-func (m *Memory) Close() error {
-	if m.listeners == nil {
+// send an event to a listener, according to the policy
+func (m *Memory) send(ctx context.Context, l *listener, event goqa.Event) error {
+	select {
+	case l.events <- event:
 		return nil
+	default:
 	}
 
+	switch m.policy {
+	case DropNewest:
+		atomic.AddUint64(&m.dropped, 1)
+	case DropOldest:
+		for {
+			select {
+			case l.events <- event:
+				return nil
+			default:
+			}
+
+			select {
+			case <-l.events:
+				atomic.AddUint64(&m.dropped, 1)
+			default: // read by the listener meanwhile
+			}
+		}
+	case Fail:
+		atomic.AddUint64(&m.dropped, 1)
+		return ErrFull
+	default:
+		var done <-chan struct{}
+		if ctx != nil {
+			done = ctx.Done()
+		}
+
+		select {
+		case l.events <- event:
+		case <-l.ctx.Done(): // removed meanwhile
+		case <-done:
+			return ctx.Err()
+		case <-m.done:
+			return ErrClosed
+		}
+	}
+
+	return nil
+}
+
+// Close the broker and the channels of its listeners
+func (m *Memory) Close() error {
+	m.once.Do(func() {
+		if m.done != nil {
+			close(m.done)
+		}
+	})
+
 	defer m.mutex.Unlock()
 	m.mutex.Lock()
 
-	var wg sync.WaitGroup
-	wg.Add(len(m.listeners))
-
-	for i := range m.listeners {
-		go func(l chan goqa.Event) {
-			defer wg.Done()
-			close(l)
-		}(m.listeners[i])
+	for _, l := range m.listeners {
+		close(l.events)
 	}
 
-	wg.Wait()
-
 	m.listeners = nil
 
 	return nil
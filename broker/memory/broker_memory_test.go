@@ -3,7 +3,6 @@ package memory
 import (
 	"context"
 	"reflect"
-	"sync"
 	"testing"
 	"time"
 
@@ -30,18 +29,46 @@ func (d DummyEvent) String() string {
 	return ContentDummy
 }
 
-func makeListeners(t int) []chan goqa.Event {
-	var c = make([]chan goqa.Event, t)
-	for i := range c {
-		c[i] = make(chan goqa.Event)
+func makeListeners(t int) []*listener {
+	var l = make([]*listener, t)
+	for i := range l {
+		l[i] = &listener{ctx: context.Background(), events: make(chan goqa.Event, 1)}
 	}
 
-	return c
+	return l
+}
+
+// pending events of a listener, without waiting
+func pending(c <-chan goqa.Event) []goqa.Event {
+	var events []goqa.Event
+	for {
+		select {
+		case e, ok := <-c:
+			if !ok {
+				return events
+			}
+
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+// NumberedEvent tells events apart
+type NumberedEvent int
+
+func (n NumberedEvent) Name() string {
+	return EventDummy
+}
+
+func (n NumberedEvent) String() string {
+	return ContentDummy
 }
 
 func TestMemory_Close(t *testing.T) {
 	type fields struct {
-		listeners []chan goqa.Event
+		listeners []*listener
 	}
 
 	tests := []struct {
@@ -68,12 +95,10 @@ func TestMemory_Close(t *testing.T) {
 	for _, tt := range tests {
 
 		t.Run(tt.name, func(t *testing.T) {
-			m := &Memory{
-				listeners: tt.fields.listeners,
-				mutex:     sync.Mutex{},
-			}
+			m := New(0, Block)
+			m.listeners = tt.fields.listeners
 
-			l := append([]chan goqa.Event{}, tt.fields.listeners...)
+			l := append([]*listener{}, tt.fields.listeners...)
 
 			if err := m.Close(); (err != nil) != tt.wantErr {
 				t.Errorf("Close() error = %v, wantErr %v", err, tt.wantErr)
@@ -81,20 +106,28 @@ func TestMemory_Close(t *testing.T) {
 
 			for i := range l {
 				select {
-				case <-l[i]:
+				case <-l[i].events:
 				case <-time.After(time.Millisecond):
 					t.Errorf("channel %d not closed", i)
 				}
 			}
 
-			internal.AssertMutexUnlocked(t, &m.mutex)
+			if err := m.Publish(context.Background(), DummyEvent{}); err != ErrClosed {
+				t.Errorf("Publish() error = %v, want %v", err, ErrClosed)
+			}
+
+			if _, err := m.Listen(context.Background()); err != ErrClosed {
+				t.Errorf("Listen() error = %v, want %v", err, ErrClosed)
+			}
+
+			internal.AssertRWMutexUnlocked(t, &m.mutex)
 		})
 	}
 }
 
 func TestMemory_Listen(t *testing.T) {
 	type fields struct {
-		listeners []chan goqa.Event
+		listeners []*listener
 	}
 
 	type args struct {
@@ -125,10 +158,8 @@ func TestMemory_Listen(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			m := &Memory{
-				listeners: tt.fields.listeners,
-				mutex:     sync.Mutex{},
-			}
+			m := New(0, Block)
+			m.listeners = tt.fields.listeners
 
 			before := len(m.listeners)
 
@@ -145,19 +176,76 @@ func TestMemory_Listen(t *testing.T) {
 				return
 			}
 
-			if m.listeners[before] != got {
+			if m.listeners[before].events != got {
 				t.Errorf("channel returned is different from the one created")
 				return
 			}
 
-			internal.AssertMutexUnlocked(t, &m.mutex)
+			if cap(got) != DefaultCapacity {
+				t.Errorf("capacity; want %d, got %d", DefaultCapacity, cap(got))
+			}
+
+			internal.AssertRWMutexUnlocked(t, &m.mutex)
 		})
 	}
 }
 
+func TestMemory_ListenDone(t *testing.T) {
+	var (
+		m           = New(1, Block)
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+
+	defer m.Close()
+
+	var gone, _ = m.Listen(ctx)
+	var c, _ = m.Listen(context.Background())
+
+	_ = m.Publish(context.Background(), NumberedEvent(1))
+	cancel()
+
+	// publishing does not block on a listener once its context is done, even with a full queue
+	var published = make(chan error)
+	go func() {
+		published <- m.Publish(context.Background(), NumberedEvent(2))
+	}()
+
+	<-c
+
+	select {
+	case err := <-published:
+		if err != nil {
+			t.Errorf("Publish() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Publish() blocked by a listener whose context is done")
+	}
+
+	var closed = make(chan struct{})
+	go func() {
+		for range gone {
+		}
+
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Errorf("channel of a listener whose context is done not closed")
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if len(m.listeners) != 1 {
+		t.Errorf("listeners; want 1, got %d", len(m.listeners))
+	}
+}
+
 func TestMemory_Publish(t *testing.T) {
 	type fields struct {
-		listeners []chan goqa.Event
+		listeners []*listener
 	}
 
 	type args struct {
@@ -206,26 +294,19 @@ func TestMemory_Publish(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			m := &Memory{
-				listeners: tt.fields.listeners,
-				mutex:     sync.Mutex{},
-			}
-
-			var wg sync.WaitGroup
-			wg.Add(1)
+			m := New(0, Block)
+			m.listeners = tt.fields.listeners
 
-			go func() {
-				defer wg.Done()
-				if err := m.Publish(tt.args.ctx, tt.args.event); (err != nil) != tt.wantErr {
-					t.Errorf("Publish() error = %v, wantErr %v", err, tt.wantErr)
-				}
+			// listeners have room, nobody reads yet
+			if err := m.Publish(tt.args.ctx, tt.args.event); (err != nil) != tt.wantErr {
+				t.Errorf("Publish() error = %v, wantErr %v", err, tt.wantErr)
+			}
 
-				internal.AssertMutexUnlocked(t, &m.mutex)
-			}()
+			internal.AssertRWMutexUnlocked(t, &m.mutex)
 
 			for i := range m.listeners {
 				select {
-				case ev := <-m.listeners[i]:
+				case ev := <-m.listeners[i].events:
 					if !reflect.DeepEqual(tt.args.event, ev) {
 						t.Errorf(
 							"event different at %d\nwant: [%s] %s\ngot:  [%s] [%s]\n",
@@ -235,19 +316,161 @@ func TestMemory_Publish(t *testing.T) {
 						)
 						return
 					}
-				case <-time.After(time.Millisecond):
+				default:
 					t.Errorf("event not received!")
 					return
 				}
 			}
+		})
+	}
+}
 
-			wg.Wait()
+func TestMemory_Policy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      Policy
+		wantErr     error
+		wantPending []goqa.Event
+		wantDropped uint64
+	}{
+		{
+			name:        "drop oldest",
+			policy:      DropOldest,
+			wantPending: []goqa.Event{NumberedEvent(2), NumberedEvent(3)},
+			wantDropped: 1,
+		},
+		{
+			name:        "drop newest",
+			policy:      DropNewest,
+			wantPending: []goqa.Event{NumberedEvent(1), NumberedEvent(2)},
+			wantDropped: 1,
+		},
+		{
+			name:        "fail",
+			policy:      Fail,
+			wantErr:     ErrFull,
+			wantPending: []goqa.Event{NumberedEvent(1), NumberedEvent(2)},
+			wantDropped: 1,
+		},
+		{
+			name:        "block",
+			policy:      Block,
+			wantErr:     context.DeadlineExceeded,
+			wantPending: []goqa.Event{NumberedEvent(1), NumberedEvent(2)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m = New(2, tt.policy)
+			defer m.Close()
+
+			var slow, _ = m.Listen(context.Background())
+			var fast, _ = m.Listen(context.Background())
+
+			for _, e := range []goqa.Event{NumberedEvent(1), NumberedEvent(2)} {
+				if err := m.Publish(context.Background(), e); err != nil {
+					t.Errorf("Publish() error = %v", err)
+				}
+			}
+
+			_ = pending(fast)
+
+			var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			if err := m.Publish(ctx, NumberedEvent(3)); err != tt.wantErr {
+				t.Errorf("Publish() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := pending(slow); !reflect.DeepEqual(got, tt.wantPending) {
+				t.Errorf("pending; want %v, got %v", tt.wantPending, got)
+			}
+
+			// the other listener is never starved, but for the blocking policy waiting on the slow one first
+			if got := pending(fast); tt.policy != Block && !reflect.DeepEqual(got, []goqa.Event{NumberedEvent(3)}) {
+				t.Errorf("pending of the other listener; want [3], got %v", got)
+			}
+
+			if got := m.Dropped(); got != tt.wantDropped {
+				t.Errorf("Dropped() = %d, want %d", got, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestMemory_ListenTopics(t *testing.T) {
+	var m = New(0, Block)
+	defer m.Close()
+
+	var _ goqa.Router = m
+
+	var coverage, _ = m.ListenTopics(context.Background(), "EVENT_COVERAGE.*")
+	var acme, _ = m.ListenTopics(context.Background(), "repo.acme/*")
+	var all, _ = m.Listen(context.Background())
+
+	var events = []goqa.Event{
+		goqa.CoverageEvent{Repository: "acme/foo"},
+		goqa.CoverageEvent{Repository: "other/foo"},
+		goqa.DiffEvent{Repository: "acme/foo"},
+		DummyEvent{},
+	}
+
+	for _, e := range events {
+		if err := m.Publish(context.Background(), e); err != nil {
+			t.Errorf("Publish() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		c    <-chan goqa.Event
+		want []goqa.Event
+	}{
+		{name: "coverage", c: coverage, want: events[:2]},
+		{name: "repository", c: acme, want: []goqa.Event{events[0], events[2]}},
+		{name: "all", c: all, want: events},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pending(tt.c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events\nwant = %v\ngot  = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Policy
+		wantErr error
+	}{
+		{name: "", want: Block},
+		{name: "block", want: Block},
+		{name: "drop-oldest", want: DropOldest},
+		{name: "drop-newest", want: DropNewest},
+		{name: "error", want: Fail},
+		{name: "whatever", want: Block, wantErr: ErrPolicy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePolicy(tt.name)
+			if got != tt.want || err != tt.wantErr {
+				t.Errorf("ParsePolicy() = %d, %v, want %d, %v", got, err, tt.want, tt.wantErr)
+			}
 		})
 	}
 }
 
 func TestNew(t *testing.T) {
 	t.Run("New", func(t *testing.T) {
-		var _ goqa.Broker = New()
+		var _ goqa.Broker = New(0, Block)
+
+		if m := New(0, DropOldest); m.capacity != DefaultCapacity || m.policy != DropOldest {
+			t.Errorf("New() capacity = %d, policy = %d", m.capacity, m.policy)
+		}
 	})
 }
@@ -7,18 +7,18 @@ import (
 )
 
 type Memory struct {
-	items map[string]goqa.Coverage
+	items map[goqa.Key]goqa.Coverage
 	mut   sync.Mutex
 }
 
 func New() *Memory {
-	return &Memory{items: make(map[string]goqa.Coverage)}
+	return &Memory{items: make(map[goqa.Key]goqa.Coverage)}
 }
 
 func (m *Memory) Reset(covs ...goqa.Coverage) error {
-	var d = make(map[string]goqa.Coverage, len(covs))
+	var d = make(map[goqa.Key]goqa.Coverage, len(covs))
 	for i := range covs {
-		d[covs[i].Pkg] = covs[i]
+		d[covs[i].Key()] = covs[i]
 	}
 
 	defer m.mut.Unlock()
@@ -29,16 +29,44 @@ func (m *Memory) Reset(covs ...goqa.Coverage) error {
 	return nil
 }
 
-func (m *Memory) Get(pkg string) (*goqa.Coverage, bool) {
+func (m *Memory) Replace(repository, ref string, covs ...goqa.Coverage) error {
+	defer m.mut.Unlock()
+	m.mut.Lock()
+
+	if m.items == nil {
+		m.items = make(map[goqa.Key]goqa.Coverage, len(covs))
+	}
+
+	for k := range m.items {
+		if k.Repository == repository && k.Ref == ref {
+			delete(m.items, k)
+		}
+	}
+
+	for i := range covs {
+		var c = covs[i]
+		c.Repository = repository
+		c.Ref = ref
+
+		m.items[c.Key()] = c
+	}
+
+	return nil
+}
+
+func (m *Memory) Get(key goqa.Key) (*goqa.Coverage, bool) {
 	if m.items == nil {
 		return nil, false
 	}
 
-	var v, ok = m.items[pkg]
+	defer m.mut.Unlock()
+	m.mut.Lock()
+
+	var v, ok = m.items[key]
 	return &v, ok
 }
 
-func (m *Memory) Keys() ([]string, error) {
+func (m *Memory) Keys() ([]goqa.Key, error) {
 	if m.items == nil {
 		return nil, nil
 	}
@@ -47,7 +75,7 @@ func (m *Memory) Keys() ([]string, error) {
 	m.mut.Lock()
 
 	var (
-		keys = make([]string, len(m.items))
+		keys = make([]goqa.Key, len(m.items))
 		i    int
 	)
 
@@ -10,13 +10,13 @@ import (
 	"github.com/fluxynet/goqa/internal"
 )
 
-func makeMapCoverages(t, skip int) map[string]goqa.Coverage {
-	var m = make(map[string]goqa.Coverage, t)
+func makeMapCoverages(t, skip int) map[goqa.Key]goqa.Coverage {
+	var m = make(map[goqa.Key]goqa.Coverage, t)
 	t = t + skip
 
 	for i := skip; i < t; i++ {
 		var pkg = "pkg." + strconv.Itoa(i)
-		m[pkg] = goqa.Coverage{
+		m[goqa.Key{Pkg: pkg}] = goqa.Coverage{
 			Pkg:        pkg,
 			Percentage: i,
 			Time:       "2006-01-02T15:04:05Z07:00",
@@ -78,7 +78,7 @@ func assertStringSlicesEqual(t *testing.T, got, want []string) {
 	}
 }
 
-func assertCoveragesEqual(t *testing.T, got map[string]goqa.Coverage, want []goqa.Coverage) {
+func assertCoveragesEqual(t *testing.T, got map[goqa.Key]goqa.Coverage, want []goqa.Coverage) {
 	var lg, lw int
 
 	if got != nil {
@@ -100,7 +100,7 @@ func assertCoveragesEqual(t *testing.T, got map[string]goqa.Coverage, want []goq
 
 	for k, w := range want {
 		var (
-			g    = got[w.Pkg]
+			g    = got[w.Key()]
 			same = g.Percentage == w.Percentage && g.Pkg == w.Pkg && g.Time == w.Time
 		)
 
@@ -118,7 +118,7 @@ func assertCoveragesEqual(t *testing.T, got map[string]goqa.Coverage, want []goq
 
 func TestMemory_Close(t *testing.T) {
 	type fields struct {
-		items map[string]goqa.Coverage
+		items map[goqa.Key]goqa.Coverage
 	}
 
 	tests := []struct {
@@ -164,7 +164,7 @@ func TestMemory_Close(t *testing.T) {
 
 func TestMemory_Get(t *testing.T) {
 	type fields struct {
-		items map[string]goqa.Coverage
+		items map[goqa.Key]goqa.Coverage
 	}
 
 	tests := []struct {
@@ -196,13 +196,13 @@ func TestMemory_Get(t *testing.T) {
 			for i := 0; i < tt.total; i++ {
 				var pkg = "pkg." + strconv.Itoa(i)
 
-				got, ok := m.Get(pkg)
+				got, ok := m.Get(goqa.Key{Pkg: pkg})
 				if !ok {
 					t.Errorf("could not get pkg: %s", pkg)
 					return
 				}
 
-				want := m.items[pkg]
+				want := m.items[goqa.Key{Pkg: pkg}]
 				same := got.Time == want.Time && got.Pkg == want.Pkg && got.Percentage == want.Percentage
 				if !same {
 					t.Errorf(
@@ -218,7 +218,7 @@ func TestMemory_Get(t *testing.T) {
 			for i := -100; i < 0; i++ {
 				var pkg = "pkg." + strconv.Itoa(i)
 
-				_, ok := m.Get(pkg)
+				_, ok := m.Get(goqa.Key{Pkg: pkg})
 				if ok {
 					t.Errorf("should not get pkg: %s", pkg)
 					return
@@ -232,7 +232,7 @@ func TestMemory_Get(t *testing.T) {
 
 func TestMemory_Keys(t *testing.T) {
 	type fields struct {
-		items map[string]goqa.Coverage
+		items map[goqa.Key]goqa.Coverage
 	}
 	tests := []struct {
 		name    string
@@ -268,12 +268,17 @@ func TestMemory_Keys(t *testing.T) {
 				mut:   sync.Mutex{},
 			}
 
-			got, err := m.Keys()
+			keys, err := m.Keys()
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Keys() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
+			var got []string
+			for i := range keys {
+				got = append(got, keys[i].Pkg)
+			}
+
 			// order is not guaranteed and also not important
 			sort.Strings(got)
 			sort.Strings(tt.want)
@@ -292,7 +297,7 @@ func TestNew(t *testing.T) {
 
 func TestMemory_Reset(t *testing.T) {
 	type fields struct {
-		items map[string]goqa.Coverage
+		items map[goqa.Key]goqa.Coverage
 	}
 
 	type args struct {
@@ -346,3 +351,65 @@ func TestMemory_Reset(t *testing.T) {
 		})
 	}
 }
+
+func TestMemory_Replace(t *testing.T) {
+	var (
+		a1 = goqa.Coverage{Repository: "a", Ref: "master", Pkg: "pkg.1", Percentage: 10}
+		a2 = goqa.Coverage{Repository: "a", Ref: "master", Pkg: "pkg.2", Percentage: 20}
+		ad = goqa.Coverage{Repository: "a", Ref: "develop", Pkg: "pkg.1", Percentage: 30}
+		b1 = goqa.Coverage{Repository: "b", Ref: "master", Pkg: "pkg.1", Percentage: 40}
+	)
+
+	type args struct {
+		repository string
+		ref        string
+		covs       []goqa.Coverage
+	}
+
+	tests := []struct {
+		name  string
+		items []goqa.Coverage
+		args  args
+		want  []goqa.Coverage
+	}{
+		{
+			name: "empty -> non-empty",
+			args: args{repository: "a", ref: "master", covs: []goqa.Coverage{a1, a2}},
+			want: []goqa.Coverage{a1, a2},
+		},
+		{
+			name:  "other repository untouched",
+			items: []goqa.Coverage{a1, a2, b1},
+			args:  args{repository: "b", ref: "master", covs: []goqa.Coverage{{Pkg: "pkg.3", Percentage: 50}}},
+			want:  []goqa.Coverage{a1, a2, {Repository: "b", Ref: "master", Pkg: "pkg.3", Percentage: 50}},
+		},
+		{
+			name:  "other ref untouched",
+			items: []goqa.Coverage{a1, a2, ad},
+			args:  args{repository: "a", ref: "master", covs: []goqa.Coverage{{Pkg: "pkg.1", Percentage: 60}}},
+			want:  []goqa.Coverage{ad, {Repository: "a", Ref: "master", Pkg: "pkg.1", Percentage: 60}},
+		},
+		{
+			name:  "non-empty -> empty",
+			items: []goqa.Coverage{a1, a2, b1},
+			args:  args{repository: "a", ref: "master"},
+			want:  []goqa.Coverage{b1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New()
+			if err := m.Reset(tt.items...); err != nil {
+				t.Errorf("Reset() error = %v", err)
+				return
+			}
+
+			if err := m.Replace(tt.args.repository, tt.args.ref, tt.args.covs...); err != nil {
+				t.Errorf("Replace() error = %v", err)
+			}
+
+			assertCoveragesEqual(t, m.items, tt.want)
+		})
+	}
+}
@@ -2,12 +2,26 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"time"
+
+	"github.com/fluxynet/goqa/badge"
+	"github.com/fluxynet/goqa/gate"
 )
 
 const (
 	// configFilename is the name of the configuration file
 	configFilename = "config.json"
+
+	// RoleHook serves the endpoints receiving coverage e.g. /github or /upload
+	RoleHook = "hook"
+
+	// RoleWeb serves the api and the dashboard
+	RoleWeb = "web"
+
+	// RoleWorker runs the subscribers doing the work e.g. keeping history, reporting to GitHub or emailing
+	RoleWorker = "worker"
 )
 
 // Config contains configuration variables for the app
@@ -20,6 +34,82 @@ type Config struct {
 	EmailFrom        string   `json:"email_from"`
 	EmailSubscribers []string `json:"email_subscribers"`
 	GithubSigKey     string   `json:"github_sigkey"`
+
+	// GithubSigKeys also accepted along GithubSigKey, while rotating it
+	GithubSigKeys []string `json:"github_sigkeys"`
+
+	// GithubRejectSHA1 signatures, only trusting X-Hub-Signature-256
+	GithubRejectSHA1 bool `json:"github_reject_sha1"`
+
+	// BrokerDir keeps events on disk until handled, so that none are lost if the process dies; empty to keep them in memory
+	// as by default; the directory e.g. events is created when missing and holds a log of segments and an offset file
+	BrokerDir string `json:"broker_dir"`
+
+	// BrokerURL of a NATS server e.g. nats://token@host:4222 through which processes share events; used over BrokerDir
+	BrokerURL string `json:"broker_url"`
+
+	// BrokerPrefix of the NATS subjects events are published under; empty for goqa
+	BrokerPrefix string `json:"broker_prefix"`
+
+	// BrokerGroup is the NATS queue group of workers, each event being handled by a single one of them; empty for goqa
+	BrokerGroup string `json:"broker_group"`
+
+	// Roles of the process among hook, web and worker, so that processes sharing a broker split the work; empty for all
+	Roles []string `json:"roles"`
+
+	// BrokerCapacity is the number of events queued per listener of the memory broker; 0 for the default
+	BrokerCapacity int `json:"broker_capacity"`
+
+	// BrokerPolicy of the memory broker when a queue is full: block, drop-oldest, drop-newest or error; empty to block
+	BrokerPolicy string `json:"broker_policy"`
+
+	// DeliveryCapacity is the number of web hook deliveries remembered to ignore redeliveries; 0 for the default
+	DeliveryCapacity int `json:"delivery_capacity"`
+
+	// DeliveryTTL in seconds for which web hook deliveries are remembered; 0 for the default
+	DeliveryTTL float64 `json:"delivery_ttl"`
+
+	// PayloadMaxAge in seconds of web hooks, judged by the latest time reported by tests; 0 for no limit
+	PayloadMaxAge float64 `json:"payload_max_age"`
+
+	// GitlabToken expected in X-Gitlab-Token from GitLab CI jobs; empty to refuse them
+	GitlabToken string `json:"gitlab_token"`
+
+	// GiteaSecret signing web hooks from Gitea Actions or Woodpecker with HMAC-SHA256; empty to refuse them
+	GiteaSecret string `json:"gitea_secret"`
+
+	// IngestHeader holding the token of any other CI; empty for X-Goqa-Token
+	IngestHeader string `json:"ingest_header"`
+
+	// IngestToken expected from any other CI; empty to refuse them
+	IngestToken string `json:"ingest_token"`
+
+	// GithubToken to set commit statuses and comment on pull requests; empty to not report back to GitHub
+	GithubToken string `json:"github_token"`
+
+	// GithubURL of the GitHub compatible api e.g. https://host/api/v3 for GitHub Enterprise; empty for the public one
+	GithubURL string `json:"github_url"`
+
+	// Gates are quality gate rules evaluated on every web hook
+	Gates []gate.Rule `json:"gates"`
+
+	// FlakyThreshold score from which a test is reported as flaky; 0 for the default
+	FlakyThreshold float64 `json:"flaky_threshold"`
+
+	// FlakyWindow is the number of latest runs considered per test; 0 for the default
+	FlakyWindow int `json:"flaky_window"`
+
+	// SlowFactor by which a test must exceed its median duration to be reported as slow; 0 for the default
+	SlowFactor float64 `json:"slow_factor"`
+
+	// SlowMinElapsed in seconds under which tests are never reported as slow; 0 for the default
+	SlowMinElapsed float64 `json:"slow_min_elapsed"`
+
+	// DurationWindow is the number of latest passed runs considered per test for durations; 0 for the default
+	DurationWindow int `json:"duration_window"`
+
+	// BadgeBands are the colors of badges by coverage; empty for the default
+	BadgeBands []badge.Band `json:"badge_bands"`
 }
 
 // LoadConf from a file named config.json placed in the same directory; bleh
@@ -35,5 +125,35 @@ func LoadConf() (*Config, error) {
 		return nil, err
 	}
 
+	for _, role := range conf.Roles {
+		if role != RoleHook && role != RoleWeb && role != RoleWorker {
+			return nil, fmt.Errorf("unknown role %q", role)
+		}
+	}
+
+	if conf.BrokerGroup == "" {
+		conf.BrokerGroup = "goqa"
+	}
+
 	return &conf, nil
 }
+
+// Runs tells if the process has a role; all of them when none is configured
+func (c *Config) Runs(role string) bool {
+	if len(c.Roles) == 0 {
+		return true
+	}
+
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// seconds as configured into a duration
+func seconds(v float64) time.Duration {
+	return time.Duration(v * float64(time.Second))
+}
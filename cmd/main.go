@@ -7,15 +7,23 @@ import (
 	"net/http"
 
 	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/broker/disk"
 	brokers "github.com/fluxynet/goqa/broker/memory"
+	"github.com/fluxynet/goqa/broker/nats"
 	caches "github.com/fluxynet/goqa/cache/memory"
 	"github.com/fluxynet/goqa/emailer/smtp"
+	"github.com/fluxynet/goqa/forge/github"
+	"github.com/fluxynet/goqa/gate"
 	"github.com/fluxynet/goqa/repo/flat"
 	rosters "github.com/fluxynet/goqa/roster/memory"
 	"github.com/fluxynet/goqa/subscriber/cachew"
 	"github.com/fluxynet/goqa/subscriber/coverage"
+	"github.com/fluxynet/goqa/subscriber/diff"
 	"github.com/fluxynet/goqa/subscriber/email"
+	flakies "github.com/fluxynet/goqa/subscriber/flaky"
 	repos "github.com/fluxynet/goqa/subscriber/repo"
+	"github.com/fluxynet/goqa/subscriber/report"
+	"github.com/fluxynet/goqa/subscriber/slow"
 	"github.com/fluxynet/goqa/web/hook"
 	"github.com/fluxynet/goqa/web/server"
 )
@@ -26,31 +34,78 @@ func main() {
 		log.Fatalln("failed to load config: ", err.Error())
 	}
 
+	var (
+		policy       *gate.Policy
+		brokerPolicy brokers.Policy
+	)
+
+	if policy, err = gate.New(cfg.Gates...); err != nil {
+		log.Fatalln("failed to load gates: ", err.Error())
+	}
+
+	if brokerPolicy, err = brokers.ParsePolicy(cfg.BrokerPolicy); err != nil {
+		log.Fatalln("failed to load broker policy: ", err.Error())
+	}
+
+	var broker goqa.Broker = brokers.New(cfg.BrokerCapacity, brokerPolicy)
+	if cfg.BrokerURL != "" {
+		if broker, err = nats.New(cfg.BrokerURL, cfg.BrokerPrefix); err != nil {
+			log.Fatalln("failed to connect broker: ", err.Error())
+		}
+	} else if cfg.BrokerDir != "" {
+		if broker, err = disk.New(cfg.BrokerDir, 0); err != nil {
+			log.Fatalln("failed to open broker: ", err.Error())
+		}
+	}
+
 	var (
 		repo   = flat.New()
-		broker = brokers.New()
 		cache  = caches.New()
+		prev   = caches.New()
 		roster = rosters.New()
+		local  = roster
+	)
+
+	// events of a group are only told to one of the workers, the cache and server sent events of every web server are
+	// told all of them through a roster of their own
+	if _, ok := broker.(goqa.Grouped); ok {
+		local = rosters.New()
+	}
+
+	var (
 		mailer = smtp.New(cfg.EmailHost, cfg.EmailPort, cfg.EmailUsr, cfg.EmailPass, cfg.EmailFrom)
 
 		hookServer = hook.Hook{
-			Broker: broker,
-			SigKey: cfg.GithubSigKey,
+			Broker:     broker,
+			SigKey:     cfg.GithubSigKey,
+			SigKeys:    cfg.GithubSigKeys,
+			RejectSHA1: cfg.GithubRejectSHA1,
+			Gate:       policy,
+			Cache:      cache,
+			Deliveries: hook.NewDeliveries(cfg.DeliveryCapacity, seconds(cfg.DeliveryTTL)),
+			MaxAge:     seconds(cfg.PayloadMaxAge),
 		}
 
 		webServer = server.Server{
 			Broker:    broker,
 			Cache:     cache,
-			Roster:    roster,
+			Repo:      repo,
+			Roster:    local,
 			IndexHTML: goqa.AssetIndexHtml,
 			Prefix:    "/api/",
+
+			FlakyWindow:    cfg.FlakyWindow,
+			DurationWindow: cfg.DurationWindow,
+			BadgeBands:     cfg.BadgeBands,
 		}
 	)
 
 	var app = App{
 		cfg:        cfg,
 		roster:     roster,
+		local:      local,
 		cache:      cache,
+		previous:   prev,
 		mailer:     mailer,
 		broker:     broker,
 		repo:       repo,
@@ -58,14 +113,25 @@ func main() {
 		webServer:  &webServer,
 	}
 
+	if cfg.GithubToken != "" {
+		app.forge = github.New(cfg.GithubURL, cfg.GithubToken)
+	}
+
 	app.Serve(context.Background())
 }
 
 type App struct {
-	cfg        *Config
-	roster     goqa.Roster
+	cfg    *Config
+	roster goqa.Roster
+
+	// local roster of subscribers told every event e.g. the cache of the web server; the same as roster unless the
+	// broker shares events among a group of workers
+	local goqa.Roster
+
 	cache      goqa.Cache
+	previous   goqa.Cache
 	mailer     goqa.Emailer
+	forge      goqa.Forge
 	broker     goqa.Broker
 	hookServer *hook.Hook
 	repo       goqa.Repo
@@ -73,50 +139,130 @@ type App struct {
 }
 
 func (a *App) Serve(ctx context.Context) {
-	var err error
+	a.subscribe(ctx)
+
+	if a.local == a.roster {
+		go goqa.Attach(a.broker, a.roster)
+	} else {
+		if a.cfg.Runs(RoleWorker) {
+			go goqa.AttachGroup(a.broker, a.roster, a.cfg.BrokerGroup)
+		}
+
+		if a.cfg.Runs(RoleHook) || a.cfg.Runs(RoleWeb) {
+			go goqa.Attach(a.broker, a.local)
+		}
+	}
+
+	if a.cfg.Runs(RoleHook) {
+		http.HandleFunc("/github", a.hookServer.Receive)
+		http.HandleFunc("/upload", a.hookServer.Upload)
+		http.HandleFunc("/gitlab", a.hookServer.Ingest(hook.Gitlab{Token: a.cfg.GitlabToken}))
+		http.HandleFunc("/gitea", a.hookServer.Ingest(hook.Gitea{Secret: a.cfg.GiteaSecret}))
+		http.HandleFunc("/ingest", a.hookServer.Ingest(hook.Generic{Header: a.cfg.IngestHeader, Token: a.cfg.IngestToken}))
+	}
+
+	if a.cfg.Runs(RoleWeb) {
+		http.HandleFunc("/api/sse", a.webServer.SSE)
+		http.HandleFunc("/api/history/", a.webServer.History)
+		http.HandleFunc("/api/commits/", a.webServer.Commit)
+		http.HandleFunc("/api/diff", a.webServer.Diff)
+		http.HandleFunc("/api/tests/", a.webServer.Tests)
+		http.HandleFunc("/api/flaky", a.webServer.Flaky)
+		http.HandleFunc("/api/durations/", a.webServer.Durations)
+		http.HandleFunc("/api/aggregates", a.webServer.Aggregates)
+		http.HandleFunc("/badge/", a.webServer.Badge)
+		http.HandleFunc("/api/", a.webServer.Get) // slash is the difference; not best practice
+		http.HandleFunc("/api", a.webServer.List) // makes life easier :(
+		http.HandleFunc("/", a.webServer.Index)
+	}
+
+	if !a.cfg.Runs(RoleHook) && !a.cfg.Runs(RoleWeb) {
+		fmt.Println("Working without serving")
+		select {}
+	}
 
-	var covs []goqa.Coverage
-	covs, err = a.repo.Load(ctx)
+	fmt.Println("Server starting on: http://" + a.cfg.ServerHost)
+	http.ListenAndServe(a.cfg.ServerHost, nil)
+}
+
+// subscribe those the roles of the process need; the cache is kept by hooks for the gates and by the web server
+func (a *App) subscribe(ctx context.Context) {
+	var covs, err = a.repo.Load(ctx)
 	if err != nil {
 		log.Fatalln("failed to load coverage from file", err.Error())
 	}
 
-	err = a.roster.Subscribe(ctx, goqa.EventGithub, repos.New(a.repo))
+	if a.cfg.Runs(RoleHook) || a.cfg.Runs(RoleWeb) {
+		err = a.cache.Reset(covs...)
+		if err != nil {
+			log.Fatalln("failed to initialize cache", err.Error())
+		}
+
+		err = a.local.Subscribe(ctx, goqa.EventGithub, cachew.New(a.cache))
+		if err != nil {
+			log.Fatalln("failed to subscribe cachew to "+goqa.EventGithub, err.Error())
+		}
+	}
+
+	if a.cfg.Runs(RoleWorker) {
+		a.work(ctx, covs)
+	}
+}
+
+// work by subscribing those doing it e.g. keeping history, reporting to GitHub or emailing
+func (a *App) work(ctx context.Context, covs []goqa.Coverage) {
+	var err = a.roster.Subscribe(ctx, goqa.EventGithub, repos.New(a.repo))
 	if err != nil {
 		log.Fatalln("failed to subscribe repo to "+goqa.EventGithub, err.Error())
 	}
 
-	err = a.cache.Reset(covs...)
+	err = a.previous.Reset(covs...)
 	if err != nil {
-		log.Fatalln("failed to initialize cache", err.Error())
+		log.Fatalln("failed to initialize previous coverage", err.Error())
 	}
 
-	err = a.roster.Subscribe(ctx, goqa.EventGithub, cachew.New(a.cache))
+	err = a.roster.Subscribe(ctx, goqa.EventGithub, coverage.New(a.broker, a.previous))
 	if err != nil {
-		log.Fatalln("failed to subscribe cachew to "+goqa.EventGithub, err.Error())
+		log.Fatalln("failed to subscribe coverage to "+goqa.EventGithub, err.Error())
 	}
 
-	err = a.roster.Subscribe(ctx, goqa.EventGithub, coverage.New(a.broker))
+	err = a.roster.Subscribe(ctx, goqa.EventGithub, flakies.New(a.repo, a.broker, a.cfg.FlakyThreshold, a.cfg.FlakyWindow))
 	if err != nil {
-		log.Fatalln("failed to subscribe coverage to "+goqa.EventGithub, err.Error())
+		log.Fatalln("failed to subscribe flaky to "+goqa.EventGithub, err.Error())
 	}
 
-	for i := range a.cfg.EmailSubscribers {
-		var sub = email.New(a.mailer, a.cfg.EmailSubscribers[i])
-		err = a.roster.Subscribe(ctx, goqa.EventCoverage, sub)
-		if err != nil {
-			log.Fatalln("failed to subscribe to "+goqa.EventCoverage, err.Error())
-		}
+	err = a.roster.Subscribe(ctx, goqa.EventGithub, slow.New(a.repo, a.broker, a.cfg.SlowFactor, a.cfg.SlowMinElapsed, a.cfg.DurationWindow))
+	if err != nil {
+		log.Fatalln("failed to subscribe slow to "+goqa.EventGithub, err.Error())
 	}
 
-	go goqa.Attach(a.broker, a.roster)
+	err = a.roster.Subscribe(ctx, goqa.EventGithub, diff.New(a.repo, a.broker))
+	if err != nil {
+		log.Fatalln("failed to subscribe diff to "+goqa.EventGithub, err.Error())
+	}
+
+	if a.forge != nil {
+		var rep = report.New(a.forge)
+		err = a.roster.Subscribe(ctx, goqa.EventGithub, rep)
+		if err != nil {
+			log.Fatalln("failed to subscribe report to "+goqa.EventGithub, err.Error())
+		}
 
-	http.HandleFunc("/github", a.hookServer.Receive)
-	http.HandleFunc("/api/sse", a.webServer.SSE)
-	http.HandleFunc("/api/", a.webServer.Get) // slash is the difference; not best practice
-	http.HandleFunc("/api", a.webServer.List) // makes life easier :(
-	http.HandleFunc("/", a.webServer.Index)
+		for _, name := range []string{goqa.EventCoverageRegression, goqa.EventCoverageImproved, goqa.EventDiff} {
+			err = a.roster.Subscribe(ctx, name, rep.Clone())
+			if err != nil {
+				log.Fatalln("failed to subscribe report to "+name, err.Error())
+			}
+		}
+	}
 
-	fmt.Println("Server starting on: http://" + a.cfg.ServerHost)
-	http.ListenAndServe(a.cfg.ServerHost, nil)
+	for i := range a.cfg.EmailSubscribers {
+		for _, name := range []string{goqa.EventCoverageRegression, goqa.EventCoverageImproved, goqa.EventGateFailed, goqa.EventFlakyTest, goqa.EventSlowTest, goqa.EventDiff} {
+			var sub = email.New(a.mailer, a.cfg.EmailSubscribers[i])
+			err = a.roster.Subscribe(ctx, name, sub)
+			if err != nil {
+				log.Fatalln("failed to subscribe to "+name, err.Error())
+			}
+		}
+	}
 }
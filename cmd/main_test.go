@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"reflect"
 	"testing"
+
+	"github.com/fluxynet/goqa"
+	caches "github.com/fluxynet/goqa/cache/memory"
+	rosters "github.com/fluxynet/goqa/roster/memory"
 )
 
 func TestLoadConf(t *testing.T) {
@@ -26,3 +31,94 @@ func TestLoadConf(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_Runs(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles []string
+		role  string
+		want  bool
+	}{
+		{name: "all by default", role: RoleWorker, want: true},
+		{name: "configured", roles: []string{RoleHook, RoleWeb}, role: RoleWeb, want: true},
+		{name: "not configured", roles: []string{RoleHook, RoleWeb}, role: RoleWorker, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = Config{Roles: tt.roles}
+			if got := c.Runs(tt.role); got != tt.want {
+				t.Errorf("Runs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakerepo loads coverage, keeping nothing
+type fakerepo struct {
+	covs []goqa.Coverage
+}
+
+func (f fakerepo) Save(ctx context.Context, repository, ref string, covs ...goqa.Coverage) error {
+	return nil
+}
+
+func (f fakerepo) Load(ctx context.Context) ([]goqa.Coverage, error) {
+	return f.covs, nil
+}
+
+func (f fakerepo) Append(ctx context.Context, rec goqa.Record) error {
+	return nil
+}
+
+func (f fakerepo) History(ctx context.Context, q goqa.HistoryQuery) ([]goqa.Record, error) {
+	return nil, nil
+}
+
+func (f fakerepo) Close() error {
+	return nil
+}
+
+func TestApp_subscribe(t *testing.T) {
+	var cov = goqa.Coverage{Repository: "acme/foo", Ref: "refs/heads/master", Pkg: "github.com/acme/foo", Percentage: 50, Precise: 50}
+
+	tests := []struct {
+		name        string
+		roles       []string
+		wantCache   bool
+		wantLocal   int // subscribers of github events on the local roster
+		wantWorkers int // and on the roster shared by workers
+	}{
+		{name: "hook", roles: []string{RoleHook}, wantCache: true, wantLocal: 1},
+		{name: "web", roles: []string{RoleWeb}, wantCache: true, wantLocal: 1},
+		{name: "worker", roles: []string{RoleWorker}, wantWorkers: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a = App{
+				cfg:      &Config{Roles: tt.roles},
+				roster:   rosters.New(),
+				local:    rosters.New(),
+				cache:    caches.New(),
+				previous: caches.New(),
+				repo:     fakerepo{covs: []goqa.Coverage{cov}},
+			}
+
+			a.subscribe(context.Background())
+
+			// gates of hooks evaluate drops against the cache
+			if _, ok := a.cache.Get(cov.Key()); ok != tt.wantCache {
+				t.Errorf("cached = %v, want %v", ok, tt.wantCache)
+			}
+
+			if subs, _ := a.local.Subscribers(context.Background(), goqa.EventGithub); len(subs) != tt.wantLocal {
+				t.Errorf("local subscribers = %d, want %d", len(subs), tt.wantLocal)
+			}
+
+			if subs, _ := a.roster.Subscribers(context.Background(), goqa.EventGithub); len(subs) != tt.wantWorkers {
+				t.Errorf("subscribers of workers = %d, want %d", len(subs), tt.wantWorkers)
+			}
+		})
+	}
+}
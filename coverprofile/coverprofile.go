@@ -0,0 +1,311 @@
+package coverprofile
+
+import (
+	"bufio"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fluxynet/goqa"
+)
+
+const (
+	// ModeSet blocks report whether they ran
+	ModeSet = "set"
+
+	// ModeCount blocks report how many times they ran
+	ModeCount = "count"
+
+	// ModeAtomic is ModeCount, safe for parallel tests
+	ModeAtomic = "atomic"
+)
+
+var (
+	// ErrMode means the profile does not start with a supported mode line
+	ErrMode = errors.New("coverprofile mode is missing or unsupported")
+
+	// ErrLine means a line of the profile cannot be read
+	ErrLine = errors.New("coverprofile line is malformed")
+)
+
+// Profile is the coverage as written by go test -coverprofile
+type Profile struct {
+	Mode string
+
+	// Files sorted by name
+	Files []goqa.FileCoverage
+}
+
+// Parse a coverprofile; blocks reported more than once (e.g. with -coverpkg) are merged
+func Parse(r io.Reader) (*Profile, error) {
+	var (
+		scanner = bufio.NewScanner(r)
+		profile Profile
+		files   = make(map[string]map[goqa.Block]int) // file => position => count
+	)
+
+	scanner.Buffer(nil, 1024*1024)
+
+	for scanner.Scan() {
+		var line = strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if profile.Mode == "" {
+			var mode = strings.TrimPrefix(line, "mode: ")
+			if mode == line || (mode != ModeSet && mode != ModeCount && mode != ModeAtomic) {
+				return nil, ErrMode
+			}
+
+			profile.Mode = mode
+			continue
+		}
+
+		var name, block, err = parseLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		var blocks, ok = files[name]
+		if !ok {
+			blocks = make(map[goqa.Block]int)
+			files[name] = blocks
+		}
+
+		var count = block.Count
+		block.Count = 0 // blocks are keyed by position
+
+		var prev, seen = blocks[block]
+		switch {
+		case !seen, profile.Mode != ModeSet:
+			blocks[block] = prev + count
+		case count > prev:
+			blocks[block] = count
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if profile.Mode == "" {
+		return nil, ErrMode
+	}
+
+	for name, blocks := range files {
+		var f = goqa.FileCoverage{Pkg: path.Dir(name), File: name}
+
+		for b, count := range blocks {
+			b.Count = count
+			f.Blocks = append(f.Blocks, b)
+			f.Statements += b.Statements
+			if count > 0 {
+				f.Covered += b.Statements
+			}
+		}
+
+		sort.Slice(f.Blocks, func(i, j int) bool {
+			var a, b = f.Blocks[i], f.Blocks[j]
+			if a.StartLine != b.StartLine {
+				return a.StartLine < b.StartLine
+			}
+
+			return a.StartCol < b.StartCol
+		})
+
+		profile.Files = append(profile.Files, f)
+	}
+
+	sort.Slice(profile.Files, func(i, j int) bool {
+		return profile.Files[i].File < profile.Files[j].File
+	})
+
+	return &profile, nil
+}
+
+// parseLine of the form name.go:line.column,line.column numberOfStatements count
+func parseLine(line string) (string, goqa.Block, error) {
+	var (
+		block goqa.Block
+		colon = strings.LastIndex(line, ":")
+	)
+
+	if colon == -1 {
+		return "", block, ErrLine
+	}
+
+	var fields = strings.FieldsFunc(line[colon+1:], func(r rune) bool {
+		return r == '.' || r == ',' || r == ' '
+	})
+
+	if len(fields) != 6 {
+		return "", block, ErrLine
+	}
+
+	var values [6]int
+	for i := range fields {
+		var v, err = strconv.Atoi(fields[i])
+		if err != nil || v < 0 {
+			return "", block, ErrLine
+		}
+
+		values[i] = v
+	}
+
+	// lines are numbered from 1 and blocks do not end before they start
+	if values[0] < 1 || values[2] < values[0] {
+		return "", block, ErrLine
+	}
+
+	block = goqa.Block{
+		StartLine:  values[0],
+		StartCol:   values[1],
+		EndLine:    values[2],
+		EndCol:     values[3],
+		Statements: values[4],
+		Count:      values[5],
+	}
+
+	return line[:colon], block, nil
+}
+
+// Coverage of every package in the profile, sorted by package, measured at the given time
+func (p Profile) Coverage(repository, ref, time string) []goqa.Coverage {
+	var (
+		pkgs     []string
+		total    = make(map[string]int)
+		covered  = make(map[string]int)
+		coverage []goqa.Coverage
+	)
+
+	for _, f := range p.Files {
+		if _, ok := total[f.Pkg]; !ok {
+			pkgs = append(pkgs, f.Pkg)
+		}
+
+		total[f.Pkg] += f.Statements
+		covered[f.Pkg] += f.Covered
+	}
+
+	sort.Strings(pkgs)
+
+	for _, pkg := range pkgs {
+		var c = goqa.Coverage{Repository: repository, Ref: ref, Pkg: pkg, Statements: total[pkg], Covered: covered[pkg], Time: time}
+		c.Precise = goqa.Percentage(covered[pkg], total[pkg])
+		c.Percentage = int(c.Precise)
+
+		coverage = append(coverage, c)
+	}
+
+	return coverage
+}
+
+// Annotate files with their source and functions; sources are keyed by the file name as found in the profile
+// and files without a source are left as is
+func (p *Profile) Annotate(sources map[string][]byte) error {
+	for i := range p.Files {
+		var src, ok = sources[p.Files[i].File]
+		if !ok {
+			continue
+		}
+
+		var funcs, err = Funcs(p.Files[i], src)
+		if err != nil {
+			return err
+		}
+
+		p.Files[i].Funcs = funcs
+		p.Files[i].Source = string(src)
+	}
+
+	return nil
+}
+
+// Funcs of a file, in order of appearance, along with their coverage; like go tool cover -func
+func Funcs(file goqa.FileCoverage, src []byte) ([]goqa.FuncCoverage, error) {
+	var (
+		fset        = token.NewFileSet()
+		parsed, err = parser.ParseFile(fset, file.File, src, 0)
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var funcs []goqa.FuncCoverage
+
+	for _, decl := range parsed.Decls {
+		var fn, ok = decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+
+		var (
+			start = fset.Position(fn.Pos())
+			end   = fset.Position(fn.End())
+			f     = goqa.FuncCoverage{Name: funcName(fn), StartLine: start.Line, EndLine: end.Line}
+		)
+
+		for _, b := range file.Blocks {
+			if before(b.StartLine, b.StartCol, start.Line, start.Column) || before(end.Line, end.Column, b.EndLine, b.EndCol) {
+				continue
+			}
+
+			f.Statements += b.Statements
+			if b.Count > 0 {
+				f.Covered += b.Statements
+			}
+		}
+
+		funcs = append(funcs, f)
+	}
+
+	return funcs, nil
+}
+
+// before tells if position a is before position b
+func before(aLine, aCol, bLine, bCol int) bool {
+	return aLine < bLine || (aLine == bLine && aCol < bCol)
+}
+
+// funcName of a declaration, prefixed by its receiver type for methods
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+
+	var (
+		typ = fn.Recv.List[0].Type
+		ptr bool
+	)
+
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ, ptr = star.X, true
+	}
+
+	// generic receivers e.g. T[K]
+	switch t := typ.(type) {
+	case *ast.IndexExpr:
+		typ = t.X
+	case *ast.IndexListExpr:
+		typ = t.X
+	}
+
+	var name string
+	if ident, ok := typ.(*ast.Ident); ok {
+		name = ident.Name
+	}
+
+	if ptr {
+		return "(*" + name + ")." + fn.Name.Name
+	}
+
+	return name + "." + fn.Name.Name
+}
@@ -0,0 +1,241 @@
+package coverprofile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fluxynet/goqa"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		want    *Profile
+		wantErr error
+	}{
+		{
+			name:    "empty",
+			profile: "",
+			wantErr: ErrMode,
+		},
+		{
+			name:    "no mode",
+			profile: "github.com/acme/foo/foo.go:3.10,5.2 1 1\n",
+			wantErr: ErrMode,
+		},
+		{
+			name:    "unsupported mode",
+			profile: "mode: often\n",
+			wantErr: ErrMode,
+		},
+		{
+			name:    "malformed line",
+			profile: "mode: set\ngithub.com/acme/foo/foo.go:3.10,5.2 1\n",
+			wantErr: ErrLine,
+		},
+		{
+			name:    "malformed number",
+			profile: "mode: set\ngithub.com/acme/foo/foo.go:3.10,5.x 1 1\n",
+			wantErr: ErrLine,
+		},
+		{
+			name:    "line zero",
+			profile: "mode: set\ngithub.com/acme/foo/foo.go:0.1,0.5 1 1\n",
+			wantErr: ErrLine,
+		},
+		{
+			name:    "ending before starting",
+			profile: "mode: set\ngithub.com/acme/foo/foo.go:5.1,3.5 1 1\n",
+			wantErr: ErrLine,
+		},
+		{
+			name:    "mode only",
+			profile: "mode: set\n",
+			want:    &Profile{Mode: ModeSet},
+		},
+		{
+			name: "set",
+			profile: "mode: set\n" +
+				"github.com/acme/foo/foo.go:7.2,9.3 2 0\n" +
+				"github.com/acme/foo/foo.go:3.10,5.2 1 1\n" +
+				"github.com/acme/foo/bar/bar.go:3.10,4.2 3 0\n" +
+				"github.com/acme/foo/foo.go:7.2,9.3 2 1\n", // same block, e.g. with -coverpkg
+			want: &Profile{
+				Mode: ModeSet,
+				Files: []goqa.FileCoverage{
+					{
+						Pkg:        "github.com/acme/foo/bar",
+						File:       "github.com/acme/foo/bar/bar.go",
+						Statements: 3,
+						Blocks:     []goqa.Block{{StartLine: 3, StartCol: 10, EndLine: 4, EndCol: 2, Statements: 3}},
+					},
+					{
+						Pkg:        "github.com/acme/foo",
+						File:       "github.com/acme/foo/foo.go",
+						Statements: 3,
+						Covered:    3,
+						Blocks: []goqa.Block{
+							{StartLine: 3, StartCol: 10, EndLine: 5, EndCol: 2, Statements: 1, Count: 1},
+							{StartLine: 7, StartCol: 2, EndLine: 9, EndCol: 3, Statements: 2, Count: 1},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "count",
+			profile: "mode: count\n" +
+				"github.com/acme/foo/foo.go:3.10,5.2 1 4\n" +
+				"github.com/acme/foo/foo.go:3.10,5.2 1 2\n" +
+				"github.com/acme/foo/foo.go:7.2,9.3 2 0\n",
+			want: &Profile{
+				Mode: ModeCount,
+				Files: []goqa.FileCoverage{
+					{
+						Pkg:        "github.com/acme/foo",
+						File:       "github.com/acme/foo/foo.go",
+						Statements: 3,
+						Covered:    1,
+						Blocks: []goqa.Block{
+							{StartLine: 3, StartCol: 10, EndLine: 5, EndCol: 2, Statements: 1, Count: 6},
+							{StartLine: 7, StartCol: 2, EndLine: 9, EndCol: 3, Statements: 2},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, err = Parse(strings.NewReader(tt.profile))
+			if err != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse()\nwant = %+v\ngot  = %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProfile_Coverage(t *testing.T) {
+	var p = Profile{
+		Mode: ModeSet,
+		Files: []goqa.FileCoverage{
+			{Pkg: "github.com/acme/foo", File: "github.com/acme/foo/foo.go", Statements: 2, Covered: 1},
+			{Pkg: "github.com/acme/foo/bar", File: "github.com/acme/foo/bar/bar.go", Statements: 3},
+			{Pkg: "github.com/acme/foo", File: "github.com/acme/foo/baz.go", Statements: 1, Covered: 1},
+			{Pkg: "github.com/acme/foo/empty", File: "github.com/acme/foo/empty/empty.go"},
+		},
+	}
+
+	var want = []goqa.Coverage{
+		{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo", Percentage: 66, Precise: 66.7, Statements: 3, Covered: 2, Time: "now"},
+		{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo/bar", Percentage: 0, Statements: 3, Time: "now"},
+		{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo/empty", Percentage: 0, Time: "now"},
+	}
+
+	if got := p.Coverage("acme/foo", "master", "now"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Coverage()\nwant = %v\ngot  = %v", want, got)
+	}
+}
+
+const source = `package foo
+
+type T struct{}
+
+func Sum(a, b int) int {
+	return a + b
+}
+
+func (t *T) Half(a int) int {
+	if a == 0 {
+		return 0
+	}
+
+	return a / 2
+}
+
+func (t T) Noop() {}
+`
+
+func TestFuncs(t *testing.T) {
+	var file = goqa.FileCoverage{
+		File: "github.com/acme/foo/foo.go",
+		Blocks: []goqa.Block{
+			{StartLine: 5, StartCol: 24, EndLine: 7, EndCol: 2, Statements: 1, Count: 1},
+			{StartLine: 9, StartCol: 29, EndLine: 10, EndCol: 12, Statements: 1, Count: 1},
+			{StartLine: 10, StartCol: 12, EndLine: 12, EndCol: 3, Statements: 1, Count: 0},
+			{StartLine: 14, StartCol: 2, EndLine: 14, EndCol: 14, Statements: 1, Count: 1},
+			{StartLine: 17, StartCol: 19, EndLine: 17, EndCol: 20, Statements: 0, Count: 0},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		src     string
+		want    []goqa.FuncCoverage
+		wantErr bool
+	}{
+		{
+			name:    "not go",
+			src:     "coverage: 50% of statements",
+			wantErr: true,
+		},
+		{
+			name: "funcs",
+			src:  source,
+			want: []goqa.FuncCoverage{
+				{Name: "Sum", StartLine: 5, EndLine: 7, Statements: 1, Covered: 1},
+				{Name: "(*T).Half", StartLine: 9, EndLine: 15, Statements: 3, Covered: 2},
+				{Name: "T.Noop", StartLine: 17, EndLine: 17},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, err = Funcs(file, []byte(tt.src))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Funcs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Funcs()\nwant = %+v\ngot  = %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProfile_Annotate(t *testing.T) {
+	var p = Profile{
+		Mode: ModeSet,
+		Files: []goqa.FileCoverage{
+			{Pkg: "github.com/acme/foo", File: "github.com/acme/foo/foo.go"},
+			{Pkg: "github.com/acme/foo", File: "github.com/acme/foo/bar.go"},
+		},
+	}
+
+	if err := p.Annotate(map[string][]byte{"github.com/acme/foo/foo.go": []byte(source)}); err != nil {
+		t.Errorf("Annotate() error = %v", err)
+		return
+	}
+
+	if len(p.Files[0].Funcs) != 3 || p.Files[0].Source != source {
+		t.Errorf("Annotate() foo.go want funcs and source, got = %+v", p.Files[0])
+	}
+
+	if p.Files[1].Funcs != nil || p.Files[1].Source != "" {
+		t.Errorf("Annotate() bar.go want neither funcs nor source, got = %+v", p.Files[1])
+	}
+
+	if err := p.Annotate(map[string][]byte{"github.com/acme/foo/bar.go": []byte("package")}); err == nil {
+		t.Errorf("Annotate() want error on malformed source")
+	}
+}
@@ -0,0 +1,85 @@
+package goqa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrUnknownEvent means an event cannot be decoded as its name is unknown
+var ErrUnknownEvent = errors.New("event is unknown")
+
+var (
+	registryMut sync.RWMutex
+
+	// registry of the types of events by their name
+	registry = map[string]reflect.Type{
+		EventGithub:             reflect.TypeOf(&GithubEvent{}), // published as a pointer by web hooks
+		EventCoverage:           reflect.TypeOf(CoverageEvent{}),
+		EventCoverageRegression: reflect.TypeOf(CoverageDeltaEvent{}),
+		EventCoverageImproved:   reflect.TypeOf(CoverageDeltaEvent{}),
+		EventGateFailed:         reflect.TypeOf(GateFailedEvent{}),
+		EventFlakyTest:          reflect.TypeOf(FlakyTestEvent{}),
+		EventSlowTest:           reflect.TypeOf(SlowTestEvent{}),
+		EventAggregate:          reflect.TypeOf(AggregateEvent{}),
+		EventDiff:               reflect.TypeOf(DiffEvent{}),
+	}
+)
+
+// Register the type of the events of a name by an example e.g. Register(EventCoverage, CoverageEvent{}), so that
+// they can be decoded; they are decoded as pointers when the example is one
+func Register(name string, example Event) {
+	registryMut.Lock()
+	defer registryMut.Unlock()
+
+	registry[name] = reflect.TypeOf(example)
+}
+
+// Decode an event encoded in json by its name, as brokers outside the process do; an Envelope is told apart
+// by its id and payload
+func Decode(name string, b []byte) (Event, error) {
+	var sealed struct {
+		ID      string          `json:"id"`
+		Payload json.RawMessage `json:"payload"`
+	}
+
+	if json.Unmarshal(b, &sealed) == nil && sealed.ID != "" && len(sealed.Payload) != 0 {
+		var e Envelope
+		if err := json.Unmarshal(b, &e); err != nil {
+			return nil, err
+		}
+
+		return e, nil
+	}
+
+	return decode(name, b)
+}
+
+// decode an event of a registered type
+func decode(name string, b []byte) (Event, error) {
+	registryMut.RLock()
+	var typ, ok = registry[name]
+	registryMut.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEvent, name)
+	}
+
+	var ptr = typ.Kind() == reflect.Ptr
+	if ptr {
+		typ = typ.Elem()
+	}
+
+	var v = reflect.New(typ)
+	if err := json.Unmarshal(b, v.Interface()); err != nil {
+		return nil, err
+	}
+
+	if ptr {
+		return v.Interface().(Event), nil
+	}
+
+	return v.Elem().Interface().(Event), nil
+}
@@ -0,0 +1,99 @@
+package goqa
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+	}{
+		{name: "github", event: &GithubEvent{Repository: "acme/foo", Coverage: []Coverage{{Pkg: "foo", Precise: 50.5}}}},
+		{name: "coverage", event: CoverageEvent{Repository: "acme/foo", Pkg: "foo", Precise: 50.5}},
+		{name: "regression", event: CoverageDeltaEvent{Pkg: "foo", Old: 60, New: 50}},
+		{name: "improved", event: CoverageDeltaEvent{Pkg: "foo", Old: 50, New: 60}},
+		{name: "gate", event: GateFailedEvent{Repository: "acme/foo", Failures: []GateCheck{{Pkg: "foo", Reason: "low"}}}},
+		{name: "flaky", event: FlakyTestEvent{Pkg: "foo", Test: "TestFoo", Score: 0.5}},
+		{name: "slow", event: SlowTestEvent{Pkg: "foo", Test: "TestFoo", Factor: 3}},
+		{name: "aggregate", event: AggregateEvent{Repository: "acme/foo", Precise: 50.5}},
+		{name: "diff", event: DiffEvent{Repository: "acme/foo", Untested: []string{"foo"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b, _ = json.Marshal(tt.event)
+
+			got, err := Decode(tt.event.Name(), b)
+			if err != nil {
+				t.Errorf("Decode() error = %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.event) {
+				t.Errorf("Decode()\nwant = %#v\ngot  = %#v", tt.event, got)
+			}
+		})
+	}
+
+	if _, err := Decode("EVENT_UNKNOWN", []byte(`{}`)); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Decode() error = %v, want %v", err, ErrUnknownEvent)
+	}
+}
+
+func TestDecode_Envelope(t *testing.T) {
+	var e = Seal("hook", CoverageEvent{Repository: "acme/foo", Pkg: "foo"})
+	e.Time = e.Time.Round(0) // without monotonic clock, as decoded
+
+	var b, _ = json.Marshal(e)
+
+	got, err := Decode(EventCoverage, b)
+	if err != nil {
+		t.Errorf("Decode() error = %v", err)
+		return
+	}
+
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("Decode()\nwant = %#v\ngot  = %#v", e, got)
+	}
+}
+
+// registered is an event decoded once registered
+type registered struct {
+	Value string `json:"value"`
+}
+
+func (r *registered) Name() string {
+	return "EVENT_REGISTERED"
+}
+
+func (r *registered) String() string {
+	return r.Value
+}
+
+func TestRegister(t *testing.T) {
+	if _, err := Decode("EVENT_REGISTERED", []byte(`{"value":"foo"}`)); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Decode() error = %v, want %v", err, ErrUnknownEvent)
+	}
+
+	Register("EVENT_REGISTERED", &registered{})
+
+	defer func() {
+		registryMut.Lock()
+		delete(registry, "EVENT_REGISTERED")
+		registryMut.Unlock()
+	}()
+
+	got, err := Decode("EVENT_REGISTERED", []byte(`{"value":"foo"}`))
+	if err != nil {
+		t.Errorf("Decode() error = %v", err)
+		return
+	}
+
+	if want := (&registered{Value: "foo"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode()\nwant = %#v\ngot  = %#v", want, got)
+	}
+}
@@ -0,0 +1,246 @@
+package goqa
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Diff is the coverage of the head of a pull request compared to its base
+type Diff struct {
+	Repository string `json:"repository"`
+	Ref        string `json:"ref"`
+
+	// Head ref (branch) of the pull request
+	Head string `json:"head"`
+
+	// Base commit the pull request is compared to
+	Base string `json:"base"`
+
+	// Commit at the head of the pull request
+	Commit string `json:"commit"`
+	Time   string `json:"time"`
+
+	// Packages whose coverage changed, which appeared or disappeared or whose files were changed; sorted by name
+	Packages []PackageDiff `json:"packages"`
+
+	// Untested are new packages without tests of their own and nothing covered
+	Untested []string `json:"untested"`
+
+	// Patch coverage of the lines changed; nil when changes or files of the head are unknown
+	Patch *PatchCoverage `json:"patch"`
+}
+
+// PackageDiff is the coverage of a package at the head of a pull request compared to its base
+type PackageDiff struct {
+	Pkg  string  `json:"pkg"`
+	Base float64 `json:"base"`
+	Head float64 `json:"head"`
+
+	// Delta between head and base, to the first decimal
+	Delta float64 `json:"delta"`
+
+	// Added means the package is not at the base
+	Added bool `json:"added"`
+
+	// Removed means the package is not at the head
+	Removed bool `json:"removed"`
+
+	// Changed means files of the package were changed
+	Changed bool `json:"changed"`
+}
+
+// PatchCoverage is the coverage of the lines changed by a pull request, only counting those with statements
+type PatchCoverage struct {
+	Lines      int         `json:"lines"`
+	Covered    int         `json:"covered"`
+	Percentage float64     `json:"percentage"`
+	Files      []PatchFile `json:"files"`
+}
+
+// PatchFile is the coverage of the lines changed in a file
+type PatchFile struct {
+	File      string `json:"file"`
+	Lines     int    `json:"lines"`
+	Covered   int    `json:"covered"`
+	Uncovered []int  `json:"uncovered"`
+}
+
+// module import path of files named relative to the repository in changes: the one under which the most files are named
+// so, the shortest on a tie as nested directories of a module share its file names
+func module(changes map[string][]int, files []FileCoverage) string {
+	var votes = make(map[string]int)
+	for _, f := range files {
+		for name := range changes {
+			if strings.HasSuffix(f.File, "/"+name) {
+				votes[strings.TrimSuffix(f.File, "/"+name)]++
+			}
+		}
+	}
+
+	var (
+		mod  string
+		most int
+	)
+
+	for p, n := range votes {
+		if n > most || (n == most && (len(p) < len(mod) || (len(p) == len(mod) && p < mod))) {
+			mod, most = p, n
+		}
+	}
+
+	return mod
+}
+
+// changed lines of a file, named after its package, out of changes named relative to the repository of module mod;
+// files of other modules of the repository get the lines of the longest name they end with
+func changed(changes map[string][]int, mod, file string) []int {
+	if lines, ok := changes[file]; ok {
+		return lines
+	}
+
+	if mod != "" && strings.HasPrefix(file, mod+"/") {
+		return changes[strings.TrimPrefix(file, mod+"/")]
+	}
+
+	var (
+		longest string
+		lines   []int
+	)
+
+	for name := range changes {
+		if strings.HasSuffix(file, "/"+name) && len(name) > len(longest) {
+			longest, lines = name, changes[name]
+		}
+	}
+
+	return lines
+}
+
+// patch coverage of a file; a line partly covered by another block is considered covered
+func patch(f FileCoverage, lines []int) PatchFile {
+	var p = PatchFile{File: f.File, Uncovered: []int{}}
+
+	for _, l := range lines {
+		var instrumented, covered bool
+		for _, b := range f.Blocks {
+			if l >= b.StartLine && l <= b.EndLine {
+				instrumented = true
+				covered = covered || b.Count > 0
+			}
+		}
+
+		if !instrumented {
+			continue
+		}
+
+		p.Lines++
+		if covered {
+			p.Covered++
+		} else {
+			p.Uncovered = append(p.Uncovered, l)
+		}
+	}
+
+	return p
+}
+
+// Compare the head record of a pull request to that of its base
+func Compare(base, head Record) Diff {
+	var d = Diff{
+		Repository: head.Repository,
+		Ref:        head.Ref,
+		Head:       head.Head,
+		Base:       base.Commit,
+		Commit:     head.Commit,
+		Time:       head.Time,
+		Packages:   []PackageDiff{},
+		Untested:   []string{},
+	}
+
+	var (
+		before  = make(map[string]Coverage)
+		after   = make(map[string]Coverage)
+		touched = make(map[string]bool)
+		tested  = make(map[string]bool)
+		seen    = make(map[string]bool)
+		pkgs    []string
+	)
+
+	var add = func(pkg string) {
+		if !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	for _, c := range base.Coverage {
+		add(c.Pkg)
+		before[c.Pkg] = c
+	}
+
+	for _, c := range head.Coverage {
+		add(c.Pkg)
+		after[c.Pkg] = c
+	}
+
+	for _, t := range head.Tests {
+		tested[t.Pkg] = true
+	}
+
+	if len(head.Changes) != 0 && len(head.Files) != 0 {
+		d.Patch = &PatchCoverage{Files: []PatchFile{}}
+
+		var mod = module(head.Changes, head.Files)
+		for _, f := range head.Files {
+			var lines = changed(head.Changes, mod, f.File)
+			if lines == nil {
+				continue
+			}
+
+			add(f.Pkg)
+			touched[f.Pkg] = true
+
+			var p = patch(f, lines)
+			d.Patch.Lines += p.Lines
+			d.Patch.Covered += p.Covered
+			d.Patch.Files = append(d.Patch.Files, p)
+		}
+
+		d.Patch.Percentage = Percentage(d.Patch.Covered, d.Patch.Lines)
+
+		sort.Slice(d.Patch.Files, func(i, j int) bool {
+			return d.Patch.Files[i].File < d.Patch.Files[j].File
+		})
+	}
+
+	sort.Strings(pkgs)
+
+	for _, pkg := range pkgs {
+		var (
+			b, inBase = before[pkg]
+			h, inHead = after[pkg]
+			p         = PackageDiff{
+				Pkg:     pkg,
+				Base:    b.Value(),
+				Head:    h.Value(),
+				Delta:   math.Round((h.Value()-b.Value())*10) / 10,
+				Added:   inHead && !inBase,
+				Removed: inBase && !inHead,
+				Changed: touched[pkg],
+			}
+		)
+
+		if p.Delta == 0 && !p.Added && !p.Removed && !p.Changed {
+			continue
+		}
+
+		d.Packages = append(d.Packages, p)
+
+		if p.Added && !tested[pkg] && h.Covered == 0 && h.Value() == 0 {
+			d.Untested = append(d.Untested, pkg)
+		}
+	}
+
+	return d
+}
@@ -0,0 +1,172 @@
+package goqa
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	var base = Record{
+		Repository: "acme/foo",
+		Commit:     "b1",
+		Ref:        "refs/heads/master",
+		Coverage: []Coverage{
+			{Pkg: "github.com/acme/foo", Percentage: 80, Precise: 80.5},
+			{Pkg: "github.com/acme/foo/bar", Percentage: 50, Precise: 50},
+			{Pkg: "github.com/acme/foo/old", Percentage: 10, Precise: 10},
+		},
+	}
+
+	var head = Record{
+		Repository: "acme/foo",
+		Commit:     "h1",
+		Ref:        "refs/pull/1/merge",
+		Head:       "feature",
+		Base:       "b1",
+		Time:       "t",
+		Coverage: []Coverage{
+			{Pkg: "github.com/acme/foo", Percentage: 79, Precise: 79.6},
+			{Pkg: "github.com/acme/foo/bar", Percentage: 50, Precise: 50},
+			{Pkg: "github.com/acme/foo/tested", Percentage: 0, Precise: 0},
+			{Pkg: "github.com/acme/foo/untested", Percentage: 0, Precise: 0},
+		},
+		Tests: []TestResult{{Pkg: "github.com/acme/foo/tested", Test: "TestFoo", Status: TestFail}},
+	}
+
+	var files = []FileCoverage{
+		{Pkg: "github.com/acme/foo", File: "github.com/acme/foo/foo.go", Blocks: []Block{
+			{StartLine: 3, EndLine: 5, Statements: 1, Count: 1},
+			{StartLine: 7, EndLine: 9, Statements: 2, Count: 0},
+		}},
+		{Pkg: "github.com/acme/foo/bar", File: "github.com/acme/foo/bar/bar.go", Blocks: []Block{
+			{StartLine: 3, EndLine: 5, Statements: 1, Count: 0},
+		}},
+	}
+
+	var withPatch = head
+	withPatch.Files = files
+	withPatch.Changes = map[string][]int{
+		"foo.go":     {1, 4, 8},
+		"bar/bar.go": {10},
+		"README.md":  {1},
+	}
+
+	var packages = []PackageDiff{
+		{Pkg: "github.com/acme/foo", Base: 80.5, Head: 79.6, Delta: -0.9},
+		{Pkg: "github.com/acme/foo/old", Base: 10, Delta: -10, Removed: true},
+		{Pkg: "github.com/acme/foo/tested", Added: true},
+		{Pkg: "github.com/acme/foo/untested", Added: true},
+	}
+
+	tests := []struct {
+		name string
+		head Record
+		want Diff
+	}{
+		{
+			name: "without changes",
+			head: head,
+			want: Diff{
+				Repository: "acme/foo",
+				Ref:        "refs/pull/1/merge",
+				Head:       "feature",
+				Base:       "b1",
+				Commit:     "h1",
+				Time:       "t",
+				Packages:   packages,
+				Untested:   []string{"github.com/acme/foo/untested"},
+			},
+		},
+		{
+			name: "with changes",
+			head: withPatch,
+			want: Diff{
+				Repository: "acme/foo",
+				Ref:        "refs/pull/1/merge",
+				Head:       "feature",
+				Base:       "b1",
+				Commit:     "h1",
+				Time:       "t",
+				Packages: []PackageDiff{
+					{Pkg: "github.com/acme/foo", Base: 80.5, Head: 79.6, Delta: -0.9, Changed: true},
+					{Pkg: "github.com/acme/foo/bar", Base: 50, Head: 50, Changed: true},
+					packages[1],
+					packages[2],
+					packages[3],
+				},
+				Untested: []string{"github.com/acme/foo/untested"},
+				Patch: &PatchCoverage{
+					Lines:      2,
+					Covered:    1,
+					Percentage: 50,
+					Files: []PatchFile{
+						{File: "github.com/acme/foo/bar/bar.go", Uncovered: []int{}},
+						{File: "github.com/acme/foo/foo.go", Lines: 2, Covered: 1, Uncovered: []int{8}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Compare(base, tt.head); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare()\nwant = %+v\ngot  = %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestChanged(t *testing.T) {
+	var files = []FileCoverage{
+		{File: "github.com/acme/x/util.go"},
+		{File: "github.com/acme/x/sub/util.go"},
+		{File: "github.com/acme/x/sub/other.go"},
+	}
+
+	tests := []struct {
+		name    string
+		changes map[string][]int
+		want    map[string][]int // by file
+	}{
+		{
+			name:    "both changed",
+			changes: map[string][]int{"util.go": {1}, "sub/util.go": {2}},
+			want:    map[string][]int{"github.com/acme/x/util.go": {1}, "github.com/acme/x/sub/util.go": {2}},
+		},
+		{
+			name:    "root changed",
+			changes: map[string][]int{"util.go": {1}},
+			want:    map[string][]int{"github.com/acme/x/util.go": {1}},
+		},
+		{
+			name:    "nested changed",
+			changes: map[string][]int{"sub/util.go": {2}},
+			want:    map[string][]int{"github.com/acme/x/sub/util.go": {2}},
+		},
+		{
+			name:    "other module",
+			changes: map[string][]int{"util.go": {1}, "sub/util.go": {2}, "tools/gen/main.go": {3}, "gen/main.go": {4}},
+			want:    map[string][]int{"github.com/acme/x/util.go": {1}, "github.com/acme/x/sub/util.go": {2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// several times, maps are iterated in no particular order
+			for i := 0; i < 20; i++ {
+				var mod = module(tt.changes, files)
+				for _, f := range files {
+					if got := changed(tt.changes, mod, f.File); !reflect.DeepEqual(got, tt.want[f.File]) {
+						t.Fatalf("changed(%s) = %v, want %v", f.File, got, tt.want[f.File])
+					}
+				}
+			}
+		})
+	}
+
+	var tools = FileCoverage{File: "github.com/acme/tools/gen/main.go"}
+	if got := changed(map[string][]int{"tools/gen/main.go": {3}, "gen/main.go": {4}}, "github.com/acme/x", tools.File); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("changed(%s) = %v, want [3]", tools.File, got)
+	}
+}
@@ -0,0 +1,126 @@
+package duration
+
+import (
+	"math"
+	"sort"
+
+	"github.com/fluxynet/goqa"
+)
+
+const (
+	// DefaultWindow is the number of latest runs considered per test
+	DefaultWindow = 20
+
+	// DefaultFactor by which a duration must exceed its usual (p50) duration to be a regression
+	DefaultFactor = 2.0
+
+	// DefaultMinElapsed in seconds under which durations are too small to be worth an alert
+	DefaultMinElapsed = 0.1
+)
+
+// Point is a duration at a commit
+type Point struct {
+	Commit  string  `json:"commit"`
+	Time    string  `json:"time"`
+	Elapsed float64 `json:"elapsed"`
+}
+
+// Trend of durations of a test, or of a whole package when Test is empty
+//
+// Only passed runs are considered: failures may be cut short or time out, and skips take no time.
+type Trend struct {
+	Repository string  `json:"repository"`
+	Ref        string  `json:"ref"`
+	Pkg        string  `json:"pkg"`
+	Test       string  `json:"test"`
+	Runs       int     `json:"runs"`
+	P50        float64 `json:"p50"`
+	P95        float64 `json:"p95"`
+	Latest     float64 `json:"latest"`
+	Points     []Point `json:"points"`
+}
+
+// Percentile p (0-100) of values using linear interpolation between closest ranks; values need not be sorted
+func Percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var sorted = make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	var (
+		rank = p / 100 * float64(len(sorted)-1)
+		lo   = int(math.Floor(rank))
+		hi   = int(math.Ceil(rank))
+	)
+
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
+
+// Analyze records, oldest first, considering the latest window passed runs of every test and package
+func Analyze(recs []goqa.Record, window int) []Trend {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+
+	type key struct {
+		repository, ref, pkg, test string
+	}
+
+	var (
+		points = make(map[key][]Point)
+		order  []key
+		add    = func(k key, p Point) {
+			if _, ok := points[k]; !ok {
+				order = append(order, k)
+			}
+
+			points[k] = append(points[k], p)
+		}
+	)
+
+	for i := range recs {
+		var rec = recs[i]
+
+		for _, r := range rec.Packages {
+			if r.Status == goqa.TestPass {
+				add(key{rec.Repository, rec.Ref, r.Pkg, ""}, Point{Commit: rec.Commit, Time: rec.Time, Elapsed: r.Elapsed})
+			}
+		}
+
+		for _, r := range rec.Tests {
+			if r.Status == goqa.TestPass {
+				add(key{rec.Repository, rec.Ref, r.Pkg, r.Test}, Point{Commit: rec.Commit, Time: rec.Time, Elapsed: r.Elapsed})
+			}
+		}
+	}
+
+	var trends = make([]Trend, len(order))
+
+	for i, k := range order {
+		var (
+			t   = Trend{Repository: k.repository, Ref: k.ref, Pkg: k.pkg, Test: k.test}
+			pts = points[k]
+		)
+		if len(pts) > window {
+			pts = pts[len(pts)-window:]
+		}
+
+		var values = make([]float64, len(pts))
+		for j := range pts {
+			values[j] = pts[j].Elapsed
+		}
+
+		t.Runs = len(pts)
+		t.P50 = Percentile(values, 50)
+		t.P95 = Percentile(values, 95)
+		t.Latest = values[len(values)-1]
+		t.Points = pts
+
+		trends[i] = t
+	}
+
+	return trends
+}
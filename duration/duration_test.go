@@ -0,0 +1,102 @@
+package duration
+
+import (
+	"testing"
+
+	"github.com/fluxynet/goqa"
+)
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{name: "empty", values: nil, p: 50, want: 0},
+		{name: "single", values: []float64{3}, p: 95, want: 3},
+		{name: "median odd", values: []float64{5, 1, 3}, p: 50, want: 3},
+		{name: "median even", values: []float64{4, 1, 3, 2}, p: 50, want: 2.5},
+		{name: "p95", values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21}, p: 95, want: 20},
+		{name: "max", values: []float64{1, 9, 5}, p: 100, want: 9},
+		{name: "min", values: []float64{1, 9, 5}, p: 0, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Percentile(tt.values, tt.p); got != tt.want {
+				t.Errorf("Percentile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	var recs []goqa.Record
+	for i, elapsed := range []float64{1, 2, 3, 10} {
+		var status = goqa.TestPass
+		if i == 2 {
+			status = goqa.TestFail // not considered
+		}
+
+		recs = append(recs, goqa.Record{
+			Repository: "acme/foo",
+			Ref:        "master",
+			Commit:     "c" + string(rune('1'+i)),
+			Time:       "t",
+			Tests:      []goqa.TestResult{{Pkg: "foo", Test: "TestA", Status: status, Elapsed: elapsed}},
+			Packages:   []goqa.PackageResult{{Pkg: "foo", Status: goqa.TestPass, Elapsed: elapsed * 2}},
+		})
+	}
+
+	tests := []struct {
+		name   string
+		window int
+		want   []Trend
+	}{
+		{
+			name:   "default window",
+			window: 0,
+			want: []Trend{
+				{Pkg: "foo", Runs: 4, P50: 5, P95: 17.9, Latest: 20},
+				{Pkg: "foo", Test: "TestA", Runs: 3, P50: 2, P95: 9.2, Latest: 10},
+			},
+		},
+		{
+			name:   "window of 2",
+			window: 2,
+			want: []Trend{
+				{Pkg: "foo", Runs: 2, P50: 13, P95: 19.3, Latest: 20},
+				{Pkg: "foo", Test: "TestA", Runs: 2, P50: 6, P95: 9.6, Latest: 10},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got = Analyze(recs, tt.window)
+			if len(got) != len(tt.want) {
+				t.Errorf("length want = %d, got = %d", len(tt.want), len(got))
+				return
+			}
+
+			for i := range got {
+				var w = tt.want[i]
+				var g = got[i]
+
+				if g.Pkg != w.Pkg || g.Test != w.Test || g.Runs != w.Runs || !near(g.P50, w.P50) || !near(g.P95, w.P95) || g.Latest != w.Latest {
+					t.Errorf("trend(%d)\nwant = %+v\ngot  = %+v", i, w, g)
+				}
+
+				if len(g.Points) != g.Runs {
+					t.Errorf("trend(%d) points = %d, runs = %d", i, len(g.Points), g.Runs)
+				}
+			}
+		})
+	}
+}
+
+func near(a, b float64) bool {
+	var d = a - b
+	return d < 1e-9 && d > -1e-9
+}
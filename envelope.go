@@ -0,0 +1,137 @@
+package goqa
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Envelope of an event, telling which one it is, when and where it comes from and what caused it
+type Envelope struct {
+	// ID unique to the event
+	ID string
+
+	// Time the event was created
+	Time time.Time
+
+	// Source which created the event e.g. hook or subscriber/coverage
+	Source string
+
+	// Correlation ID shared by an event and those derived from it: the ID of the first one
+	Correlation string
+
+	// Payload is the event itself
+	Payload Event
+}
+
+// envelope as encoded in json; the name of the payload tells how to decode it
+type envelope struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Time        time.Time       `json:"time"`
+	Source      string          `json:"source"`
+	Correlation string          `json:"correlation_id"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+// Name of the payload, so that subscribers of an event get it enveloped
+func (e Envelope) Name() string {
+	return e.Payload.Name()
+}
+
+// String representation of the payload
+func (e Envelope) String() string {
+	return e.Payload.String()
+}
+
+// MarshalJSON along the name of the payload
+func (e Envelope) MarshalJSON() ([]byte, error) {
+	var payload, err = json.Marshal(e.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelope{
+		ID:          e.ID,
+		Name:        e.Name(),
+		Time:        e.Time,
+		Source:      e.Source,
+		Correlation: e.Correlation,
+		Payload:     payload,
+	})
+}
+
+// UnmarshalJSON decoding the payload by its name, see Register
+func (e *Envelope) UnmarshalJSON(b []byte) error {
+	var v envelope
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	var payload, err = decode(v.Name, v.Payload)
+	if err != nil {
+		return err
+	}
+
+	*e = Envelope{ID: v.ID, Time: v.Time, Source: v.Source, Correlation: v.Correlation, Payload: payload}
+
+	return nil
+}
+
+// Seal an event from a source in a new envelope; an event already sealed is returned as it is
+func Seal(source string, event Event) Envelope {
+	if e, ok := Enveloped(event); ok {
+		return e
+	}
+
+	var id = NewID()
+
+	return Envelope{ID: id, Time: time.Now().UTC(), Source: source, Correlation: id, Payload: event}
+}
+
+// Derive an event from the one which caused it, sharing its correlation ID
+func Derive(cause Event, source string, event Event) Envelope {
+	var e = Seal(source, Open(event))
+
+	if c, ok := Enveloped(cause); ok && c.Correlation != "" {
+		e.Correlation = c.Correlation
+	} else if ok {
+		e.Correlation = c.ID
+	}
+
+	return e
+}
+
+// Enveloped tells if an event was sealed, and its envelope
+func Enveloped(event Event) (Envelope, bool) {
+	switch v := event.(type) {
+	case Envelope:
+		return v, true
+	case *Envelope:
+		if v != nil {
+			return *v, true
+		}
+	}
+
+	return Envelope{}, false
+}
+
+// Open an envelope, returning its payload; an event not sealed is returned as it is
+func Open(event Event) Event {
+	if e, ok := Enveloped(event); ok {
+		return e.Payload
+	}
+
+	return event
+}
+
+// NewID of 16 random bytes in hexadecimal
+func NewID() string {
+	var b = make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // the system is unusable without randomness
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,106 @@
+package goqa
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSeal(t *testing.T) {
+	var event = CoverageEvent{Repository: "acme/foo", Pkg: "foo"}
+
+	var e = Seal("hook", event)
+	if len(e.ID) != 32 || e.Correlation != e.ID || e.Source != "hook" || e.Time.IsZero() || e.Payload != event {
+		t.Errorf("Seal() = %#v", e)
+	}
+
+	if e.Name() != EventCoverage || e.String() != event.String() {
+		t.Errorf("Seal() name = %s, string = %s", e.Name(), e.String())
+	}
+
+	if again := Seal("other", e); again != e {
+		t.Errorf("Seal() of an envelope\nwant = %#v\ngot  = %#v", e, again)
+	}
+
+	if other := Seal("hook", event); other.ID == e.ID {
+		t.Errorf("Seal() ID not unique = %s", e.ID)
+	}
+}
+
+func TestDerive(t *testing.T) {
+	var (
+		cause   = Seal("hook", &GithubEvent{Repository: "acme/foo"})
+		derived = Derive(cause, "subscriber/coverage", CoverageEvent{Repository: "acme/foo"})
+		twice   = Derive(derived, "subscriber/report", AggregateEvent{Repository: "acme/foo"})
+	)
+
+	tests := []struct {
+		name  string
+		event Envelope
+	}{
+		{name: "derived", event: derived},
+		{name: "derived twice", event: twice},
+		{name: "derived from a sealed event", event: Derive(cause, "x", Seal("y", AggregateEvent{}))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.event.ID == cause.ID || tt.event.Correlation != cause.ID {
+				t.Errorf("Derive() of %s = %#v", cause.ID, tt.event)
+			}
+		})
+	}
+
+	if e := Derive(AggregateEvent{}, "x", AggregateEvent{}); e.Correlation != e.ID {
+		t.Errorf("Derive() of an event not sealed = %#v", e)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	var event = AggregateEvent{Repository: "acme/foo"}
+	var e = Seal("hook", event)
+
+	tests := []struct {
+		name  string
+		event Event
+	}{
+		{name: "envelope", event: e},
+		{name: "envelope pointer", event: &e},
+		{name: "not sealed", event: event},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Open(tt.event); got != event {
+				t.Errorf("Open()\nwant = %#v\ngot  = %#v", event, got)
+			}
+		})
+	}
+}
+
+func TestEnvelope_JSON(t *testing.T) {
+	var e = Envelope{
+		ID:          "e2",
+		Time:        time.Date(2021, 3, 7, 23, 9, 38, 0, time.UTC),
+		Source:      "subscriber/coverage",
+		Correlation: "e1",
+		Payload:     &GithubEvent{Repository: "acme/foo", Coverage: []Coverage{{Pkg: "foo", Precise: 50.5}}},
+	}
+
+	var b, err = json.Marshal(e)
+	if err != nil {
+		t.Errorf("json.Marshal() error = %v", err)
+		return
+	}
+
+	var got Envelope
+	if err = json.Unmarshal(b, &got); err != nil {
+		t.Errorf("json.Unmarshal() error = %v", err)
+		return
+	}
+
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("json.Unmarshal()\nwant = %#v\ngot  = %#v", e, got)
+	}
+}
@@ -6,7 +6,13 @@ import (
 )
 
 // Attach a roster to a broker; stops if the context stops
+// events of a Durable broker are acknowledged once subscribers were notified
 func Attach(b Broker, r Roster) {
+	if d, ok := b.(Durable); ok {
+		attachDurable(d, r)
+		return
+	}
+
 	var c, err = b.Listen(context.Background())
 	if err != nil {
 		return
@@ -14,6 +20,30 @@ func Attach(b Broker, r Roster) {
 
 	defer Closed(b)
 
+	dispatch(c, r)
+}
+
+// AttachGroup a roster to a broker as a member of group, so that processes attached to the same group share events
+// rather than each handling all of them; as Attach when the broker is not Grouped
+func AttachGroup(b Broker, r Roster, group string) {
+	var g, ok = b.(Grouped)
+	if !ok {
+		Attach(b, r)
+		return
+	}
+
+	var c, err = g.ListenGroup(context.Background(), group)
+	if err != nil {
+		return
+	}
+
+	defer Closed(b)
+
+	dispatch(c, r)
+}
+
+// dispatch events to their subscribers until the channel is closed
+func dispatch(c <-chan Event, r Roster) {
 	for event := range c {
 		var subs, err = r.Subscribers(context.Background(), event.Name())
 		if err == nil {
@@ -24,6 +54,32 @@ func Attach(b Broker, r Roster) {
 	}
 }
 
+// attachDurable leaves events whose subscribers cannot be known unacknowledged, so that they are delivered again
+func attachDurable(b Durable, r Roster) {
+	var c, err = b.Deliveries(context.Background())
+	if err != nil {
+		return
+	}
+
+	defer Closed(b)
+
+	for d := range c {
+		var subs, err = r.Subscribers(context.Background(), d.Event.Name())
+		if err != nil {
+			log.Printf("failed to get subscribers\n%s\n%s\n", err.Error(), d.Event.Name())
+			continue
+		}
+
+		go func(d Delivery) {
+			Publish(d.Event, subs...)
+
+			if err := d.Ack(); err != nil {
+				log.Printf("failed to acknowledge event\n%s\n%s\n", err.Error(), d.Event.Name())
+			}
+		}(d)
+	}
+}
+
 // Publish event to many subscribers
 func Publish(event Event, subs ...Subscriber) {
 	for i := range subs {
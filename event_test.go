@@ -1,7 +1,10 @@
 package goqa
 
 import (
+	"context"
+	"errors"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -39,6 +42,153 @@ func (f fakeevent) String() string {
 	return f.name + ".data"
 }
 
+// fakedurable delivers events once, recording those acknowledged
+type fakedurable struct {
+	events []Event
+	mut    sync.Mutex
+	acked  []string
+	done   chan struct{}
+}
+
+func (f *fakedurable) Listen(ctx context.Context) (<-chan Event, error) {
+	return nil, errors.New("not durable")
+}
+
+func (f *fakedurable) Publish(ctx context.Context, event Event) error {
+	return nil
+}
+
+func (f *fakedurable) Close() error {
+	return nil
+}
+
+func (f *fakedurable) Deliveries(ctx context.Context) (<-chan Delivery, error) {
+	var c = make(chan Delivery, len(f.events))
+	for i := range f.events {
+		var e = f.events[i]
+		c <- Delivery{Event: e, Ack: func() error {
+			f.mut.Lock()
+			defer f.mut.Unlock()
+
+			f.acked = append(f.acked, e.Name())
+			if len(f.acked) == 1 {
+				close(f.done)
+			}
+
+			return nil
+		}}
+	}
+
+	close(c)
+	return c, nil
+}
+
+// fakeroster fails to know subscribers of events named "unknown"
+type fakeroster struct {
+	sub *fakesubscriber
+}
+
+func (f *fakeroster) Subscribe(ctx context.Context, name string, sub Subscriber) error {
+	return nil
+}
+
+func (f *fakeroster) Unsubscribe(ctx context.Context, id string) error {
+	return nil
+}
+
+func (f *fakeroster) Subscribers(ctx context.Context, name string) ([]Subscriber, error) {
+	if name == "unknown" {
+		return nil, errors.New("roster err")
+	}
+
+	return []Subscriber{f.sub}, nil
+}
+
+func (f *fakeroster) Close() error {
+	return nil
+}
+
+func TestAttach(t *testing.T) {
+	t.Run("durable", func(t *testing.T) {
+		var (
+			b = &fakedurable{events: []Event{fakeevent{name: "unknown"}, fakeevent{name: "known"}}, done: make(chan struct{})}
+			r = &fakeroster{sub: &fakesubscriber{}}
+		)
+
+		Attach(b, r)
+		<-b.done
+
+		b.mut.Lock()
+		defer b.mut.Unlock()
+
+		// events whose subscribers are not known are left to be delivered again
+		if want := []string{"known"}; !reflect.DeepEqual(b.acked, want) {
+			t.Errorf("acked; want %v, got %v", want, b.acked)
+		}
+
+		if want := (fakeevent{name: "known"}); r.sub.event != want {
+			t.Errorf("notified; want %v, got %v", want, r.sub.event)
+		}
+	})
+}
+
+// fakegrouped tells its events to members of a group only
+type fakegrouped struct {
+	events []Event
+	group  string
+}
+
+func (f *fakegrouped) Listen(ctx context.Context) (<-chan Event, error) {
+	return nil, errors.New("not a member")
+}
+
+func (f *fakegrouped) ListenGroup(ctx context.Context, group string) (<-chan Event, error) {
+	f.group = group
+
+	var c = make(chan Event, len(f.events))
+	for i := range f.events {
+		c <- f.events[i]
+	}
+
+	close(c)
+	return c, nil
+}
+
+func (f *fakegrouped) Publish(ctx context.Context, event Event) error {
+	return nil
+}
+
+func (f *fakegrouped) Close() error {
+	return nil
+}
+
+func TestAttachGroup(t *testing.T) {
+	t.Run("grouped", func(t *testing.T) {
+		var (
+			b = &fakegrouped{events: []Event{fakeevent{name: "known"}}}
+			r = &fakeroster{sub: &fakesubscriber{}}
+		)
+
+		var _ Grouped = b
+
+		AttachGroup(b, r, "workers")
+
+		if b.group != "workers" {
+			t.Errorf("group; want workers, got %s", b.group)
+		}
+	})
+
+	t.Run("not grouped", func(t *testing.T) {
+		var (
+			b = &fakedurable{events: []Event{fakeevent{name: "known"}}, done: make(chan struct{})}
+			r = &fakeroster{sub: &fakesubscriber{}}
+		)
+
+		AttachGroup(b, r, "workers")
+		<-b.done
+	})
+}
+
 func TestPublish(t *testing.T) {
 	type args struct {
 		event Event
@@ -112,3 +262,23 @@ func TestPublish(t *testing.T) {
 		})
 	}
 }
+
+func TestCoverageDeltaEvent_Delta(t *testing.T) {
+	tests := []struct {
+		name  string
+		event CoverageDeltaEvent
+		want  float64
+	}{
+		{name: "fractional drop", event: CoverageDeltaEvent{Old: 83.3, New: 82.4}, want: -0.9},
+		{name: "up", event: CoverageDeltaEvent{Old: 50, New: 75}, want: 25},
+		{name: "none", event: CoverageDeltaEvent{Old: 10.1, New: 10.1}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.event.Delta(); got != tt.want {
+				t.Errorf("Delta() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package goqa
 
 import (
 	"fmt"
+	"math"
 	"strings"
 )
 
@@ -14,6 +15,15 @@ type GithubEvent struct {
 	Head       string `json:"head"`
 	Workflow   string `json:"workflow"`
 	Coverage   []Coverage
+	Tests      []TestResult
+	Packages   []PackageResult
+	Files      []FileCoverage
+
+	// Base commit of a pull request; empty otherwise
+	Base string `json:"base"`
+
+	// Changes are the lines added or modified per file compared to Base, paths being relative to the repository
+	Changes map[string][]int
 }
 
 // Name of the event
@@ -30,6 +40,7 @@ func (e GithubEvent) String() string {
 			`Commit = "` + e.Commit + `"\n` +
 			`Ref = "` + e.Ref + `"\n` +
 			`Head = "` + e.Head + `"\n` +
+			`Base = "` + e.Base + `"\n` +
 			`Workflow = "` + e.Workflow + `"\n` +
 			`Coverage =\n`,
 	)
@@ -38,6 +49,14 @@ func (e GithubEvent) String() string {
 		b.WriteString(e.Coverage[i].String() + "\n")
 	}
 
+	if len(e.Tests) != 0 {
+		b.WriteString("Tests =\n")
+	}
+
+	for i := range e.Tests {
+		b.WriteString(e.Tests[i].String() + "\n")
+	}
+
 	return b.String()
 }
 
@@ -49,5 +68,164 @@ func (c CoverageEvent) Name() string {
 }
 
 func (c CoverageEvent) String() string {
-	return fmt.Sprintf("pkg: %s; percentage: %d %%; time: %s", c.Pkg, c.Percentage, c.Time)
+	return fmt.Sprintf("repository: %s; ref: %s; pkg: %s; percentage: %s %%; time: %s", c.Repository, c.Ref, c.Pkg, FormatPercentage(Coverage(c).Value()), c.Time)
+}
+
+// CoverageDeltaEvent is a change of coverage of a package compared to the previous value for the same repository and ref
+type CoverageDeltaEvent struct {
+	Repository string  `json:"repository"`
+	Ref        string  `json:"ref"`
+	Commit     string  `json:"commit"`
+	Pkg        string  `json:"pkg"`
+	Old        float64 `json:"old"`
+	New        float64 `json:"new"`
+	Time       string  `json:"time"`
+}
+
+// Delta between new and old percentages, to the first decimal
+func (c CoverageDeltaEvent) Delta() float64 {
+	return math.Round((c.New-c.Old)*10) / 10
+}
+
+// Name is EventCoverageRegression when coverage went down, EventCoverageImproved otherwise
+func (c CoverageDeltaEvent) Name() string {
+	if c.New < c.Old {
+		return EventCoverageRegression
+	}
+
+	return EventCoverageImproved
+}
+
+func (c CoverageDeltaEvent) String() string {
+	return fmt.Sprintf(
+		"repository: %s; ref: %s; commit: %s; pkg: %s; percentage: %s %% -> %s %% (%+g); time: %s",
+		c.Repository, c.Ref, c.Commit, c.Pkg, FormatPercentage(c.Old), FormatPercentage(c.New), c.Delta(), c.Time,
+	)
+}
+
+// GateCheck is the outcome of a quality gate rule on a package
+type GateCheck struct {
+	Pkg        string  `json:"pkg"`
+	Percentage float64 `json:"percentage"`
+	Passed     bool    `json:"passed"`
+	Reason     string  `json:"reason,omitempty"`
+}
+
+// GateFailedEvent is emitted when coverage of a push does not satisfy quality gates
+type GateFailedEvent struct {
+	Repository string      `json:"repository"`
+	Ref        string      `json:"ref"`
+	Commit     string      `json:"commit"`
+	Failures   []GateCheck `json:"failures"`
+}
+
+func (g GateFailedEvent) Name() string {
+	return EventGateFailed
+}
+
+func (g GateFailedEvent) String() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("repository: %s; ref: %s; commit: %s; failures:\n", g.Repository, g.Ref, g.Commit))
+
+	for i := range g.Failures {
+		b.WriteString(fmt.Sprintf("pkg: %s; %s\n", g.Failures[i].Pkg, g.Failures[i].Reason))
+	}
+
+	return b.String()
+}
+
+// FlakyTestEvent is emitted when the flakiness score of a test crosses the threshold
+type FlakyTestEvent struct {
+	Repository string  `json:"repository"`
+	Ref        string  `json:"ref"`
+	Commit     string  `json:"commit"`
+	Pkg        string  `json:"pkg"`
+	Test       string  `json:"test"`
+	Runs       int     `json:"runs"`
+	Flips      int     `json:"flips"`
+	Retried    int     `json:"retried"`
+	Score      float64 `json:"score"`
+}
+
+func (f FlakyTestEvent) Name() string {
+	return EventFlakyTest
+}
+
+func (f FlakyTestEvent) String() string {
+	return fmt.Sprintf(
+		"repository: %s; ref: %s; commit: %s; pkg: %s; test: %s; score: %.2f; flips: %d; retried: %d; runs: %d",
+		f.Repository, f.Ref, f.Commit, f.Pkg, f.Test, f.Score, f.Flips, f.Retried, f.Runs,
+	)
+}
+
+// SlowTestEvent is emitted when a test, or a whole package when Test is empty, took much longer than its baseline
+type SlowTestEvent struct {
+	Repository string  `json:"repository"`
+	Ref        string  `json:"ref"`
+	Commit     string  `json:"commit"`
+	Pkg        string  `json:"pkg"`
+	Test       string  `json:"test"`
+	Elapsed    float64 `json:"elapsed"`
+	Baseline   float64 `json:"baseline"`
+	Factor     float64 `json:"factor"`
+}
+
+func (s SlowTestEvent) Name() string {
+	return EventSlowTest
+}
+
+func (s SlowTestEvent) String() string {
+	return fmt.Sprintf(
+		"repository: %s; ref: %s; commit: %s; pkg: %s; test: %s; elapsed: %.3fs; baseline: %.3fs; factor: %.1fx",
+		s.Repository, s.Ref, s.Commit, s.Pkg, s.Test, s.Elapsed, s.Baseline, s.Factor,
+	)
+}
+
+// AggregateEvent is the aggregate coverage of a whole repository and ref
+type AggregateEvent Aggregate
+
+func (a AggregateEvent) Name() string {
+	return EventAggregate
+}
+
+func (a AggregateEvent) String() string {
+	return fmt.Sprintf(
+		"repository: %s; ref: %s; path: %s; percentage: %s %%; statements: %d/%d; packages: %d",
+		a.Repository, a.Ref, a.Path, FormatPercentage(a.Precise), a.Covered, a.Statements, a.Packages,
+	)
+}
+
+// DiffEvent is the coverage of a pull request compared to its base
+type DiffEvent Diff
+
+func (d DiffEvent) Name() string {
+	return EventDiff
+}
+
+func (d DiffEvent) String() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("repository: %s; ref: %s; head: %s; base: %s; commit: %s", d.Repository, d.Ref, d.Head, d.Base, d.Commit))
+
+	if d.Patch != nil {
+		b.WriteString(fmt.Sprintf("; patch: %s %% of %d lines", FormatPercentage(d.Patch.Percentage), d.Patch.Lines))
+	}
+
+	b.WriteString("\n")
+
+	for _, p := range d.Packages {
+		switch {
+		case p.Added:
+			b.WriteString(fmt.Sprintf("pkg: %s; new; %s %%\n", p.Pkg, FormatPercentage(p.Head)))
+		case p.Removed:
+			b.WriteString(fmt.Sprintf("pkg: %s; removed\n", p.Pkg))
+		default:
+			b.WriteString(fmt.Sprintf("pkg: %s; %s %% -> %s %% (%+g)\n", p.Pkg, FormatPercentage(p.Base), FormatPercentage(p.Head), p.Delta))
+		}
+	}
+
+	for _, pkg := range d.Untested {
+		b.WriteString(fmt.Sprintf("pkg: %s; no tests\n", pkg))
+	}
+
+	return b.String()
 }
@@ -0,0 +1,137 @@
+package goqa
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// LineCovered is run by at least one block
+	LineCovered = "covered"
+
+	// LineUncovered is only part of blocks which never ran
+	LineUncovered = "uncovered"
+
+	// LineNotInstrumented is not part of any block e.g. comments or declarations
+	LineNotInstrumented = "none"
+)
+
+// Block of statements as found in a coverprofile; positions are those of the source file
+type Block struct {
+	StartLine  int `json:"start_line"`
+	StartCol   int `json:"start_col"`
+	EndLine    int `json:"end_line"`
+	EndCol     int `json:"end_col"`
+	Statements int `json:"statements"`
+
+	// Count is how many times the block ran; 0 or 1 in set mode
+	Count int `json:"count"`
+}
+
+// FileCoverage is the statement coverage of a single source file
+type FileCoverage struct {
+	Pkg string `json:"pkg"`
+
+	// File is the full import path of the file e.g. github.com/acme/foo/foo.go
+	File       string  `json:"file"`
+	Statements int     `json:"statements"`
+	Covered    int     `json:"covered"`
+	Blocks     []Block `json:"blocks,omitempty"`
+
+	// Funcs of the file; only known when the source was uploaded along with the coverprofile
+	Funcs []FuncCoverage `json:"funcs,omitempty"`
+
+	// Source of the file when uploaded along with the coverprofile
+	Source string `json:"source,omitempty"`
+}
+
+// Line of source annotated with its coverage
+type Line struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+	Status string `json:"status"`
+
+	// Count is the highest count of blocks on the line
+	Count int `json:"count"`
+}
+
+// FuncCoverage is the statement coverage of a function, including the function literals it contains
+type FuncCoverage struct {
+	// Name of the function; methods are prefixed by their receiver e.g. (*Hook).Receive
+	Name       string `json:"name"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Statements int    `json:"statements"`
+	Covered    int    `json:"covered"`
+}
+
+// Percentage of statements covered; 0 when there are no statements
+func (f FuncCoverage) Percentage() float64 {
+	return Percentage(f.Covered, f.Statements)
+}
+
+// Percentage of statements covered; 0 when there are no statements
+func (f FileCoverage) Percentage() float64 {
+	return Percentage(f.Covered, f.Statements)
+}
+
+// Uncovered lines, in increasing order; a line partly covered by another block is considered covered
+func (f FileCoverage) Uncovered() []int {
+	var lines = make(map[int]bool) // line => covered
+
+	for _, b := range f.Blocks {
+		for l := b.StartLine; l <= b.EndLine; l++ {
+			if l >= 1 {
+				lines[l] = lines[l] || b.Count > 0
+			}
+		}
+	}
+
+	var uncovered = []int{}
+	for l, covered := range lines {
+		if !covered {
+			uncovered = append(uncovered, l)
+		}
+	}
+
+	sort.Ints(uncovered)
+
+	return uncovered
+}
+
+// Lines of the source annotated with coverage, like go tool cover -html; nil when the source is unknown
+func (f FileCoverage) Lines() []Line {
+	if f.Source == "" {
+		return nil
+	}
+
+	var texts = strings.Split(strings.TrimSuffix(f.Source, "\n"), "\n")
+	var lines = make([]Line, len(texts))
+
+	for i := range texts {
+		lines[i] = Line{Number: i + 1, Text: texts[i], Status: LineNotInstrumented}
+	}
+
+	for _, b := range f.Blocks {
+		for l := b.StartLine; l <= b.EndLine && l <= len(lines); l++ {
+			if l < 1 {
+				continue // lines are numbered from 1, blocks recorded before they were checked may not be
+			}
+
+			var line = &lines[l-1]
+
+			switch {
+			case b.Count > 0:
+				line.Status = LineCovered
+			case line.Status == LineNotInstrumented:
+				line.Status = LineUncovered
+			}
+
+			if b.Count > line.Count {
+				line.Count = b.Count
+			}
+		}
+	}
+
+	return lines
+}
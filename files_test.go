@@ -0,0 +1,140 @@
+package goqa
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFileCoverage_Percentage(t *testing.T) {
+	tests := []struct {
+		name string
+		file FileCoverage
+		want float64
+	}{
+		{name: "no statements", file: FileCoverage{}, want: 0},
+		{name: "none", file: FileCoverage{Statements: 3}, want: 0},
+		{name: "first decimal", file: FileCoverage{Statements: 3, Covered: 2}, want: 66.7},
+		{name: "all", file: FileCoverage{Statements: 3, Covered: 3}, want: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.file.Percentage(); got != tt.want {
+				t.Errorf("Percentage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileCoverage_Uncovered(t *testing.T) {
+	tests := []struct {
+		name   string
+		blocks []Block
+		want   []int
+	}{
+		{
+			name:   "no blocks",
+			blocks: nil,
+			want:   []int{},
+		},
+		{
+			name: "all covered",
+			blocks: []Block{
+				{StartLine: 3, EndLine: 5, Count: 1},
+			},
+			want: []int{},
+		},
+		{
+			name: "partly covered",
+			blocks: []Block{
+				{StartLine: 3, EndLine: 5, Count: 2},
+				{StartLine: 5, EndLine: 7, Count: 0},
+				{StartLine: 10, EndLine: 10, Count: 0},
+			},
+			want: []int{6, 7, 10},
+		},
+		{
+			name: "line zero",
+			blocks: []Block{
+				{StartLine: 0, EndLine: 0, Count: 0},
+				{StartLine: 0, EndLine: 1, Count: 0},
+			},
+			want: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (FileCoverage{Blocks: tt.blocks}).Uncovered(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Uncovered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileCoverage_Lines(t *testing.T) {
+	var blocks = []Block{
+		{StartLine: 3, EndLine: 5, Count: 2},
+		{StartLine: 5, EndLine: 6, Count: 0},
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		blocks []Block // the above when nil
+		want   []Line
+	}{
+		{
+			name:   "no source",
+			source: "",
+			want:   nil,
+		},
+		{
+			name:   "annotated",
+			source: "package foo\n\nfunc Foo() {\n\tbar()\n\tif baz() {\n\t\treturn\n\t}\n}\n",
+			want: []Line{
+				{Number: 1, Text: "package foo", Status: LineNotInstrumented},
+				{Number: 2, Text: "", Status: LineNotInstrumented},
+				{Number: 3, Text: "func Foo() {", Status: LineCovered, Count: 2},
+				{Number: 4, Text: "\tbar()", Status: LineCovered, Count: 2},
+				{Number: 5, Text: "\tif baz() {", Status: LineCovered, Count: 2},
+				{Number: 6, Text: "\t\treturn", Status: LineUncovered},
+				{Number: 7, Text: "\t}", Status: LineNotInstrumented},
+				{Number: 8, Text: "}", Status: LineNotInstrumented},
+			},
+		},
+		{
+			name:   "source shorter than blocks",
+			source: "package foo\n\nfunc Foo() {",
+			want: []Line{
+				{Number: 1, Text: "package foo", Status: LineNotInstrumented},
+				{Number: 2, Text: "", Status: LineNotInstrumented},
+				{Number: 3, Text: "func Foo() {", Status: LineCovered, Count: 2},
+			},
+		},
+		{
+			name:   "line zero",
+			source: "package foo\n",
+			blocks: []Block{
+				{StartLine: 0, EndLine: 0, Count: 1},
+				{StartLine: 0, EndLine: 1, Count: 1},
+			},
+			want: []Line{
+				{Number: 1, Text: "package foo", Status: LineCovered, Count: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b = tt.blocks
+			if b == nil {
+				b = blocks
+			}
+
+			if got := (FileCoverage{Blocks: b, Source: tt.source}).Lines(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines()\nwant = %v\ngot  = %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package flaky
+
+import (
+	"sort"
+
+	"github.com/fluxynet/goqa"
+)
+
+const (
+	// DefaultThreshold score from which a test is deemed flaky
+	DefaultThreshold = 0.2
+
+	// DefaultWindow is the number of latest runs considered per test
+	DefaultWindow = 20
+)
+
+// Score of flakiness of a test on a repository and ref
+//
+// goqa has no access to source code, so any flip between pass and fail on consecutive runs counts; a flip between
+// runs of the same commit is a change of outcome without any code change and counts twice. A run where the test only
+// passed after failing attempts counts as a flip on its own.
+type Score struct {
+	Repository string `json:"repository"`
+	Ref        string `json:"ref"`
+	Pkg        string `json:"pkg"`
+	Test       string `json:"test"`
+
+	// Runs considered
+	Runs int `json:"runs"`
+
+	// Flips between pass and fail across consecutive runs
+	Flips int `json:"flips"`
+
+	// Retried is the number of runs where the test passed only on retry
+	Retried int `json:"retried"`
+
+	// Status of the latest run
+	Status string `json:"status"`
+
+	// Commit of the latest run
+	Commit string `json:"commit"`
+
+	// Score between 0 (stable) and 1 (flips every run)
+	Score float64 `json:"score"`
+}
+
+type run struct {
+	commit string
+	result goqa.TestResult
+}
+
+// Analyze records, oldest first, considering the latest window runs of every test; scores are sorted by decreasing score
+func Analyze(recs []goqa.Record, window int) []Score {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+
+	var (
+		runs  = make(map[Score][]run) // identifying fields only
+		order []Score
+	)
+
+	for i := range recs {
+		for j := range recs[i].Tests {
+			var (
+				t = recs[i].Tests[j]
+				k = Score{Repository: recs[i].Repository, Ref: recs[i].Ref, Pkg: t.Pkg, Test: t.Test}
+			)
+
+			if _, ok := runs[k]; !ok {
+				order = append(order, k)
+			}
+
+			runs[k] = append(runs[k], run{commit: recs[i].Commit, result: t})
+		}
+	}
+
+	var scores = make([]Score, len(order))
+
+	for i, k := range order {
+		var r = runs[k]
+		if len(r) > window {
+			r = r[len(r)-window:]
+		}
+
+		scores[i] = score(k, r)
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+
+	return scores
+}
+
+func score(s Score, runs []run) Score {
+	var (
+		weight float64
+		prev   *run
+	)
+
+	for i := range runs {
+		var r = runs[i]
+
+		if r.result.PassedOnRetry() {
+			s.Retried++
+			weight++
+		}
+
+		if r.result.Status == goqa.TestSkip {
+			continue
+		}
+
+		if prev != nil && prev.result.Status != r.result.Status {
+			s.Flips++
+			weight++
+
+			if prev.commit == r.commit {
+				weight++
+			}
+		}
+
+		prev = &runs[i]
+	}
+
+	s.Runs = len(runs)
+
+	if s.Runs != 0 {
+		s.Status = runs[s.Runs-1].result.Status
+		s.Commit = runs[s.Runs-1].commit
+		s.Score = weight / float64(s.Runs)
+	}
+
+	if s.Score > 1 {
+		s.Score = 1
+	}
+
+	return s
+}
@@ -0,0 +1,93 @@
+package flaky
+
+import (
+	"testing"
+
+	"github.com/fluxynet/goqa"
+)
+
+func makeRecord(commit string, results ...goqa.TestResult) goqa.Record {
+	return goqa.Record{Repository: "acme/foo", Ref: "master", Commit: commit, Tests: results}
+}
+
+func result(test, status string) goqa.TestResult {
+	return goqa.TestResult{Pkg: "foo", Test: test, Status: status}
+}
+
+func TestAnalyze(t *testing.T) {
+	var retried = result("TestR", goqa.TestPass)
+	retried.Retries = 1
+	retried.Failures = 1
+
+	var recs = []goqa.Record{
+		makeRecord("c1", result("TestA", goqa.TestPass), result("TestB", goqa.TestPass), result("TestF", goqa.TestPass), result("TestR", goqa.TestPass)),
+		makeRecord("c2", result("TestA", goqa.TestPass), result("TestB", goqa.TestFail), result("TestF", goqa.TestFail), retried),
+		makeRecord("c2", result("TestA", goqa.TestPass), result("TestB", goqa.TestFail), result("TestF", goqa.TestPass), result("TestR", goqa.TestPass)),
+		makeRecord("c3", result("TestA", goqa.TestSkip), result("TestB", goqa.TestFail), result("TestF", goqa.TestPass), result("TestR", goqa.TestPass)),
+	}
+
+	tests := []struct {
+		name   string
+		window int
+		want   map[string]Score
+	}{
+		{
+			name:   "default window",
+			window: 0,
+			want: map[string]Score{
+				"TestA": {Runs: 4, Status: goqa.TestSkip, Commit: "c3", Score: 0},
+				"TestB": {Runs: 4, Flips: 1, Status: goqa.TestFail, Commit: "c3", Score: 0.25},
+				"TestF": {Runs: 4, Flips: 2, Status: goqa.TestPass, Commit: "c3", Score: 0.75}, // flip on c2 counts twice
+				"TestR": {Runs: 4, Retried: 1, Status: goqa.TestPass, Commit: "c3", Score: 0.25},
+			},
+		},
+		{
+			name:   "window of 2",
+			window: 2,
+			want: map[string]Score{
+				"TestA": {Runs: 2, Status: goqa.TestSkip, Commit: "c3", Score: 0},
+				"TestB": {Runs: 2, Status: goqa.TestFail, Commit: "c3", Score: 0},
+				"TestF": {Runs: 2, Status: goqa.TestPass, Commit: "c3", Score: 0},
+				"TestR": {Runs: 2, Status: goqa.TestPass, Commit: "c3", Score: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got = Analyze(recs, tt.window)
+
+			if len(got) != len(tt.want) {
+				t.Errorf("length want = %d, got = %d", len(tt.want), len(got))
+				return
+			}
+
+			for i := range got {
+				if i != 0 && got[i].Score > got[i-1].Score {
+					t.Errorf("not sorted by decreasing score at %d", i)
+				}
+
+				var w = tt.want[got[i].Test]
+				w.Repository, w.Ref, w.Pkg, w.Test = "acme/foo", "master", "foo", got[i].Test
+
+				if got[i] != w {
+					t.Errorf("%s\nwant = %+v\ngot  = %+v", got[i].Test, w, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyze_Capped(t *testing.T) {
+	var retried = result("TestA", goqa.TestPass)
+	retried.Failures = 1
+
+	var got = Analyze([]goqa.Record{
+		makeRecord("c1", result("TestA", goqa.TestFail)),
+		makeRecord("c1", retried),
+	}, 0)
+
+	if len(got) != 1 || got[0].Score != 1 {
+		t.Errorf("score not capped to 1: %+v", got)
+	}
+}
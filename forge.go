@@ -0,0 +1,41 @@
+package goqa
+
+import "context"
+
+const (
+	// StatusSuccess means a commit is fine
+	StatusSuccess = "success"
+
+	// StatusFailure means something is wrong with a commit
+	StatusFailure = "failure"
+
+	// StatusPending means a commit is still being checked
+	StatusPending = "pending"
+
+	// StatusError means a commit could not be checked
+	StatusError = "error"
+)
+
+// CommitStatus is the outcome of a check on a commit, as shown by forges next to it
+type CommitStatus struct {
+	// State is one of StatusSuccess, StatusFailure, StatusPending or StatusError
+	State string
+
+	// Context tells statuses apart e.g. goqa/coverage
+	Context string
+
+	// Description is a short summary
+	Description string
+
+	// TargetURL links to details; may be empty
+	TargetURL string
+}
+
+// Forge hosts repositories e.g. GitHub, and is told about their coverage
+type Forge interface {
+	// SetStatus of a commit; replaces any previous status of the same context
+	SetStatus(ctx context.Context, repository, commit string, status CommitStatus) error
+
+	// Comment on a pull request; a previous comment containing marker is replaced rather than a new one added
+	Comment(ctx context.Context, repository string, number int, marker, body string) error
+}
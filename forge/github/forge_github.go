@@ -0,0 +1,184 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fluxynet/goqa"
+)
+
+// DefaultURL is that of the public GitHub REST api
+const DefaultURL = "https://api.github.com"
+
+// perPage is the number of comments fetched at once, the maximum allowed by GitHub
+const perPage = 100
+
+// ErrResponse means the api replied with an unexpected status
+var ErrResponse = errors.New("github api replied with an error")
+
+// New GitHub compatible api client e.g. GitHub Enterprise at https://host/api/v3; empty url for DefaultURL
+func New(url, token string) *Github {
+	if url == "" {
+		url = DefaultURL
+	}
+
+	return &Github{url: strings.TrimSuffix(url, "/"), token: token, client: http.DefaultClient, comments: make(map[string]int64)}
+}
+
+// Github sets commit statuses and comments on pull requests through the REST api
+type Github struct {
+	url    string
+	token  string
+	client *http.Client
+
+	// comments found or added, by issue and marker; saves listing the comments of a pull request on every push
+	mut      sync.Mutex
+	comments map[string]int64
+}
+
+type status struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description"`
+	Context     string `json:"context"`
+}
+
+type comment struct {
+	ID   int64  `json:"id,omitempty"`
+	Body string `json:"body"`
+}
+
+// do a request with a json body, decoding the json reply into v unless nil
+func (g *Github) do(ctx context.Context, method, path string, body, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		var b, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(b)
+	}
+
+	var req, err = http.NewRequestWithContext(ctx, method, g.url+path, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+
+	res, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%w: %s %s: %s", ErrResponse, method, path, res.Status)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// SetStatus of a commit
+func (g *Github) SetStatus(ctx context.Context, repository, commit string, s goqa.CommitStatus) error {
+	return g.do(ctx, http.MethodPost, "/repos/"+repository+"/statuses/"+commit, status{
+		State:       s.State,
+		TargetURL:   s.TargetURL,
+		Description: s.Description,
+		Context:     s.Context,
+	}, nil)
+}
+
+// Comment on a pull request, editing the first comment containing marker if any
+func (g *Github) Comment(ctx context.Context, repository string, number int, marker, body string) error {
+	var (
+		issue = "/repos/" + repository + "/issues/" + strconv.Itoa(number)
+		key   = issue + "#" + marker
+	)
+
+	if id, ok := g.comment(key); ok {
+		if g.edit(ctx, repository, id, body) == nil {
+			return nil
+		}
+
+		g.remember(key, 0) // e.g. deleted meanwhile, looked up again
+	}
+
+	for page := 1; ; page++ {
+		var comments []comment
+		var err = g.do(ctx, http.MethodGet, issue+"/comments?per_page="+strconv.Itoa(perPage)+"&page="+strconv.Itoa(page), nil, &comments)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range comments {
+			if strings.Contains(c.Body, marker) {
+				if err = g.edit(ctx, repository, c.ID, body); err == nil {
+					g.remember(key, c.ID)
+				}
+
+				return err
+			}
+		}
+
+		if len(comments) < perPage {
+			break
+		}
+	}
+
+	var added comment
+	var err = g.do(ctx, http.MethodPost, issue+"/comments", comment{Body: body}, &added)
+	if err == nil && added.ID != 0 {
+		g.remember(key, added.ID)
+	}
+
+	return err
+}
+
+// edit the body of a comment
+func (g *Github) edit(ctx context.Context, repository string, id int64, body string) error {
+	var path = "/repos/" + repository + "/issues/comments/" + strconv.FormatInt(id, 10)
+	return g.do(ctx, http.MethodPatch, path, comment{Body: body}, nil)
+}
+
+// comment found or added before under key
+func (g *Github) comment(key string) (int64, bool) {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+
+	var id, ok = g.comments[key]
+	return id, ok
+}
+
+// remember the comment of key; forgotten when id is 0
+func (g *Github) remember(key string, id int64) {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+
+	if id == 0 {
+		delete(g.comments, key)
+	} else {
+		g.comments[key] = id
+	}
+}
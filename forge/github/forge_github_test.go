@@ -0,0 +1,228 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fluxynet/goqa"
+)
+
+// fakegithub is a GitHub compatible api keeping what it is told in memory
+type fakegithub struct {
+	mut      sync.Mutex
+	requests []string // method and path of every request
+	statuses map[string][]status
+	comments map[string][]comment // issue path => comments
+	nextID   int64
+	token    string
+}
+
+func newFakegithub(token string) (*fakegithub, *httptest.Server) {
+	var f = &fakegithub{statuses: make(map[string][]status), comments: make(map[string][]comment), nextID: 1, token: token}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakegithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var parts = strings.Split(strings.TrimPrefix(r.URL.Path, "/repos/"), "/")
+
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 4 && parts[2] == "statuses":
+		var s status
+		_ = json.NewDecoder(r.Body).Decode(&s)
+		f.statuses[parts[3]] = append(f.statuses[parts[3]], s)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet && len(parts) == 5 && parts[4] == "comments":
+		var (
+			all     = f.comments[strings.Join(parts[:4], "/")]
+			page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+			size, _ = strconv.Atoi(r.URL.Query().Get("per_page"))
+			from    = (page - 1) * size
+			to      = from + size
+		)
+
+		if from > len(all) {
+			from = len(all)
+		}
+
+		if to > len(all) {
+			to = len(all)
+		}
+
+		_ = json.NewEncoder(w).Encode(append([]comment{}, all[from:to]...))
+	case r.Method == http.MethodPost && len(parts) == 5 && parts[4] == "comments":
+		var c comment
+		_ = json.NewDecoder(r.Body).Decode(&c)
+		c.ID = f.nextID
+		f.nextID++
+
+		var issue = strings.Join(parts[:4], "/")
+		f.comments[issue] = append(f.comments[issue], c)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(c)
+	case r.Method == http.MethodPatch && len(parts) == 5 && parts[3] == "comments":
+		var c comment
+		_ = json.NewDecoder(r.Body).Decode(&c)
+		var id, _ = strconv.ParseInt(parts[4], 10, 64)
+
+		for issue, comments := range f.comments {
+			for i := range comments {
+				if comments[i].ID == id {
+					f.comments[issue][i].Body = c.Body
+					return
+				}
+			}
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Run("New", func(t *testing.T) {
+		var _ goqa.Forge = New("", "")
+
+		if g := New("", ""); g.url != DefaultURL {
+			t.Errorf("New() url = %s", g.url)
+		}
+
+		if g := New("https://ghe.example.com/api/v3/", ""); g.url != "https://ghe.example.com/api/v3" {
+			t.Errorf("New() url = %s", g.url)
+		}
+	})
+}
+
+func TestGithub_SetStatus(t *testing.T) {
+	var fake, server = newFakegithub("t0ken")
+	defer server.Close()
+
+	var s = goqa.CommitStatus{State: goqa.StatusSuccess, Context: "goqa/coverage", Description: "83.3 %"}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "unauthorized", token: "bad", wantErr: ErrResponse},
+		{name: "set", token: "t0ken"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err = New(server.URL, tt.token).SetStatus(context.Background(), "acme/foo", "c1", s)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SetStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	var want = []status{{State: "success", Description: "83.3 %", Context: "goqa/coverage"}}
+	if got := fake.statuses["c1"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses\nwant = %v\ngot  = %v", want, got)
+	}
+}
+
+func TestGithub_Comment(t *testing.T) {
+	var fake, server = newFakegithub("t0ken")
+	defer server.Close()
+
+	const issue = "acme/foo/issues/1"
+
+	// a full page of comments by others, so that the marker is only found on the second page
+	for i := 0; i < perPage; i++ {
+		fake.comments[issue] = append(fake.comments[issue], comment{ID: fake.nextID, Body: "lgtm"})
+		fake.nextID++
+	}
+
+	var g = New(server.URL, "t0ken")
+
+	if err := g.Comment(context.Background(), "acme/foo", 1, "<!-- goqa -->", "<!-- goqa -->\nfirst"); err != nil {
+		t.Errorf("Comment() error = %v", err)
+		return
+	}
+
+	if err := g.Comment(context.Background(), "acme/foo", 1, "<!-- goqa -->", "<!-- goqa -->\nsecond"); err != nil {
+		t.Errorf("Comment() error = %v", err)
+		return
+	}
+
+	var got = fake.comments[issue]
+	if len(got) != perPage+1 || got[perPage].Body != "<!-- goqa -->\nsecond" {
+		t.Errorf("comments; want %d, the last one edited, got %d: %v", perPage+1, len(got), got[len(got)-1])
+	}
+
+	var wantRequests = []string{
+		"GET /repos/acme/foo/issues/1/comments",
+		"GET /repos/acme/foo/issues/1/comments",
+		"POST /repos/acme/foo/issues/1/comments",
+		"PATCH /repos/acme/foo/issues/comments/101",
+	}
+
+	if !reflect.DeepEqual(fake.requests, wantRequests) {
+		t.Errorf("requests\nwant = %v\ngot  = %v", wantRequests, fake.requests)
+	}
+
+	// found by another client, e.g. after a restart
+	fake.requests = nil
+	if err := New(server.URL, "t0ken").Comment(context.Background(), "acme/foo", 1, "<!-- goqa -->", "<!-- goqa -->\nthird"); err != nil {
+		t.Errorf("Comment() error = %v", err)
+		return
+	}
+
+	wantRequests = []string{
+		"GET /repos/acme/foo/issues/1/comments",
+		"GET /repos/acme/foo/issues/1/comments",
+		"PATCH /repos/acme/foo/issues/comments/101",
+	}
+
+	if !reflect.DeepEqual(fake.requests, wantRequests) {
+		t.Errorf("requests\nwant = %v\ngot  = %v", wantRequests, fake.requests)
+	}
+
+	// deleted meanwhile
+	fake.comments[issue] = fake.comments[issue][:perPage]
+	fake.requests = nil
+
+	if err := g.Comment(context.Background(), "acme/foo", 1, "<!-- goqa -->", "<!-- goqa -->\nfourth"); err != nil {
+		t.Errorf("Comment() error = %v", err)
+		return
+	}
+
+	wantRequests = []string{
+		"PATCH /repos/acme/foo/issues/comments/101",
+		"GET /repos/acme/foo/issues/1/comments",
+		"GET /repos/acme/foo/issues/1/comments",
+		"POST /repos/acme/foo/issues/1/comments",
+	}
+
+	if !reflect.DeepEqual(fake.requests, wantRequests) {
+		t.Errorf("requests\nwant = %v\ngot  = %v", wantRequests, fake.requests)
+	}
+
+	if got = fake.comments[issue]; len(got) != perPage+1 || got[perPage].Body != "<!-- goqa -->\nfourth" {
+		t.Errorf("comments; want %d, the last one added again, got %d", perPage+1, len(got))
+	}
+
+	if err := New(server.URL, "bad").Comment(context.Background(), "acme/foo", 1, "<!-- goqa -->", ""); !errors.Is(err, ErrResponse) {
+		t.Errorf("Comment() error = %v, want %v", err, ErrResponse)
+	}
+}
@@ -0,0 +1,166 @@
+package gate
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/fluxynet/goqa"
+)
+
+// Rule sets coverage requirements on packages matching patterns
+//
+// Patterns are globs where "*" matches within a path segment and "..." matches anything, including slashes;
+// as with the go tool, "x/..." also matches "x" itself. An empty pattern matches everything.
+type Rule struct {
+	// Repository pattern, e.g. "acme/*"
+	Repository string `json:"repository"`
+
+	// Pkg pattern, e.g. "github.com/acme/*/internal/..."
+	Pkg string `json:"pkg"`
+
+	// Min coverage required
+	Min float64 `json:"min"`
+
+	// MaxDrop allowed compared to the previous value for the same repository and ref; nil means no limit
+	MaxDrop *float64 `json:"max_drop"`
+}
+
+// Result of evaluating a policy
+type Result struct {
+	Passed bool             `json:"passed"`
+	Checks []goqa.GateCheck `json:"checks"`
+}
+
+// Failures among checks
+func (r Result) Failures() []goqa.GateCheck {
+	var f []goqa.GateCheck
+	for i := range r.Checks {
+		if !r.Checks[i].Passed {
+			f = append(f, r.Checks[i])
+		}
+	}
+
+	return f
+}
+
+// Policy is a set of rules; every rule matching a package applies
+type Policy struct {
+	rules []rule
+}
+
+type rule struct {
+	Rule
+	repository *regexp.Regexp
+	pkg        *regexp.Regexp
+}
+
+// New policy from rules
+func New(rules ...Rule) (*Policy, error) {
+	var p = Policy{rules: make([]rule, len(rules))}
+
+	for i := range rules {
+		var r = rule{Rule: rules[i]}
+		var err error
+
+		if r.repository, err = compile(rules[i].Repository); err != nil {
+			return nil, err
+		}
+
+		if r.pkg, err = compile(rules[i].Pkg); err != nil {
+			return nil, err
+		}
+
+		p.rules[i] = r
+	}
+
+	return &p, nil
+}
+
+// compile a glob pattern into an anchored regular expression
+func compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); {
+		switch {
+		case strings.HasPrefix(pattern[i:], "/...") && i+4 == len(pattern):
+			b.WriteString("(/.*)?")
+			i += 4
+		case strings.HasPrefix(pattern[i:], "..."):
+			b.WriteString(".*")
+			i += 3
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+			i++
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			i++
+		}
+	}
+
+	b.WriteString("$")
+
+	return regexp.Compile(b.String())
+}
+
+func match(re *regexp.Regexp, s string) bool {
+	return re == nil || re.MatchString(s)
+}
+
+// Evaluate coverage of an event against the policy; previous holds the values before the event
+func (p *Policy) Evaluate(e *goqa.GithubEvent, previous goqa.Cache) Result {
+	var res = Result{Passed: true, Checks: []goqa.GateCheck{}}
+
+	if p == nil || e == nil {
+		return res
+	}
+
+	for i := range e.Coverage {
+		var cov = e.Coverage[i]
+
+		for j := range p.rules {
+			var r = p.rules[j]
+			if !match(r.repository, e.Repository) || !match(r.pkg, cov.Pkg) {
+				continue
+			}
+
+			var perc = cov.Value()
+
+			var check = goqa.GateCheck{
+				Pkg:        cov.Pkg,
+				Percentage: perc,
+				Passed:     true,
+			}
+
+			if perc < r.Min {
+				check.Passed = false
+				check.Reason = fmt.Sprintf("coverage %g %% is below minimum %g %%", perc, r.Min)
+			} else if r.MaxDrop != nil && previous != nil {
+				if prev, ok := previous.Get(goqa.Key{Repository: e.Repository, Ref: e.Ref, Pkg: cov.Pkg}); ok && prev != nil {
+					// rounded to the first decimal as floats do not subtract exactly e.g. 83.3 - 82.4
+					if drop := math.Round((prev.Value()-perc)*10) / 10; drop > *r.MaxDrop {
+						check.Passed = false
+						check.Reason = fmt.Sprintf("coverage dropped from %g %% to %g %%, more than %g %%", prev.Value(), perc, *r.MaxDrop)
+					}
+				}
+			}
+
+			if !check.Passed {
+				res.Passed = false
+			}
+
+			res.Checks = append(res.Checks, check)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,209 @@
+package gate
+
+import (
+	"testing"
+
+	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/cache/memory"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		pattern string
+		matches []string
+		misses  []string
+	}{
+		{
+			pattern: "",
+			matches: []string{"", "github.com/acme/foo"},
+		},
+		{
+			pattern: "acme/*",
+			matches: []string{"acme/foo", "acme/"},
+			misses:  []string{"acme/foo/bar", "acme", "other/foo"},
+		},
+		{
+			pattern: "github.com/acme/*/internal/...",
+			matches: []string{"github.com/acme/foo/internal", "github.com/acme/foo/internal/bar", "github.com/acme/foo/internal/bar/baz"},
+			misses:  []string{"github.com/acme/foo/bar/internal", "github.com/acme/foo/internals", "github.com/acme/foo"},
+		},
+		{
+			pattern: "github.com/acme/...foo",
+			matches: []string{"github.com/acme/foo", "github.com/acme/x/y/foo"},
+			misses:  []string{"github.com/acme/foobar"},
+		},
+		{
+			pattern: "github.com/acme/fo?.go",
+			matches: []string{"github.com/acme/foo.go"},
+			misses:  []string{"github.com/acme/fooxgo", "github.com/acme/fo/.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			re, err := compile(tt.pattern)
+			if err != nil {
+				t.Errorf("compile() error = %v", err)
+				return
+			}
+
+			for _, s := range tt.matches {
+				if !match(re, s) {
+					t.Errorf("%s should match %s", tt.pattern, s)
+				}
+			}
+
+			for _, s := range tt.misses {
+				if match(re, s) {
+					t.Errorf("%s should not match %s", tt.pattern, s)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	var (
+		two      = 2.0
+		half     = 0.5
+		previous = memory.New()
+	)
+
+	_ = previous.Reset(
+		goqa.Coverage{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo", Percentage: 90},
+		goqa.Coverage{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo/internal/bar", Percentage: 70},
+		goqa.Coverage{Repository: "acme/qux", Ref: "master", Pkg: "github.com/acme/qux", Percentage: 83, Precise: 83.9},
+		goqa.Coverage{Repository: "acme/qux", Ref: "master", Pkg: "github.com/acme/qux/quux", Percentage: 83, Precise: 83.3},
+	)
+
+	policy, err := New(
+		Rule{Repository: "acme/*", Pkg: "github.com/acme/*/internal/...", Min: 60},
+		Rule{Repository: "acme/foo", MaxDrop: &two},
+		Rule{Repository: "acme/qux", MaxDrop: &half},
+	)
+
+	if err != nil {
+		t.Errorf("New() error = %v", err)
+		return
+	}
+
+	tests := []struct {
+		name   string
+		policy *Policy
+		cache  goqa.Cache // previous when nil
+		event  *goqa.GithubEvent
+		want   Result
+	}{
+		{
+			name:   "no policy",
+			policy: nil,
+			event:  &goqa.GithubEvent{Repository: "acme/foo", Coverage: []goqa.Coverage{{Pkg: "foo", Percentage: 0}}},
+			want:   Result{Passed: true},
+		},
+		{
+			name:   "no matching rule",
+			policy: policy,
+			event:  &goqa.GithubEvent{Repository: "other/foo", Ref: "master", Coverage: []goqa.Coverage{{Pkg: "github.com/acme/foo/internal/bar", Percentage: 0}}},
+			want:   Result{Passed: true},
+		},
+		{
+			name:   "passed",
+			policy: policy,
+			event: &goqa.GithubEvent{Repository: "acme/foo", Ref: "master", Coverage: []goqa.Coverage{
+				{Pkg: "github.com/acme/foo", Percentage: 88},
+			}},
+			want: Result{Passed: true, Checks: []goqa.GateCheck{
+				{Pkg: "github.com/acme/foo", Percentage: 88, Passed: true},
+			}},
+		},
+		{
+			name:   "below minimum and dropped too much",
+			policy: policy,
+			event: &goqa.GithubEvent{Repository: "acme/foo", Ref: "master", Coverage: []goqa.Coverage{
+				{Pkg: "github.com/acme/foo", Percentage: 87},
+				{Pkg: "github.com/acme/foo/internal/bar", Percentage: 65},
+				{Pkg: "github.com/acme/foo/internal/baz", Percentage: 50},
+			}},
+			want: Result{Passed: false, Checks: []goqa.GateCheck{
+				{Pkg: "github.com/acme/foo", Percentage: 87, Reason: "coverage dropped from 90 % to 87 %, more than 2 %"},
+				{Pkg: "github.com/acme/foo/internal/bar", Percentage: 65, Passed: true},
+				{Pkg: "github.com/acme/foo/internal/bar", Percentage: 65, Reason: "coverage dropped from 70 % to 65 %, more than 2 %"},
+				{Pkg: "github.com/acme/foo/internal/baz", Percentage: 50, Reason: "coverage 50 % is below minimum 60 %"},
+				{Pkg: "github.com/acme/foo/internal/baz", Percentage: 50, Passed: true},
+			}},
+		},
+		{
+			name:   "fractional drop",
+			policy: policy,
+			event: &goqa.GithubEvent{Repository: "acme/qux", Ref: "master", Coverage: []goqa.Coverage{
+				{Pkg: "github.com/acme/qux", Percentage: 83, Precise: 83.1},
+				{Pkg: "github.com/acme/qux/quux", Percentage: 82, Precise: 82.8},
+			}},
+			want: Result{Passed: false, Checks: []goqa.GateCheck{
+				{Pkg: "github.com/acme/qux", Percentage: 83.1, Reason: "coverage dropped from 83.9 % to 83.1 %, more than 0.5 %"},
+				{Pkg: "github.com/acme/qux/quux", Percentage: 82.8, Passed: true},
+			}},
+		},
+		{
+			name:   "nothing cached",
+			policy: policy,
+			cache:  nilcache{previous},
+			event: &goqa.GithubEvent{Repository: "acme/foo", Ref: "master", Coverage: []goqa.Coverage{
+				{Pkg: "github.com/acme/foo", Percentage: 10},
+			}},
+			want: Result{Passed: true, Checks: []goqa.GateCheck{
+				{Pkg: "github.com/acme/foo", Percentage: 10, Passed: true},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cache = tt.cache
+			if cache == nil {
+				cache = previous
+			}
+
+			got := tt.policy.Evaluate(tt.event, cache)
+
+			if got.Passed != tt.want.Passed {
+				t.Errorf("Passed want = %t, got = %t", tt.want.Passed, got.Passed)
+			}
+
+			if len(got.Checks) != len(tt.want.Checks) {
+				t.Errorf("Checks want = %v\ngot  = %v", tt.want.Checks, got.Checks)
+				return
+			}
+
+			for i := range tt.want.Checks {
+				if got.Checks[i] != tt.want.Checks[i] {
+					t.Errorf("Checks(%d)\nwant = %v\ngot  = %v", i, tt.want.Checks[i], got.Checks[i])
+				}
+			}
+
+			if f := got.Failures(); len(f) != len(tt.want.Checks)-countPassed(tt.want.Checks) {
+				t.Errorf("Failures() = %v", f)
+			}
+		})
+	}
+}
+
+func countPassed(checks []goqa.GateCheck) int {
+	var n int
+	for i := range checks {
+		if checks[i].Passed {
+			n++
+		}
+	}
+
+	return n
+}
+
+// nilcache gets nothing for any key
+type nilcache struct {
+	goqa.Cache
+}
+
+func (nilcache) Get(key goqa.Key) (*goqa.Coverage, bool) {
+	return nil, false
+}
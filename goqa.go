@@ -2,6 +2,7 @@ package goqa
 
 import (
 	"context"
+	"math"
 	"strconv"
 )
 
@@ -11,60 +12,187 @@ const (
 
 	// EventCoverage denotes new coverage data being available
 	EventCoverage = "EVENT_COVERAGE"
+
+	// EventCoverageRegression means coverage of a package went down
+	EventCoverageRegression = "EVENT_COVERAGE_REGRESSION"
+
+	// EventCoverageImproved means coverage of a package went up
+	EventCoverageImproved = "EVENT_COVERAGE_IMPROVED"
+
+	// EventGateFailed means coverage did not satisfy quality gates
+	EventGateFailed = "EVENT_GATE_FAILED"
+
+	// EventFlakyTest means a test became flaky
+	EventFlakyTest = "EVENT_FLAKY_TEST"
+
+	// EventAggregate means the aggregate coverage of a repository was measured
+	EventAggregate = "EVENT_AGGREGATE"
+
+	// EventSlowTest means a test or package took much longer than it usually does
+	EventSlowTest = "EVENT_SLOW_TEST"
+
+	// EventDiff means the coverage of a pull request was compared to its base
+	EventDiff = "EVENT_DIFF"
 )
 
+// Key identifies coverage of a package within a repository and ref
+type Key struct {
+	// Repository the package belongs to
+	Repository string `json:"repository"`
+
+	// Ref (branch or tag) the coverage was measured on
+	Ref string `json:"ref"`
+
+	// Pkg name
+	Pkg string `json:"pkg"`
+}
+
+// Match tells if other is covered by k; empty fields of k match anything
+func (k Key) Match(other Key) bool {
+	return (k.Repository == "" || k.Repository == other.Repository) &&
+		(k.Ref == "" || k.Ref == other.Ref) &&
+		(k.Pkg == "" || k.Pkg == other.Pkg)
+}
+
+// String representation of a key
+func (k Key) String() string {
+	return k.Repository + "@" + k.Ref + ":" + k.Pkg
+}
+
 // Coverage represents actual coverage of a package
 type Coverage struct {
+	// Repository the package belongs to
+	Repository string `json:"repository"`
+
+	// Ref (branch or tag) the coverage was measured on
+	Ref string `json:"ref"`
+
 	// Pkg name
 	Pkg string `json:"pkg"`
 
-	// Percentage expressed to the nearest integer (on a scale of 0-100)
+	// Percentage expressed to the nearest integer (on a scale of 0-100); kept for backward compatibility, see Precise
 	Percentage int `json:"percentage"`
 
+	// Precise percentage, to the first decimal as reported by go test e.g. 83.3
+	Precise float64 `json:"precise"`
+
+	// Statements in the package; only known from a coverprofile
+	Statements int `json:"statements,omitempty"`
+
+	// Covered statements in the package; only known from a coverprofile
+	Covered int `json:"covered,omitempty"`
+
 	// Time the measurement was done
 	Time string `json:"time"`
 }
 
+// Key of the coverage
+func (c Coverage) Key() Key {
+	return Key{Repository: c.Repository, Ref: c.Ref, Pkg: c.Pkg}
+}
+
 // String representation of coverage information
 func (c Coverage) String() string {
-	return `[` + c.Time + `] pkg = "` + c.Pkg + `" %` + strconv.Itoa(c.Percentage)
+	return `[` + c.Time + `] repository = "` + c.Repository + `" ref = "` + c.Ref + `" pkg = "` + c.Pkg + `" %` + FormatPercentage(c.Value())
+}
+
+// Value of the coverage with its decimals; falls back on Percentage for coverage recorded before Precise existed
+func (c Coverage) Value() float64 {
+	if c.Precise == 0 && c.Percentage != 0 {
+		return float64(c.Percentage)
+	}
+
+	return c.Precise
+}
+
+// Migrate coverage recorded before Precise existed
+func (c Coverage) Migrate() Coverage {
+	if c.Precise == 0 && c.Statements != 0 {
+		c.Precise = Percentage(c.Covered, c.Statements)
+	} else if c.Precise == 0 {
+		c.Precise = float64(c.Percentage)
+	}
+
+	return c
+}
+
+// Percentage of covered statements to the first decimal, like go test; 0 when there are no statements
+func Percentage(covered, statements int) float64 {
+	if statements == 0 {
+		return 0
+	}
+
+	return math.Round(float64(covered)*1000/float64(statements)) / 10
+}
+
+// FormatPercentage without trailing zeroes e.g. 83.3 or 100
+func FormatPercentage(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
 }
 
 // Repo allows persistance of data to permanent storage
 type Repo interface {
-	// Save coverage data on permanent storage
-	Save(ctx context.Context, covs ...Coverage) error
+	// Save coverage data of a repository and ref on permanent storage; replaces what was saved for them before
+	Save(ctx context.Context, repository, ref string, covs ...Coverage) error
 
 	// Load latest coverage data available
 	Load(ctx context.Context) ([]Coverage, error)
 
+	// Append a record to history
+	Append(ctx context.Context, rec Record) error
+
+	// History of records matching the query, oldest first
+	History(ctx context.Context, q HistoryQuery) ([]Record, error)
+
 	// Close the repo
 	Close() error
 }
 
 // Cache mechanism to store coverage data in memory
 type Cache interface {
-	// Reinit purges existing coverage info and stores new info
+	// Reset purges existing coverage info and stores new info
 	Reset(covs ...Coverage) error
 
-	// Get coverage data for a package
-	Get(pkg string) (*Coverage, bool)
+	// Replace coverage info of a repository and ref; other repositories and refs are left untouched
+	Replace(repository, ref string, covs ...Coverage) error
+
+	// Get coverage data for a package of a repository and ref
+	Get(key Key) (*Coverage, bool)
 
 	// Keys get all keys therein stored
-	Keys() ([]string, error)
+	Keys() ([]Key, error)
 
 	// Close the cache
 	Close() error
 }
 
-// Event is emitted and listened to
+// Coverages in a cache whose keys match key, in no particular order
+func Coverages(c Cache, key Key) ([]Coverage, error) {
+	var keys, err = c.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	var covs []Coverage
+	for i := range keys {
+		if !key.Match(keys[i]) {
+			continue
+		}
+
+		if cov, ok := c.Get(keys[i]); ok {
+			covs = append(covs, *cov)
+		}
+	}
+
+	return covs, nil
+}
+
+// Event is emitted and listened to; sealed in an Envelope when published, see Open
 type Event interface {
 	// Name of the event
 	Name() string
 
-	// String representation of the event
-	// not the best, a simple string cannot satisfy all use cases
-	// maybe should not use string but map[string]interface{} for key => values map
+	// String representation of the event, for people; see Envelope for the structured one
 	String() string
 }
 
@@ -106,3 +234,27 @@ type Broker interface {
 	// Close the broker
 	Close() error
 }
+
+// Delivery of an event by a Durable broker, to acknowledge once handled
+type Delivery struct {
+	Event Event
+
+	// Ack the event, so that it is not delivered again
+	Ack func() error
+}
+
+// Durable is a Broker delivering events at least once; those not acknowledged are delivered again once restarted
+type Durable interface {
+	Broker
+
+	// Deliveries of events to acknowledge once handled
+	Deliveries(ctx context.Context) (<-chan Delivery, error)
+}
+
+// Grouped is a Broker shared by processes, where each event is listened to by a single member of a group
+type Grouped interface {
+	Broker
+
+	// ListenGroup to events as a member of group; other members are told other events
+	ListenGroup(ctx context.Context, group string) (<-chan Event, error)
+}
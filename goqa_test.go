@@ -0,0 +1,99 @@
+package goqa
+
+import "testing"
+
+func TestKey_Match(t *testing.T) {
+	var other = Key{Repository: "acme/foo", Ref: "refs/heads/master", Pkg: "github.com/acme/foo"}
+
+	tests := []struct {
+		name string
+		key  Key
+		want bool
+	}{
+		{
+			name: "empty matches all",
+			key:  Key{},
+			want: true,
+		},
+		{
+			name: "same",
+			key:  other,
+			want: true,
+		},
+		{
+			name: "repository only",
+			key:  Key{Repository: "acme/foo"},
+			want: true,
+		},
+		{
+			name: "repository and ref",
+			key:  Key{Repository: "acme/foo", Ref: "refs/heads/master"},
+			want: true,
+		},
+		{
+			name: "other repository",
+			key:  Key{Repository: "acme/bar"},
+			want: false,
+		},
+		{
+			name: "other ref",
+			key:  Key{Repository: "acme/foo", Ref: "refs/heads/develop"},
+			want: false,
+		},
+		{
+			name: "other pkg",
+			key:  Key{Pkg: "github.com/acme/bar"},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.key.Match(other); got != tt.want {
+				t.Errorf("Match() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoverage_Migrate(t *testing.T) {
+	tests := []struct {
+		name string
+		cov  Coverage
+		want float64
+	}{
+		{
+			name: "precise kept",
+			cov:  Coverage{Percentage: 83, Precise: 83.3},
+			want: 83.3,
+		},
+		{
+			name: "from statements",
+			cov:  Coverage{Percentage: 66, Statements: 3, Covered: 2},
+			want: 66.7,
+		},
+		{
+			name: "from percentage",
+			cov:  Coverage{Percentage: 83},
+			want: 83,
+		},
+		{
+			name: "nothing covered",
+			cov:  Coverage{},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got = tt.cov.Migrate()
+			if got.Precise != tt.want {
+				t.Errorf("Migrate() precise = %g, want %g", got.Precise, tt.want)
+			}
+
+			if got.Percentage != tt.cov.Percentage {
+				t.Errorf("Migrate() percentage = %d, want %d", got.Percentage, tt.cov.Percentage)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package goqa
+
+import (
+	"sort"
+	"time"
+)
+
+// Record is the coverage of a repository at a given commit; records are only ever appended to history
+type Record struct {
+	Repository string          `json:"repository"`
+	Commit     string          `json:"commit"`
+	Ref        string          `json:"ref"`
+	Head       string          `json:"head,omitempty"`
+	Base       string          `json:"base,omitempty"`
+	Workflow   string          `json:"workflow"`
+	Time       string          `json:"time"`
+	Coverage   []Coverage      `json:"coverage"`
+	Tests      []TestResult    `json:"tests,omitempty"`
+	Packages   []PackageResult `json:"packages,omitempty"`
+	Files      []FileCoverage  `json:"files,omitempty"`
+
+	// Changes are the lines added or modified per file compared to Base, for pull requests
+	Changes map[string][]int `json:"changes,omitempty"`
+}
+
+// ID of a record; a record with the same ID supersedes the previous one (e.g. a redelivered webhook)
+func (r Record) ID() string {
+	return r.Repository + "@" + r.Ref + "#" + r.Commit + "/" + r.Workflow
+}
+
+// When the record was made; zero if time cannot be parsed
+func (r Record) When() time.Time {
+	var t, err = time.Parse(time.RFC3339Nano, r.Time)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+// Only keeps coverage, tests, results and files of the given package
+func (r Record) Only(pkg string) Record {
+	var covs []Coverage
+	for i := range r.Coverage {
+		if r.Coverage[i].Pkg == pkg {
+			covs = append(covs, r.Coverage[i])
+		}
+	}
+
+	var tests []TestResult
+	for i := range r.Tests {
+		if r.Tests[i].Pkg == pkg {
+			tests = append(tests, r.Tests[i])
+		}
+	}
+
+	var pkgs []PackageResult
+	for i := range r.Packages {
+		if r.Packages[i].Pkg == pkg {
+			pkgs = append(pkgs, r.Packages[i])
+		}
+	}
+
+	var files []FileCoverage
+	for i := range r.Files {
+		if r.Files[i].Pkg == pkg {
+			files = append(files, r.Files[i])
+		}
+	}
+
+	r.Coverage = covs
+	r.Tests = tests
+	r.Packages = pkgs
+	r.Files = files
+	return r
+}
+
+// Record of the event to be kept in history
+func (e GithubEvent) Record() Record {
+	var (
+		rec = Record{
+			Repository: e.Repository,
+			Commit:     e.Commit,
+			Ref:        e.Ref,
+			Head:       e.Head,
+			Base:       e.Base,
+			Workflow:   e.Workflow,
+			Coverage:   make([]Coverage, len(e.Coverage)),
+			Tests:      e.Tests,
+			Packages:   e.Packages,
+			Files:      e.Files,
+			Changes:    e.Changes,
+		}
+		latest time.Time
+	)
+
+	// time of the latest measurement
+	var observe = func(v string) {
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil && t.After(latest) {
+			latest = t
+			rec.Time = v
+		}
+	}
+
+	for i := range e.Coverage {
+		rec.Coverage[i] = e.Coverage[i]
+		rec.Coverage[i].Repository = e.Repository
+		rec.Coverage[i].Ref = e.Ref
+
+		observe(e.Coverage[i].Time)
+	}
+
+	for i := range e.Tests {
+		observe(e.Tests[i].Time)
+	}
+
+	if rec.Time == "" {
+		rec.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	return rec
+}
+
+// HistoryQuery filters records from history; empty fields match anything
+type HistoryQuery struct {
+	Repository string
+	Ref        string
+	Commit     string
+
+	// Pkg restricts records to those having coverage, tests or files for that package; other packages are stripped
+	Pkg string
+
+	// From inclusive
+	From time.Time
+
+	// To exclusive
+	To time.Time
+
+	// Files of records are only loaded when asked for; being large, repos may keep them aside from history
+	Files bool
+}
+
+// Match tells if a record satisfies the query
+func (q HistoryQuery) Match(r Record) bool {
+	if (q.Repository != "" && q.Repository != r.Repository) ||
+		(q.Ref != "" && q.Ref != r.Ref) ||
+		(q.Commit != "" && q.Commit != r.Commit) {
+		return false
+	}
+
+	if !q.From.IsZero() || !q.To.IsZero() {
+		var t = r.When()
+		if (!q.From.IsZero() && t.Before(q.From)) || (!q.To.IsZero() && !t.Before(q.To)) {
+			return false
+		}
+	}
+
+	if q.Pkg == "" {
+		return true
+	}
+
+	for i := range r.Coverage {
+		if r.Coverage[i].Pkg == q.Pkg {
+			return true
+		}
+	}
+
+	for i := range r.Tests {
+		if r.Tests[i].Pkg == q.Pkg {
+			return true
+		}
+	}
+
+	for i := range r.Packages {
+		if r.Packages[i].Pkg == q.Pkg {
+			return true
+		}
+	}
+
+	for i := range r.Files {
+		if r.Files[i].Pkg == q.Pkg {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Filter records by the query, oldest first; coverage is stripped to Pkg when set
+func (q HistoryQuery) Filter(recs []Record) []Record {
+	var got []Record
+
+	for i := range recs {
+		if !q.Match(recs[i]) {
+			continue
+		}
+
+		if q.Pkg == "" {
+			got = append(got, recs[i])
+		} else {
+			got = append(got, recs[i].Only(q.Pkg))
+		}
+	}
+
+	sort.SliceStable(got, func(i, j int) bool {
+		return got[i].When().Before(got[j].When())
+	})
+
+	return got
+}
+
+// Latest of records ordered oldest first, preferably of the workflow; false when there are none
+func Latest(recs []Record, workflow string) (Record, bool) {
+	for i := len(recs) - 1; i >= 0; i-- {
+		if recs[i].Workflow == workflow {
+			return recs[i], true
+		}
+	}
+
+	if len(recs) == 0 {
+		return Record{}, false
+	}
+
+	return recs[len(recs)-1], true
+}
@@ -0,0 +1,128 @@
+package goqa
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGithubEvent_Record(t *testing.T) {
+	var e = GithubEvent{
+		Repository: "acme/foo",
+		Commit:     "c1",
+		Ref:        "refs/heads/master",
+		Workflow:   "Go",
+		Coverage: []Coverage{
+			{Pkg: "foo", Percentage: 10, Time: "2021-03-16T07:09:32.56Z"},
+			{Pkg: "bar", Percentage: 20, Time: "2021-03-16T07:09:32.562Z"},
+		},
+	}
+
+	var rec = e.Record()
+
+	if rec.ID() != "acme/foo@refs/heads/master#c1/Go" {
+		t.Errorf("ID() = %s", rec.ID())
+	}
+
+	if rec.Time != "2021-03-16T07:09:32.562Z" {
+		t.Errorf("Time want latest measurement, got = %s", rec.Time)
+	}
+
+	if len(rec.Coverage) != 2 || rec.Coverage[1].Repository != "acme/foo" || rec.Coverage[1].Ref != "refs/heads/master" {
+		t.Errorf("Coverage not keyed: %v", rec.Coverage)
+	}
+
+	if rec = (GithubEvent{}).Record(); rec.When().IsZero() {
+		t.Errorf("Time not defaulted to now: %s", rec.Time)
+	}
+}
+
+func TestHistoryQuery_Filter(t *testing.T) {
+	var recs = []Record{
+		{Repository: "a", Ref: "master", Commit: "c3", Time: "2021-03-01T00:00:00Z", Coverage: []Coverage{{Pkg: "foo"}, {Pkg: "bar"}}},
+		{Repository: "a", Ref: "master", Commit: "c1", Time: "2021-01-01T00:00:00Z", Coverage: []Coverage{{Pkg: "foo"}}},
+		{Repository: "a", Ref: "develop", Commit: "c2", Time: "2021-02-01T00:00:00Z", Coverage: []Coverage{{Pkg: "bar"}}},
+		{Repository: "b", Ref: "master", Commit: "c4", Time: "2021-04-01T00:00:00Z", Coverage: []Coverage{{Pkg: "foo"}}},
+		{Repository: "b", Ref: "master", Commit: "c5", Time: "2021-05-01T00:00:00Z", Files: []FileCoverage{{Pkg: "baz"}, {Pkg: "foo"}}},
+	}
+
+	tests := []struct {
+		name  string
+		query HistoryQuery
+		want  []string
+	}{
+		{
+			name:  "all, oldest first",
+			query: HistoryQuery{},
+			want:  []string{"c1", "c2", "c3", "c4", "c5"},
+		},
+		{
+			name:  "repository and ref",
+			query: HistoryQuery{Repository: "a", Ref: "master"},
+			want:  []string{"c1", "c3"},
+		},
+		{
+			name:  "package",
+			query: HistoryQuery{Pkg: "bar"},
+			want:  []string{"c2", "c3"},
+		},
+		{
+			name:  "package in files",
+			query: HistoryQuery{Pkg: "baz"},
+			want:  []string{"c5"},
+		},
+		{
+			name:  "from inclusive, to exclusive",
+			query: HistoryQuery{From: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+			want:  []string{"c2", "c3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got = tt.query.Filter(recs)
+			if len(got) != len(tt.want) {
+				t.Errorf("Filter() length want = %d, got = %d", len(tt.want), len(got))
+				return
+			}
+
+			for i := range got {
+				if got[i].Commit != tt.want[i] {
+					t.Errorf("Filter()[%d] want = %s, got = %s", i, tt.want[i], got[i].Commit)
+				}
+
+				if tt.query.Pkg != "" && len(got[i].Coverage)+len(got[i].Files) != 1 {
+					t.Errorf("Filter()[%d] not stripped to %s: %v", i, tt.query.Pkg, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLatest(t *testing.T) {
+	var recs = []Record{
+		{Commit: "c1", Workflow: "Go", Time: "1"},
+		{Commit: "c1", Workflow: "Go", Time: "2"},
+		{Commit: "c1", Workflow: "Lint", Time: "3"},
+	}
+
+	tests := []struct {
+		name     string
+		recs     []Record
+		workflow string
+		want     string
+		wantOk   bool
+	}{
+		{name: "none", recs: nil, workflow: "Go", wantOk: false},
+		{name: "latest of workflow", recs: recs, workflow: "Go", want: "2", wantOk: true},
+		{name: "latest of any workflow", recs: recs, workflow: "Other", want: "3", wantOk: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, ok = Latest(tt.recs, tt.workflow)
+			if ok != tt.wantOk || got.Time != tt.want {
+				t.Errorf("Latest() = %v, %t, want %s, %t", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -4,18 +4,41 @@ import (
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/fluxynet/goqa"
 )
 
 // AssertMutexUnlocked checks if a mutex is locked
 func AssertMutexUnlocked(t *testing.T, m *sync.Mutex) {
-	var state = reflect.ValueOf(m).Elem().FieldByName("state")
+	var v = reflect.ValueOf(m).Elem()
+	if mu := v.FieldByName("mu"); mu.IsValid() { // newer toolchains wrap the internal mutex
+		v = mu
+	}
+
+	var state = v.FieldByName("state")
 	if state.Int() == 1 {
 		t.Errorf("mutex still locked")
 	}
 }
 
+// AssertRWMutexUnlocked checks neither readers nor a writer hold a mutex, by locking it for a while
+func AssertRWMutexUnlocked(t *testing.T, m *sync.RWMutex) {
+	var locked = make(chan struct{})
+
+	go func() {
+		m.Lock()
+		m.Unlock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("mutex still locked")
+	}
+}
+
 func AssertGithubEventsEqual(t *testing.T, got, want *goqa.GithubEvent) {
 	if (got == nil) != (want == nil) {
 		t.Errorf("Nil, want = %t got = %t", want == nil, got == nil)
@@ -50,7 +73,45 @@ func AssertGithubEventsEqual(t *testing.T, got, want *goqa.GithubEvent) {
 		t.Errorf("Workflow\nwant = %s\ngot  = %s", got.Workflow, want.Workflow)
 	}
 
+	if got.Base != want.Base {
+		t.Errorf("Base\nwant = %s\ngot  = %s", got.Base, want.Base)
+	}
+
+	if !reflect.DeepEqual(got.Changes, want.Changes) {
+		t.Errorf("Changes\nwant = %v\ngot  = %v", want.Changes, got.Changes)
+	}
+
 	AssertCoveragesEqual(t, got.Coverage, want.Coverage)
+	AssertTestResultsEqual(t, got.Tests, want.Tests)
+
+	if lg, lw := len(got.Packages), len(want.Packages); lg != lw {
+		t.Errorf("packages length got = %d, want = %d", lg, lw)
+		return
+	}
+
+	for i := range want.Packages {
+		if got.Packages[i] != want.Packages[i] {
+			t.Errorf("package(%d)\nwant = %v\ngot  = %v", i, want.Packages[i], got.Packages[i])
+		}
+	}
+
+	if !reflect.DeepEqual(got.Files, want.Files) {
+		t.Errorf("Files\nwant = %v\ngot  = %v", want.Files, got.Files)
+	}
+}
+
+func AssertTestResultsEqual(t *testing.T, got, want []goqa.TestResult) {
+	var lg, lw = len(got), len(want)
+	if lg != lw {
+		t.Errorf("tests length got = %d, want = %d", lg, lw)
+		return
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("test(%d)\nwant = %v\ngot  = %v", i, want[i], got[i])
+		}
+	}
 }
 
 func AssertCoveragesEqual(t *testing.T, got, want []goqa.Coverage) {
@@ -61,6 +122,14 @@ func AssertCoveragesEqual(t *testing.T, got, want []goqa.Coverage) {
 	}
 
 	for i := range want {
+		if got[i].Repository != want[i].Repository {
+			t.Errorf("coverage(%d) repository\nwant = %s\ngot  = %s", i, want[i].Repository, got[i].Repository)
+		}
+
+		if got[i].Ref != want[i].Ref {
+			t.Errorf("coverage(%d) ref\nwant = %s\ngot  = %s", i, want[i].Ref, got[i].Ref)
+		}
+
 		if got[i].Pkg != want[i].Pkg {
 			t.Errorf("coverage(%d) pkg\nwant = %s\ngot  = %s", i, want[i].Pkg, got[i].Pkg)
 		}
@@ -69,6 +138,14 @@ func AssertCoveragesEqual(t *testing.T, got, want []goqa.Coverage) {
 			t.Errorf("coverage(%d) percentage\nwant = %d\ngot  = %d", i, want[i].Percentage, got[i].Percentage)
 		}
 
+		if got[i].Precise != want[i].Precise {
+			t.Errorf("coverage(%d) precise\nwant = %g\ngot  = %g", i, want[i].Precise, got[i].Precise)
+		}
+
+		if got[i].Statements != want[i].Statements || got[i].Covered != want[i].Covered {
+			t.Errorf("coverage(%d) statements\nwant = %d/%d\ngot  = %d/%d", i, want[i].Covered, want[i].Statements, got[i].Covered, got[i].Statements)
+		}
+
 		if got[i].Time != want[i].Time {
 			t.Errorf("coverage(%d) time\nwant = %s\ngot  = %s", i, want[i].Time, got[i].Time)
 		}
@@ -0,0 +1,115 @@
+package patch
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrHunk means a hunk header of the diff cannot be read
+var ErrHunk = errors.New("diff hunk header is malformed")
+
+// Parse a unified diff as written by git diff into the lines added or modified per file, in increasing order;
+// paths are relative to the root of the repository and deleted files are left out
+func Parse(r io.Reader) (map[string][]int, error) {
+	var (
+		scanner = bufio.NewScanner(r)
+		changes = make(map[string][]int)
+		file    string
+		line    int
+		old     int // lines of the hunk left on the old side
+		current int // lines of the hunk left on the new side
+	)
+
+	scanner.Buffer(nil, 1024*1024)
+
+	for scanner.Scan() {
+		var text = scanner.Text()
+
+		if old > 0 || current > 0 {
+			switch {
+			case strings.HasPrefix(text, "+"):
+				if file != "" {
+					changes[file] = append(changes[file], line)
+				}
+
+				line++
+				current--
+			case strings.HasPrefix(text, "-"):
+				old--
+			case strings.HasPrefix(text, `\`): // \ No newline at end of file
+			default: // context, some tools trim the leading space of empty lines
+				line++
+				old--
+				current--
+			}
+
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(text, "+++ "):
+			file = name(text[4:])
+		case strings.HasPrefix(text, "@@ "):
+			var err error
+			if line, old, current, err = hunk(text); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// name of a file out of a +++ line; empty for /dev/null
+func name(v string) string {
+	if i := strings.IndexByte(v, '\t'); i != -1 { // timestamps of diff -u
+		v = v[:i]
+	}
+
+	if v == "/dev/null" {
+		return ""
+	}
+
+	return strings.TrimPrefix(v, "b/")
+}
+
+// hunk header e.g. @@ -1,4 +1,5 @@ into the first line on the new side and the line counts of both sides
+func hunk(v string) (line, old, current int, err error) {
+	var fields = strings.Fields(v)
+	if len(fields) < 4 || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0, ErrHunk
+	}
+
+	if _, old, err = span(fields[1][1:]); err != nil {
+		return 0, 0, 0, ErrHunk
+	}
+
+	if line, current, err = span(fields[2][1:]); err != nil {
+		return 0, 0, 0, ErrHunk
+	}
+
+	return line, old, current, nil
+}
+
+// span of a hunk e.g. 1,4 or 3 which is 3,1
+func span(v string) (start, count int, err error) {
+	var i = strings.IndexByte(v, ',')
+	if i == -1 {
+		start, err = strconv.Atoi(v)
+		return start, 1, err
+	}
+
+	if start, err = strconv.Atoi(v[:i]); err != nil {
+		return 0, 0, err
+	}
+
+	count, err = strconv.Atoi(v[i+1:])
+	return start, count, err
+}
@@ -0,0 +1,88 @@
+package patch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const diff = `diff --git a/foo.go b/foo.go
+index 3b18e51..a9c3f1e 100644
+--- a/foo.go
++++ b/foo.go
+@@ -1,5 +1,7 @@
+ package foo
+ 
+-func Sum(a, b int) int {
++// Sum of two numbers
++func Sum(a, b int) int {
+ 	return a + b
+ }
++
+@@ -10,2 +12,3 @@ func Sub(a, b int) int {
+ 	return a - b
++	// unreachable
+ }
+diff --git a/bar/bar.go b/bar/bar.go
+new file mode 100644
+--- /dev/null
++++ b/bar/bar.go
+@@ -0,0 +1,3 @@
++package bar
++
++++ a line starting with plus signs
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package old
+-
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		diff    string
+		want    map[string][]int
+		wantErr error
+	}{
+		{
+			name: "empty",
+			diff: "",
+			want: map[string][]int{},
+		},
+		{
+			name: "modified, new and deleted files",
+			diff: diff,
+			want: map[string][]int{
+				"foo.go":     {3, 4, 7, 13},
+				"bar/bar.go": {1, 2, 3},
+			},
+		},
+		{
+			name: "without prefix nor count",
+			diff: "--- foo.go\t2021-03-01 10:00:00\n+++ foo.go\t2021-03-02 10:00:00\n@@ -3 +3 @@\n-a\n+b\n",
+			want: map[string][]int{"foo.go": {3}},
+		},
+		{
+			name:    "malformed hunk",
+			diff:    "+++ b/foo.go\n@@ -1,x +1 @@\n",
+			wantErr: ErrHunk,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, err = Parse(strings.NewReader(tt.diff))
+			if err != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse()\nwant = %v\ngot  = %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -1,41 +1,100 @@
 package flat
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/fluxynet/goqa"
 )
 
 const (
-	filename = "goqa.repo.json"
+	filename        = "goqa.repo.json"
+	historyFilename = "goqa.history.jsonl"
+
+	// filesDirname holds the files of each record, kept out of the history log as they are large
+	filesDirname = "goqa.files"
+
+	// compactEvery so many appends, the history log is compacted
+	compactEvery = 100
 )
 
 type filewriterFunc func(name string, data []byte, perm os.FileMode) error
 type filereaderFunc func(name string) ([]byte, error)
+type fileappenderFunc func(name string, data []byte, perm os.FileMode) error
+type filerenamerFunc func(oldpath, newpath string) error
+type dirmakerFunc func(path string, perm os.FileMode) error
 
 var (
-	filewriter filewriterFunc = os.WriteFile
-	filereader filereaderFunc = os.ReadFile
+	filewriter   filewriterFunc   = os.WriteFile
+	filereader   filereaderFunc   = os.ReadFile
+	fileappender fileappenderFunc = appendFile
+	filerenamer  filerenamerFunc  = os.Rename
+	dirmaker     dirmakerFunc     = os.MkdirAll
 )
 
-type Flat struct{}
+// appendFile writes data at the end of a file, creating it if need be
+func appendFile(name string, data []byte, perm os.FileMode) error {
+	var f, err = os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+
+	return err
+}
+
+type Flat struct {
+	mut sync.Mutex
+
+	// appended records since the last compaction
+	appended int
+}
 
 func New() *Flat {
 	return &Flat{}
 }
 
-func (f Flat) Save(ctx context.Context, covs ...goqa.Coverage) error {
-	var (
-		b   []byte
-		err error
-	)
+func (f *Flat) Save(ctx context.Context, repository, ref string, covs ...goqa.Coverage) error {
+	defer f.mut.Unlock()
+	f.mut.Lock()
+
+	var existing, err = f.load()
+	if err != nil {
+		return err
+	}
+
+	var merged = make([]goqa.Coverage, 0, len(existing)+len(covs))
+	for i := range existing {
+		if existing[i].Repository != repository || existing[i].Ref != ref {
+			merged = append(merged, existing[i])
+		}
+	}
+
+	for i := range covs {
+		var c = covs[i]
+		c.Repository = repository
+		c.Ref = ref
+
+		merged = append(merged, c)
+	}
+
+	var b []byte
 
-	if len(covs) == 0 {
+	if len(merged) == 0 {
 		b = []byte("[]")
 	} else {
-		b, err = json.Marshal(covs)
+		b, err = json.Marshal(merged)
 	}
 
 	if err != nil {
@@ -45,21 +104,242 @@ func (f Flat) Save(ctx context.Context, covs ...goqa.Coverage) error {
 	return filewriter(filename, b, 0644)
 }
 
-func (f Flat) Load(ctx context.Context) ([]goqa.Coverage, error) {
+func (f *Flat) Load(ctx context.Context) ([]goqa.Coverage, error) {
+	defer f.mut.Unlock()
+	f.mut.Lock()
+
+	return f.load()
+}
+
+func (f *Flat) load() ([]goqa.Coverage, error) {
 	var b, err = filereader(filename)
 
 	if os.IsNotExist(err) {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
+	} else if len(b) == 0 {
+		return nil, nil
 	}
 
 	var covs []goqa.Coverage
-	err = json.Unmarshal(b, &covs)
+	if err = json.Unmarshal(b, &covs); err != nil {
+		return nil, err
+	}
+
+	for i := range covs {
+		covs[i] = covs[i].Migrate()
+	}
+
+	return covs, nil
+}
+
+// Append a record to the history log; one json document per line, files of the record are written aside
+func (f *Flat) Append(ctx context.Context, rec goqa.Record) error {
+	defer f.mut.Unlock()
+	f.mut.Lock()
+
+	// files first so that a record in the log always has its files
+	var err = f.keep(rec)
+	if err != nil {
+		return err
+	}
+
+	rec.Files = nil
+
+	var b []byte
+	if b, err = json.Marshal(rec); err != nil {
+		return err
+	}
+
+	err = fileappender(historyFilename, append(b, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+
+	f.appended++
+	if f.appended < compactEvery {
+		return nil
+	}
+
+	return f.compact()
+}
+
+func (f *Flat) History(ctx context.Context, q goqa.HistoryQuery) ([]goqa.Record, error) {
+	defer f.mut.Unlock()
+	f.mut.Lock()
+
+	var recs, _, err = f.history()
+	if err != nil {
+		return nil, err
+	}
+
+	recs = q.Filter(recs)
+
+	for i := range recs {
+		if !q.Files {
+			recs[i].Files = nil
+			continue
+		} else if len(recs[i].Files) != 0 {
+			continue // appended before files were kept aside
+		}
+
+		if recs[i].Files, err = f.files(recs[i]); err != nil {
+			return nil, err
+		}
+
+		if q.Pkg != "" {
+			recs[i] = recs[i].Only(q.Pkg)
+		}
+	}
+
+	return recs, nil
+}
+
+// Compact the history log; superseded records and lines which cannot be read (e.g. a write interrupted by a crash) are dropped
+func (f *Flat) Compact(ctx context.Context) error {
+	defer f.mut.Unlock()
+	f.mut.Lock()
+
+	return f.compact()
+}
+
+func (f *Flat) compact() error {
+	var recs, lines, err = f.history()
+	if err != nil {
+		return err
+	}
+
+	f.appended = 0
+
+	var inline int
+	for i := range recs {
+		if len(recs[i].Files) == 0 {
+			continue
+		}
+
+		// appended before files were kept aside
+		if err = f.keep(recs[i]); err != nil {
+			return err
+		}
+
+		recs[i].Files = nil
+		inline++
+	}
+
+	if lines == len(recs) && inline == 0 {
+		return nil // nothing to gain
+	}
+
+	var buf bytes.Buffer
+	for i := range recs {
+		var b, err = json.Marshal(recs[i])
+		if err != nil {
+			return err
+		}
+
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	// write aside then rename so that a crash midway leaves the log intact
+	var tmp = historyFilename + ".tmp"
+	if err = filewriter(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	return filerenamer(tmp, historyFilename)
+}
+
+// history reads all records in the log, in order of appending, along with the number of lines read
+func (f *Flat) history() ([]goqa.Record, int, error) {
+	var b, err = filereader(historyFilename)
+
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		recs    []goqa.Record
+		indexes = make(map[string]int)
+		lines   int
+		scanner = bufio.NewScanner(bytes.NewReader(b))
+	)
+
+	scanner.Buffer(nil, len(b)+1)
+
+	for scanner.Scan() {
+		var line = scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		lines++
+
+		var rec goqa.Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			continue
+		}
+
+		for i := range rec.Coverage {
+			rec.Coverage[i] = rec.Coverage[i].Migrate()
+		}
+
+		if i, ok := indexes[rec.ID()]; ok {
+			recs[i] = rec
+		} else {
+			indexes[rec.ID()] = len(recs)
+			recs = append(recs, rec)
+		}
+	}
+
+	return recs, lines, scanner.Err()
+}
+
+// filesFilename of a record; a record superseding another replaces its files
+func filesFilename(rec goqa.Record) string {
+	var sum = sha1.Sum([]byte(rec.ID()))
+	return filepath.Join(filesDirname, hex.EncodeToString(sum[:])+".json")
+}
+
+// keep the files of a record aside from the history log
+func (f *Flat) keep(rec goqa.Record) error {
+	if len(rec.Files) == 0 {
+		return nil
+	}
+
+	var b, err = json.Marshal(rec.Files)
+	if err != nil {
+		return err
+	}
+
+	if err = dirmaker(filesDirname, 0755); err != nil {
+		return err
+	}
+
+	return filewriter(filesFilename(rec), b, 0644)
+}
+
+// files of a record kept aside; nil when it has none
+func (f *Flat) files(rec goqa.Record) ([]goqa.FileCoverage, error) {
+	var b, err = filereader(filesFilename(rec))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var files []goqa.FileCoverage
+	if err = json.Unmarshal(b, &files); err != nil {
+		return nil, err
+	}
 
-	return covs, err
+	return files, nil
 }
 
-func (f Flat) Close() error {
+func (f *Flat) Close() error {
 	return nil
 }
@@ -3,12 +3,14 @@ package flat
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fluxynet/goqa"
 )
@@ -40,18 +42,18 @@ func assertCoveragesEqual(t *testing.T, got, want []goqa.Coverage) {
 
 	mgot := make(map[string]goqa.Coverage, lg)
 	for i := range got {
-		mgot[got[i].Pkg] = got[i]
+		mgot[got[i].Key().String()] = got[i]
 	}
 
 	mwant := make(map[string]goqa.Coverage, lw)
 	for i := range want {
-		mwant[want[i].Pkg] = want[i]
+		mwant[want[i].Key().String()] = want[i]
 	}
 
 	for k, w := range mwant {
 		var (
 			g    = mgot[k]
-			same = g.Percentage == w.Percentage && g.Pkg == w.Pkg && g.Time == w.Time
+			same = g.Percentage == w.Percentage && g.Key() == w.Key() && g.Time == w.Time
 		)
 
 		if !same {
@@ -148,6 +150,8 @@ func makeCoverages(t, skip int) []goqa.Coverage {
 	for i := skip; i < t; i++ {
 		var pkg = "pkg." + strconv.Itoa(i)
 		m[n] = goqa.Coverage{
+			Repository: "repo",
+			Ref:        "ref",
 			Pkg:        pkg,
 			Percentage: i,
 			Time:       "2006-01-02T15:04:05Z07:00",
@@ -234,7 +238,7 @@ func TestFlat_LoadSave(t *testing.T) {
 			filewriter = faker.write
 			filereader = faker.read
 
-			err := f.Save(context.Background(), tt.args.covs...)
+			err := f.Save(context.Background(), "repo", "ref", tt.args.covs...)
 
 			if err != nil {
 				t.Errorf("writer err is not nil, %s\n%s", err.Error(), faker.String())
@@ -260,10 +264,11 @@ func TestFlat_LoadSave(t *testing.T) {
 			filewriter = gfaker.write
 			filereader = faker.read
 
-			err := f.Save(context.Background(), tt.args.covs...)
+			// existing data is merged on save, so it must be readable
+			err := f.Save(context.Background(), "repo", "ref", tt.args.covs...)
 
-			if err != nil {
-				t.Errorf("writer err is not nil, %s\n", err.Error())
+			if !errors.Is(err, errRead) {
+				t.Errorf("save did not fail with read error: %v", err)
 				return
 			}
 
@@ -293,9 +298,12 @@ func TestFlat_LoadSave(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			f := Flat{}
 			faker := badReadWriter{err: errWrite}
+			gfaker := fakereadwriter{}
+
 			filewriter = faker.write
+			filereader = gfaker.read
 
-			err := f.Save(context.Background(), tt.args.covs...)
+			err := f.Save(context.Background(), "repo", "ref", tt.args.covs...)
 
 			if tt.mustNotErr && err != nil {
 				t.Errorf("%s must not err, but gave an error anyway", tt.name)
@@ -313,3 +321,406 @@ func TestFlat_LoadSave(t *testing.T) {
 		})
 	}
 }
+
+func TestFlat_SaveMerge(t *testing.T) {
+	var (
+		oldfilewriter = filewriter
+		oldfilereader = filereader
+	)
+
+	defer func() {
+		filewriter = oldfilewriter
+		filereader = oldfilereader
+	}()
+
+	faker := &fakereadwriter{}
+	filewriter = faker.write
+	filereader = faker.read
+
+	var (
+		f  = Flat{}
+		a1 = goqa.Coverage{Repository: "a", Ref: "master", Pkg: "pkg.1", Percentage: 10}
+		a2 = goqa.Coverage{Repository: "a", Ref: "master", Pkg: "pkg.2", Percentage: 20}
+		ad = goqa.Coverage{Repository: "a", Ref: "develop", Pkg: "pkg.1", Percentage: 30}
+		b1 = goqa.Coverage{Repository: "b", Ref: "master", Pkg: "pkg.1", Percentage: 40}
+		a3 = goqa.Coverage{Repository: "a", Ref: "master", Pkg: "pkg.3", Percentage: 50}
+	)
+
+	var saves = []struct {
+		repository string
+		ref        string
+		covs       []goqa.Coverage
+	}{
+		{"a", "master", []goqa.Coverage{a1, a2}},
+		{"a", "develop", []goqa.Coverage{ad}},
+		{"b", "master", []goqa.Coverage{b1}},
+		{"a", "master", []goqa.Coverage{{Pkg: "pkg.3", Percentage: 50}}},
+	}
+
+	for i := range saves {
+		if err := f.Save(context.Background(), saves[i].repository, saves[i].ref, saves[i].covs...); err != nil {
+			t.Errorf("Save(%d) error = %v", i, err)
+			return
+		}
+	}
+
+	covs, err := f.Load(context.Background())
+	if err != nil {
+		t.Errorf("Load() error = %v", err)
+		return
+	}
+
+	assertCoveragesEqual(t, covs, []goqa.Coverage{ad, b1, a3})
+}
+
+type fakefs struct {
+	files map[string][]byte
+}
+
+func (f *fakefs) write(name string, data []byte, perm os.FileMode) error {
+	f.files[name] = data
+	return nil
+}
+
+func (f *fakefs) read(name string) ([]byte, error) {
+	var b, ok = f.files[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return b, nil
+}
+
+func (f *fakefs) append(name string, data []byte, perm os.FileMode) error {
+	f.files[name] = append(f.files[name], data...)
+	return nil
+}
+
+func (f *fakefs) rename(oldpath, newpath string) error {
+	f.files[newpath] = f.files[oldpath]
+	delete(f.files, oldpath)
+	return nil
+}
+
+func (f *fakefs) mkdir(path string, perm os.FileMode) error {
+	return nil
+}
+
+func useFakefs(t *testing.T) *fakefs {
+	var (
+		oldfilewriter   = filewriter
+		oldfilereader   = filereader
+		oldfileappender = fileappender
+		oldfilerenamer  = filerenamer
+		olddirmaker     = dirmaker
+		fs              = &fakefs{files: make(map[string][]byte)}
+	)
+
+	t.Cleanup(func() {
+		filewriter = oldfilewriter
+		filereader = oldfilereader
+		fileappender = oldfileappender
+		filerenamer = oldfilerenamer
+		dirmaker = olddirmaker
+	})
+
+	filewriter = fs.write
+	filereader = fs.read
+	fileappender = fs.append
+	filerenamer = fs.rename
+	dirmaker = fs.mkdir
+
+	return fs
+}
+
+func makeRecord(commit, time string, percs ...int) goqa.Record {
+	var rec = goqa.Record{
+		Repository: "acme/foo",
+		Commit:     commit,
+		Ref:        "refs/heads/master",
+		Workflow:   "Go",
+		Time:       time,
+	}
+
+	for i := range percs {
+		rec.Coverage = append(rec.Coverage, goqa.Coverage{
+			Repository: rec.Repository,
+			Ref:        rec.Ref,
+			Pkg:        "pkg." + strconv.Itoa(i),
+			Percentage: percs[i],
+			Time:       time,
+		})
+	}
+
+	return rec
+}
+
+func commitsOf(recs []goqa.Record) string {
+	var commits []string
+	for i := range recs {
+		commits = append(commits, recs[i].Commit)
+	}
+
+	return strings.Join(commits, ",")
+}
+
+func TestFlat_Migrate(t *testing.T) {
+	var fs = useFakefs(t)
+
+	fs.files[filename] = []byte(`[` +
+		`{"repository":"acme/foo","ref":"master","pkg":"foo","percentage":83,"time":"t"},` +
+		`{"repository":"acme/foo","ref":"master","pkg":"bar","percentage":66,"statements":3,"covered":2,"time":"t"},` +
+		`{"repository":"acme/foo","ref":"master","pkg":"baz","percentage":83,"precise":83.3,"time":"t"}]`)
+
+	fs.files[historyFilename] = []byte(`{"repository":"acme/foo","commit":"c1","ref":"master","coverage":[{"pkg":"foo","percentage":83}]}` + "\n")
+
+	var want = map[string]float64{"foo": 83, "bar": 66.7, "baz": 83.3}
+
+	var f = New()
+
+	covs, err := f.Load(context.Background())
+	if err != nil {
+		t.Errorf("Load() error = %v", err)
+		return
+	}
+
+	if len(covs) != len(want) {
+		t.Errorf("Load() = %v", covs)
+		return
+	}
+
+	for i := range covs {
+		if covs[i].Precise != want[covs[i].Pkg] {
+			t.Errorf("Load() precise of %s\nwant = %g\ngot  = %g", covs[i].Pkg, want[covs[i].Pkg], covs[i].Precise)
+		}
+	}
+
+	recs, err := f.History(context.Background(), goqa.HistoryQuery{})
+	if err != nil {
+		t.Errorf("History() error = %v", err)
+		return
+	}
+
+	if len(recs) != 1 || len(recs[0].Coverage) != 1 || recs[0].Coverage[0].Precise != 83 {
+		t.Errorf("History() = %+v", recs)
+	}
+}
+
+func TestFlat_History(t *testing.T) {
+	var fs = useFakefs(t)
+
+	var (
+		f    = New()
+		recs = []goqa.Record{
+			makeRecord("c2", "2021-02-01T00:00:00Z", 20, 30),
+			makeRecord("c1", "2021-01-01T00:00:00Z", 10),
+			makeRecord("c3", "2021-03-01T00:00:00Z", 40, 50),
+			makeRecord("c2", "2021-02-01T00:00:00Z", 25, 35), // redelivery
+		}
+	)
+
+	for i := range recs {
+		if err := f.Append(context.Background(), recs[i]); err != nil {
+			t.Errorf("Append(%d) error = %v", i, err)
+			return
+		}
+	}
+
+	// a write interrupted midway
+	fs.files[historyFilename] = append(fs.files[historyFilename], []byte(`{"repository":"acme/fo`)...)
+
+	tests := []struct {
+		name  string
+		query goqa.HistoryQuery
+		want  string
+	}{
+		{
+			name:  "all",
+			query: goqa.HistoryQuery{},
+			want:  "c1,c2,c3",
+		},
+		{
+			name:  "by package",
+			query: goqa.HistoryQuery{Pkg: "pkg.1"},
+			want:  "c2,c3",
+		},
+		{
+			name:  "by commit",
+			query: goqa.HistoryQuery{Commit: "c3"},
+			want:  "c3",
+		},
+		{
+			name: "by time range",
+			query: goqa.HistoryQuery{
+				From: time.Date(2021, 1, 15, 0, 0, 0, 0, time.UTC),
+				To:   time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+			want: "c2",
+		},
+		{
+			name:  "other repository",
+			query: goqa.HistoryQuery{Repository: "acme/bar"},
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.History(context.Background(), tt.query)
+			if err != nil {
+				t.Errorf("History() error = %v", err)
+				return
+			}
+
+			if c := commitsOf(got); c != tt.want {
+				t.Errorf("History() commits\nwant = %s\ngot  = %s", tt.want, c)
+			}
+		})
+	}
+
+	t.Run("superseded", func(t *testing.T) {
+		got, _ := f.History(context.Background(), goqa.HistoryQuery{Commit: "c2", Pkg: "pkg.0"})
+		if len(got) != 1 || len(got[0].Coverage) != 1 || got[0].Coverage[0].Percentage != 25 {
+			t.Errorf("redelivered record did not supersede: %v", got)
+		}
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		if err := f.Compact(context.Background()); err != nil {
+			t.Errorf("Compact() error = %v", err)
+			return
+		}
+
+		if _, ok := fs.files[historyFilename+".tmp"]; ok {
+			t.Errorf("temporary file left behind")
+		}
+
+		if n := bytes.Count(fs.files[historyFilename], []byte("\n")); n != 3 {
+			t.Errorf("lines after compaction: want = 3, got = %d", n)
+		}
+
+		got, _ := f.History(context.Background(), goqa.HistoryQuery{})
+		if c := commitsOf(got); c != "c1,c2,c3" {
+			t.Errorf("History() after compaction: %s", c)
+		}
+	})
+}
+
+func TestFlat_AppendCompacts(t *testing.T) {
+	var (
+		fs = useFakefs(t)
+		f  = New()
+	)
+
+	for i := 0; i < compactEvery; i++ {
+		if err := f.Append(context.Background(), makeRecord("c", "2021-01-01T00:00:00Z", i)); err != nil {
+			t.Errorf("Append(%d) error = %v", i, err)
+			return
+		}
+	}
+
+	if n := bytes.Count(fs.files[historyFilename], []byte("\n")); n != 1 {
+		t.Errorf("lines after automatic compaction: want = 1, got = %d", n)
+	}
+
+	if f.appended != 0 {
+		t.Errorf("appended not reset: %d", f.appended)
+	}
+}
+
+func TestFlat_HistoryFiles(t *testing.T) {
+	var (
+		fs    = useFakefs(t)
+		f     = New()
+		rec   = makeRecord("c1", "2021-01-01T00:00:00Z", 10, 20)
+		files = []goqa.FileCoverage{
+			{Pkg: "pkg.0", File: "pkg.0/foo.go", Statements: 2, Covered: 1, Source: "package foo\n"},
+			{Pkg: "pkg.1", File: "pkg.1/bar.go", Statements: 4, Covered: 4},
+		}
+	)
+
+	rec.Files = files
+
+	if err := f.Append(context.Background(), rec); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	// appended before files were kept aside
+	var legacy = makeRecord("c0", "2020-12-01T00:00:00Z", 5)
+	legacy.Files = files[:1]
+
+	var b, _ = json.Marshal(legacy)
+	fs.files[historyFilename] = append(append(b, '\n'), fs.files[historyFilename]...)
+
+	if bytes.Contains(fs.files[historyFilename][len(b):], []byte(`"files"`)) {
+		t.Errorf("files written in the history log:\n%s", fs.files[historyFilename])
+	}
+
+	tests := []struct {
+		name  string
+		query goqa.HistoryQuery
+		want  string // files of each record
+	}{
+		{
+			name:  "not asked for",
+			query: goqa.HistoryQuery{},
+			want:  ",",
+		},
+		{
+			name:  "asked for",
+			query: goqa.HistoryQuery{Files: true},
+			want:  "pkg.0/foo.go,pkg.0/foo.go pkg.1/bar.go",
+		},
+		{
+			name:  "of a package",
+			query: goqa.HistoryQuery{Pkg: "pkg.1", Files: true},
+			want:  "pkg.1/bar.go",
+		},
+		{
+			name:  "of a commit",
+			query: goqa.HistoryQuery{Commit: "c1", Files: true},
+			want:  "pkg.0/foo.go pkg.1/bar.go",
+		},
+	}
+
+	var check = func(t *testing.T, query goqa.HistoryQuery, want string) {
+		t.Helper()
+
+		var got, err = f.History(context.Background(), query)
+		if err != nil {
+			t.Fatalf("History() error = %v", err)
+		}
+
+		var names []string
+		for i := range got {
+			var files []string
+			for j := range got[i].Files {
+				files = append(files, got[i].Files[j].File)
+			}
+
+			names = append(names, strings.Join(files, " "))
+		}
+
+		if s := strings.Join(names, ","); s != want {
+			t.Errorf("History() files\nwant = %s\ngot  = %s", want, s)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check(t, tt.query, tt.want)
+		})
+	}
+
+	t.Run("compacted", func(t *testing.T) {
+		if err := f.Compact(context.Background()); err != nil {
+			t.Fatalf("Compact() error = %v", err)
+		}
+
+		if bytes.Contains(fs.files[historyFilename], []byte(`"files"`)) {
+			t.Errorf("files left in the history log:\n%s", fs.files[historyFilename])
+		}
+
+		check(t, goqa.HistoryQuery{Files: true}, "pkg.0/foo.go,pkg.0/foo.go pkg.1/bar.go")
+	})
+}
@@ -0,0 +1,74 @@
+package goqa
+
+import "strings"
+
+const (
+	// TestPass test passed
+	TestPass = "pass"
+
+	// TestFail test failed, or never reported an outcome
+	TestFail = "fail"
+
+	// TestSkip test was skipped
+	TestSkip = "skip"
+)
+
+// TestResult is the outcome of a test or subtest as reported by go test -json
+type TestResult struct {
+	Pkg string `json:"pkg"`
+
+	// Test name; subtests are separated by slashes e.g. TestSum/Empty
+	Test string `json:"test"`
+
+	// Status is one of TestPass, TestFail or TestSkip
+	Status string `json:"status"`
+
+	// Elapsed time in seconds
+	Elapsed float64 `json:"elapsed"`
+
+	// Retries is how many times the test ran again within the same run e.g. with -count or a rerun of failures
+	Retries int `json:"retries,omitempty"`
+
+	// Failures is how many attempts failed before the last one
+	Failures int `json:"failures,omitempty"`
+
+	// Output captured while the test ran
+	Output string `json:"output,omitempty"`
+
+	// Time the test started
+	Time string `json:"time"`
+}
+
+// Parent of a subtest; empty for top level tests
+func (t TestResult) Parent() string {
+	var i = strings.LastIndex(t.Test, "/")
+	if i == -1 {
+		return ""
+	}
+
+	return t.Test[:i]
+}
+
+// PassedOnRetry tells if the test eventually passed after failing within the same run
+func (t TestResult) PassedOnRetry() bool {
+	return t.Status == TestPass && t.Failures != 0
+}
+
+// String representation of a test result
+func (t TestResult) String() string {
+	return `[` + t.Time + `] pkg = "` + t.Pkg + `" test = "` + t.Test + `" ` + t.Status
+}
+
+// PackageResult is the outcome of the tests of a package as a whole
+type PackageResult struct {
+	Pkg string `json:"pkg"`
+
+	// Status is one of TestPass, TestFail or TestSkip
+	Status string `json:"status"`
+
+	// Elapsed time in seconds
+	Elapsed float64 `json:"elapsed"`
+
+	// Time the package finished
+	Time string `json:"time"`
+}
@@ -9,7 +9,7 @@ func New(c goqa.Cache) *Cache {
 	return &Cache{cache: c}
 }
 
-// Cache is a subscriber that listens to goqa.GithubEvent, updates a goqa.Cache and then emits a goqa.CoverageEvent
+// Cache is a subscriber that listens to goqa.GithubEvent and replaces the entries of its repository and ref in a goqa.Cache
 type Cache struct {
 	subscriber.Identifiable
 	cache goqa.Cache
@@ -18,7 +18,7 @@ type Cache struct {
 func (c *Cache) Notify(event goqa.Event) error {
 	var e *goqa.GithubEvent
 
-	switch v := event.(type) {
+	switch v := goqa.Open(event).(type) {
 	default:
 		return subscriber.ErrUnsupportedEvent
 	case *goqa.GithubEvent:
@@ -27,6 +27,10 @@ func (c *Cache) Notify(event goqa.Event) error {
 		e = &v
 	}
 
-	var err = c.cache.Reset(e.Coverage...)
+	if len(e.Coverage) == 0 {
+		return nil // only tests were run; keep what we know
+	}
+
+	var err = c.cache.Replace(e.Repository, e.Ref, e.Coverage...)
 	return err
 }
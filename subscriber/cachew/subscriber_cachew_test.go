@@ -9,19 +9,27 @@ import (
 )
 
 type fakecache struct {
-	covs []goqa.Coverage
+	repository string
+	ref        string
+	covs       []goqa.Coverage
 }
 
 func (f *fakecache) Reset(covs ...goqa.Coverage) error {
+	panic("not implemented")
+}
+
+func (f *fakecache) Replace(repository, ref string, covs ...goqa.Coverage) error {
+	f.repository = repository
+	f.ref = ref
 	f.covs = covs
 	return nil
 }
 
-func (f *fakecache) Get(pkg string) (*goqa.Coverage, bool) {
+func (f *fakecache) Get(key goqa.Key) (*goqa.Coverage, bool) {
 	panic("not implemented")
 }
 
-func (f *fakecache) Keys() ([]string, error) {
+func (f *fakecache) Keys() ([]goqa.Key, error) {
 	panic("not implemented")
 }
 
@@ -277,6 +285,10 @@ func TestCache_Notify(t *testing.T) {
 			}
 
 			internal.AssertCoveragesEqual(t, cache.covs, tt.want)
+
+			if e, ok := tt.args.event.(goqa.GithubEvent); ok && (cache.repository != e.Repository || cache.ref != e.Ref) {
+				t.Errorf("replaced\nwant = %s@%s\ngot  = %s@%s", e.Repository, e.Ref, cache.repository, cache.ref)
+			}
 		})
 	}
 }
@@ -2,26 +2,38 @@ package coverage
 
 import (
 	"context"
+	"sync"
 
 	"github.com/fluxynet/goqa"
 	"github.com/fluxynet/goqa/subscriber"
 )
 
+// Source of the coverage events published, see goqa.Envelope
+const Source = "subscriber/coverage"
+
+// Coverage is a subscriber that listens to goqa.GithubEvent and emits a goqa.CoverageEvent for every package whose coverage changed
+// along with a goqa.CoverageDeltaEvent when a previous value was known for the same repository and ref,
+// then a goqa.AggregateEvent for the whole repository and ref when anything changed
 type Coverage struct {
 	subscriber.Identifiable
 	broker goqa.Broker
+
+	// previous values; distinct from the cache used by the web server as subscribers are notified in no particular order
+	previous goqa.Cache
+	mut      sync.Mutex
 }
 
-func New(broker goqa.Broker) *Coverage {
+func New(broker goqa.Broker, previous goqa.Cache) *Coverage {
 	return &Coverage{
-		broker: broker,
+		broker:   broker,
+		previous: previous,
 	}
 }
 
-func (c Coverage) Notify(event goqa.Event) error {
+func (c *Coverage) Notify(event goqa.Event) error {
 	var e *goqa.GithubEvent
 
-	switch v := event.(type) {
+	switch v := goqa.Open(event).(type) {
 	default:
 		return subscriber.ErrUnsupportedEvent
 	case *goqa.GithubEvent:
@@ -30,14 +42,66 @@ func (c Coverage) Notify(event goqa.Event) error {
 		e = &v
 	}
 
+	if len(e.Coverage) == 0 {
+		return nil
+	}
+
+	defer c.mut.Unlock()
+	c.mut.Lock()
+
+	var events []goqa.Event
+
 	for i := range e.Coverage {
-		var ev = goqa.CoverageEvent{
-			Pkg:        e.Coverage[i].Pkg,
-			Percentage: e.Coverage[i].Percentage,
-			Time:       e.Coverage[i].Time,
+		var (
+			cov = e.Coverage[i]
+			key = goqa.Key{Repository: e.Repository, Ref: e.Ref, Pkg: cov.Pkg}
+		)
+
+		var prev, ok = c.previous.Get(key)
+		if ok && prev.Value() == cov.Value() {
+			continue // nothing new to say
+		}
+
+		events = append(events, goqa.CoverageEvent{
+			Repository: e.Repository,
+			Ref:        e.Ref,
+			Pkg:        cov.Pkg,
+			Percentage: cov.Percentage,
+			Precise:    cov.Value(),
+			Statements: cov.Statements,
+			Covered:    cov.Covered,
+			Time:       cov.Time,
+		})
+
+		if ok {
+			events = append(events, goqa.CoverageDeltaEvent{
+				Repository: e.Repository,
+				Ref:        e.Ref,
+				Commit:     e.Commit,
+				Pkg:        cov.Pkg,
+				Old:        prev.Value(),
+				New:        cov.Value(),
+				Time:       cov.Time,
+			})
 		}
+	}
+
+	var err = c.previous.Replace(e.Repository, e.Ref, e.Coverage...)
+	if err != nil {
+		return err
+	}
+
+	if len(events) != 0 {
+		var covs []goqa.Coverage
+		if covs, err = goqa.Coverages(c.previous, goqa.Key{Repository: e.Repository, Ref: e.Ref}); err != nil {
+			return err
+		}
+
+		events = append(events, goqa.AggregateEvent(goqa.Aggregated(e.Repository, e.Ref, "", covs)))
+	}
 
-		err := c.broker.Publish(context.Background(), ev)
+	for i := range events {
+		err = c.broker.Publish(context.Background(), goqa.Derive(event, Source, events[i]))
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,192 @@
+package coverage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/cache/memory"
+	"github.com/fluxynet/goqa/subscriber"
+)
+
+type fakebroker struct {
+	events    []goqa.Event
+	envelopes []goqa.Envelope
+}
+
+func (f *fakebroker) Listen(ctx context.Context) (<-chan goqa.Event, error) {
+	return nil, nil
+}
+
+func (f *fakebroker) Publish(ctx context.Context, event goqa.Event) error {
+	var e, _ = goqa.Enveloped(event)
+
+	f.events = append(f.events, goqa.Open(event))
+	f.envelopes = append(f.envelopes, e)
+	return nil
+}
+
+func (f *fakebroker) Close() error {
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	t.Run("New", func(t *testing.T) {
+		var _ goqa.Subscriber = New(nil, nil)
+	})
+}
+
+func TestCoverage_Notify(t *testing.T) {
+	var previous = []goqa.Coverage{
+		{Repository: "acme/foo", Ref: "master", Pkg: "foo", Percentage: 50},
+		{Repository: "acme/foo", Ref: "master", Pkg: "bar", Percentage: 60},
+		{Repository: "acme/foo", Ref: "master", Pkg: "baz", Percentage: 70},
+		{Repository: "acme/foo", Ref: "develop", Pkg: "qux", Percentage: 10},
+		{Repository: "acme/foo", Ref: "release", Pkg: "foo", Percentage: 83, Precise: 83.3},
+	}
+
+	tests := []struct {
+		name    string
+		event   goqa.Event
+		want    []goqa.Event
+		wantErr error
+	}{
+		{
+			name:    "unsupported event",
+			event:   goqa.CoverageEvent{Pkg: "foo"},
+			wantErr: subscriber.ErrUnsupportedEvent,
+		},
+		{
+			name: "unchanged, down, up and new",
+			event: goqa.GithubEvent{
+				Repository: "acme/foo",
+				Ref:        "master",
+				Commit:     "c1",
+				Coverage: []goqa.Coverage{
+					{Pkg: "foo", Percentage: 50, Time: "t"},
+					{Pkg: "bar", Percentage: 55, Time: "t"},
+					{Pkg: "baz", Percentage: 75, Time: "t"},
+					{Pkg: "qux", Percentage: 20, Time: "t"},
+				},
+			},
+			want: []goqa.Event{
+				goqa.CoverageEvent{Repository: "acme/foo", Ref: "master", Pkg: "bar", Percentage: 55, Precise: 55, Time: "t"},
+				goqa.CoverageDeltaEvent{Repository: "acme/foo", Ref: "master", Commit: "c1", Pkg: "bar", Old: 60, New: 55, Time: "t"},
+				goqa.CoverageEvent{Repository: "acme/foo", Ref: "master", Pkg: "baz", Percentage: 75, Precise: 75, Time: "t"},
+				goqa.CoverageDeltaEvent{Repository: "acme/foo", Ref: "master", Commit: "c1", Pkg: "baz", Old: 70, New: 75, Time: "t"},
+				goqa.CoverageEvent{Repository: "acme/foo", Ref: "master", Pkg: "qux", Percentage: 20, Precise: 20, Time: "t"},
+				goqa.AggregateEvent{Repository: "acme/foo", Ref: "master", Packages: 4, Percentage: 50, Precise: 50},
+			},
+		},
+		{
+			name: "with statements",
+			event: goqa.GithubEvent{
+				Repository: "acme/foo",
+				Ref:        "develop",
+				Commit:     "c2",
+				Coverage: []goqa.Coverage{
+					{Pkg: "qux", Percentage: 10, Statements: 10, Covered: 1, Time: "t"},
+					{Pkg: "quux", Percentage: 90, Precise: 90, Statements: 30, Covered: 27, Time: "t"},
+				},
+			},
+			want: []goqa.Event{
+				goqa.CoverageEvent{Repository: "acme/foo", Ref: "develop", Pkg: "quux", Percentage: 90, Precise: 90, Statements: 30, Covered: 27, Time: "t"},
+				goqa.AggregateEvent{Repository: "acme/foo", Ref: "develop", Packages: 2, Weighted: 2, Statements: 40, Covered: 28, Percentage: 70, Precise: 70},
+			},
+		},
+		{
+			name: "fractional drop",
+			event: goqa.GithubEvent{
+				Repository: "acme/foo",
+				Ref:        "release",
+				Commit:     "c3",
+				Coverage: []goqa.Coverage{
+					{Pkg: "foo", Percentage: 82, Precise: 82.4, Time: "t"},
+				},
+			},
+			want: []goqa.Event{
+				goqa.CoverageEvent{Repository: "acme/foo", Ref: "release", Pkg: "foo", Percentage: 82, Precise: 82.4, Time: "t"},
+				goqa.CoverageDeltaEvent{Repository: "acme/foo", Ref: "release", Commit: "c3", Pkg: "foo", Old: 83.3, New: 82.4, Time: "t"},
+				goqa.AggregateEvent{Repository: "acme/foo", Ref: "release", Packages: 1, Percentage: 82, Precise: 82.4},
+			},
+		},
+		{
+			name: "unchanged to the decimal",
+			event: goqa.GithubEvent{
+				Repository: "acme/foo",
+				Ref:        "release",
+				Commit:     "c4",
+				Coverage: []goqa.Coverage{
+					{Pkg: "foo", Percentage: 83, Precise: 83.3, Time: "t"},
+				},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				b = &fakebroker{}
+				p = memory.New()
+				c = New(b, p)
+			)
+
+			_ = p.Reset(previous...)
+
+			if err := c.Notify(tt.event); err != tt.wantErr {
+				t.Errorf("Notify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if len(b.events) != len(tt.want) {
+				t.Errorf("events length want = %d, got = %d\n%v", len(tt.want), len(b.events), b.events)
+				return
+			}
+
+			for i := range tt.want {
+				if b.events[i] != tt.want[i] {
+					t.Errorf("event(%d)\nwant = [%s] %s\ngot  = [%s] %s", i, tt.want[i].Name(), tt.want[i], b.events[i].Name(), b.events[i])
+				}
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			// notifying again is noise
+			b.events = nil
+			if err := c.Notify(tt.event); err != nil || len(b.events) != 0 {
+				t.Errorf("second Notify() error = %v, events = %v", err, b.events)
+			}
+		})
+	}
+}
+
+func TestCoverage_NotifyEnvelope(t *testing.T) {
+	var (
+		b = &fakebroker{}
+		c = New(b, memory.New())
+	)
+
+	var event = goqa.Seal("hook", &goqa.GithubEvent{
+		Repository: "acme/foo",
+		Ref:        "master",
+		Coverage:   []goqa.Coverage{{Pkg: "foo", Precise: 50}},
+	})
+
+	if err := c.Notify(event); err != nil {
+		t.Errorf("Notify() error = %v", err)
+		return
+	}
+
+	if len(b.envelopes) == 0 {
+		t.Errorf("no event published")
+	}
+
+	for i, e := range b.envelopes {
+		if e.ID == "" || e.ID == event.ID || e.Source != Source || e.Correlation != event.ID {
+			t.Errorf("envelope(%d) of %s = %#v", i, event.ID, e)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package diff
+
+import (
+	"context"
+
+	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/subscriber"
+)
+
+// Source of the goqa.DiffEvents published, see goqa.Envelope
+const Source = "subscriber/diff"
+
+// Diff is a subscriber that listens to goqa.GithubEvent of pull requests and emits a goqa.DiffEvent comparing
+// their coverage to that of their base commit, as found in history
+type Diff struct {
+	subscriber.Identifiable
+	repo   goqa.Repo
+	broker goqa.Broker
+}
+
+// New diff subscriber
+func New(repo goqa.Repo, broker goqa.Broker) *Diff {
+	return &Diff{repo: repo, broker: broker}
+}
+
+func (d *Diff) Notify(event goqa.Event) error {
+	var e *goqa.GithubEvent
+
+	switch v := goqa.Open(event).(type) {
+	default:
+		return subscriber.ErrUnsupportedEvent
+	case *goqa.GithubEvent:
+		e = v
+	case goqa.GithubEvent:
+		e = &v
+	}
+
+	if e.Base == "" || len(e.Coverage) == 0 {
+		return nil // not a pull request, or nothing to compare
+	}
+
+	var recs, err = d.repo.History(context.Background(), goqa.HistoryQuery{Repository: e.Repository, Commit: e.Base})
+	if err != nil {
+		return err
+	}
+
+	var base, ok = goqa.Latest(recs, e.Workflow)
+	if !ok {
+		return nil // base was never measured
+	}
+
+	return d.broker.Publish(context.Background(), goqa.Derive(event, Source, goqa.DiffEvent(goqa.Compare(base, e.Record()))))
+}
@@ -0,0 +1,149 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/subscriber"
+)
+
+var errHistory = errors.New("history err")
+
+type fakerepo struct {
+	recs []goqa.Record
+	err  error
+}
+
+func (f *fakerepo) Save(ctx context.Context, repository, ref string, covs ...goqa.Coverage) error {
+	panic("not implemented")
+}
+
+func (f *fakerepo) Load(ctx context.Context) ([]goqa.Coverage, error) {
+	panic("not implemented")
+}
+
+func (f *fakerepo) Append(ctx context.Context, rec goqa.Record) error {
+	panic("not implemented")
+}
+
+func (f *fakerepo) History(ctx context.Context, q goqa.HistoryQuery) ([]goqa.Record, error) {
+	return q.Filter(f.recs), f.err
+}
+
+func (f *fakerepo) Close() error {
+	return nil
+}
+
+type fakebroker struct {
+	events []goqa.Event
+}
+
+func (f *fakebroker) Listen(ctx context.Context) (<-chan goqa.Event, error) {
+	return nil, nil
+}
+
+func (f *fakebroker) Publish(ctx context.Context, event goqa.Event) error {
+	f.events = append(f.events, goqa.Open(event)) // envelopes are tested by goqa
+	return nil
+}
+
+func (f *fakebroker) Close() error {
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	t.Run("New", func(t *testing.T) {
+		var _ goqa.Subscriber = New(nil, nil)
+	})
+}
+
+func TestDiff_Notify(t *testing.T) {
+	var recs = []goqa.Record{
+		{Repository: "acme/foo", Commit: "b1", Ref: "refs/heads/master", Workflow: "Go", Coverage: []goqa.Coverage{{Pkg: "foo", Percentage: 50, Precise: 50}}},
+		{Repository: "acme/foo", Commit: "b1", Ref: "refs/heads/master", Workflow: "Lint", Coverage: []goqa.Coverage{{Pkg: "foo", Percentage: 10, Precise: 10}}},
+	}
+
+	var pr = goqa.GithubEvent{
+		Event:      "pull_request",
+		Repository: "acme/foo",
+		Commit:     "h1",
+		Ref:        "refs/pull/1/merge",
+		Head:       "feature",
+		Base:       "b1",
+		Workflow:   "Go",
+		Coverage:   []goqa.Coverage{{Pkg: "foo", Percentage: 60, Precise: 60.5, Time: "2021-03-16T07:09:32Z"}},
+	}
+
+	var other = pr
+	other.Base = "b2"
+
+	var push = pr
+	push.Base = ""
+
+	tests := []struct {
+		name    string
+		repo    *fakerepo
+		event   goqa.Event
+		want    []goqa.Event
+		wantErr error
+	}{
+		{
+			name:    "unsupported event",
+			repo:    &fakerepo{},
+			event:   goqa.CoverageEvent{},
+			wantErr: subscriber.ErrUnsupportedEvent,
+		},
+		{
+			name:  "not a pull request",
+			repo:  &fakerepo{recs: recs},
+			event: push,
+		},
+		{
+			name:  "base not measured",
+			repo:  &fakerepo{recs: recs},
+			event: &other,
+		},
+		{
+			name:    "history error",
+			repo:    &fakerepo{err: errHistory},
+			event:   pr,
+			wantErr: errHistory,
+		},
+		{
+			name:  "compared to base of same workflow",
+			repo:  &fakerepo{recs: recs},
+			event: &pr,
+			want: []goqa.Event{goqa.DiffEvent{
+				Repository: "acme/foo",
+				Ref:        "refs/pull/1/merge",
+				Head:       "feature",
+				Base:       "b1",
+				Commit:     "h1",
+				Time:       "2021-03-16T07:09:32Z",
+				Packages:   []goqa.PackageDiff{{Pkg: "foo", Base: 50, Head: 60.5, Delta: 10.5}},
+				Untested:   []string{},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				b = &fakebroker{}
+				d = New(tt.repo, b)
+			)
+
+			if err := d.Notify(tt.event); err != tt.wantErr {
+				t.Errorf("Notify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(b.events, tt.want) {
+				t.Errorf("events\nwant = %v\ngot  = %v", tt.want, b.events)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package email
 
 import (
+	"encoding/json"
 	"errors"
 
 	"github.com/fluxynet/goqa"
@@ -22,10 +23,22 @@ type Email struct {
 	Email  string
 }
 
+// Notify by email, the text of the event followed by its goqa.Envelope in json when sealed
 func (e Email) Notify(event goqa.Event) error {
 	if e.Email == "" {
 		return ErrEmailEmpty
 	}
 
-	return e.mailer.Send(event.Name(), event.String(), e.Email)
+	var message = event.String()
+
+	if envelope, ok := goqa.Enveloped(event); ok {
+		var b, err = json.MarshalIndent(envelope, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		message += "\n\n--\n" + string(b)
+	}
+
+	return e.mailer.Send(event.Name(), message, e.Email)
 }
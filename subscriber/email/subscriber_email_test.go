@@ -2,6 +2,7 @@ package email
 
 import (
 	"testing"
+	"time"
 
 	"github.com/fluxynet/goqa"
 )
@@ -90,6 +91,31 @@ func TestEmail_Notify(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "sealed event",
+			fields: fields{
+				Email: "john@doe.com",
+			},
+			args: args{
+				goqa.Envelope{
+					ID:          "e1",
+					Time:        time.Date(2021, 3, 7, 23, 9, 38, 0, time.UTC),
+					Source:      "hook",
+					Correlation: "e1",
+					Payload:     goqa.SlowTestEvent{Repository: "acme/foo", Pkg: "foo", Elapsed: 3, Baseline: 1, Factor: 3},
+				},
+			},
+			want: want{
+				subject: goqa.EventSlowTest,
+				message: "repository: acme/foo; ref: ; commit: ; pkg: foo; test: ; elapsed: 3.000s; baseline: 1.000s; factor: 3.0x\n\n--\n" +
+					"{\n  \"id\": \"e1\",\n  \"name\": \"EVENT_SLOW_TEST\",\n  \"time\": \"2021-03-07T23:09:38Z\",\n" +
+					"  \"source\": \"hook\",\n  \"correlation_id\": \"e1\",\n  \"payload\": {\n" +
+					"    \"repository\": \"acme/foo\",\n    \"ref\": \"\",\n    \"commit\": \"\",\n    \"pkg\": \"foo\",\n" +
+					"    \"test\": \"\",\n    \"elapsed\": 3,\n    \"baseline\": 1,\n    \"factor\": 3\n  }\n}",
+				recipients: []string{"john@doe.com"},
+			},
+			wantErr: nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,104 @@
+package flaky
+
+import (
+	"context"
+
+	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/flaky"
+	"github.com/fluxynet/goqa/subscriber"
+)
+
+// Source of the goqa.FlakyTestEvents published, see goqa.Envelope
+const Source = "subscriber/flaky"
+
+// Flaky is a subscriber that listens to goqa.GithubEvent and emits a goqa.FlakyTestEvent for every test of the event
+// whose flakiness score crosses the threshold
+type Flaky struct {
+	subscriber.Identifiable
+	repo      goqa.Repo
+	broker    goqa.Broker
+	threshold float64
+	window    int
+}
+
+// New flaky subscriber; zero threshold and window mean flaky.DefaultThreshold and flaky.DefaultWindow
+func New(repo goqa.Repo, broker goqa.Broker, threshold float64, window int) *Flaky {
+	if threshold <= 0 {
+		threshold = flaky.DefaultThreshold
+	}
+
+	if window <= 0 {
+		window = flaky.DefaultWindow
+	}
+
+	return &Flaky{repo: repo, broker: broker, threshold: threshold, window: window}
+}
+
+func (f *Flaky) Notify(event goqa.Event) error {
+	var e *goqa.GithubEvent
+
+	switch v := goqa.Open(event).(type) {
+	default:
+		return subscriber.ErrUnsupportedEvent
+	case *goqa.GithubEvent:
+		e = v
+	case goqa.GithubEvent:
+		e = &v
+	}
+
+	if len(e.Tests) == 0 {
+		return nil
+	}
+
+	var recs, err = f.repo.History(context.Background(), goqa.HistoryQuery{Repository: e.Repository, Ref: e.Ref})
+	if err != nil {
+		return err
+	}
+
+	// the event may or may not have been appended to history yet
+	var (
+		current = e.Record()
+		before  = make([]goqa.Record, 0, len(recs)+1)
+	)
+
+	for i := range recs {
+		if recs[i].ID() != current.ID() {
+			before = append(before, recs[i])
+		}
+	}
+
+	var previous = make(map[string]float64)
+	for _, s := range flaky.Analyze(before, f.window) {
+		previous[s.Pkg+" "+s.Test] = s.Score
+	}
+
+	var tested = make(map[string]bool, len(e.Tests))
+	for i := range e.Tests {
+		tested[e.Tests[i].Pkg+" "+e.Tests[i].Test] = true
+	}
+
+	for _, s := range flaky.Analyze(append(before, current), f.window) {
+		var k = s.Pkg + " " + s.Test
+		if !tested[k] || s.Score < f.threshold || previous[k] >= f.threshold {
+			continue
+		}
+
+		err = f.broker.Publish(context.Background(), goqa.Derive(event, Source, goqa.FlakyTestEvent{
+			Repository: s.Repository,
+			Ref:        s.Ref,
+			Commit:     e.Commit,
+			Pkg:        s.Pkg,
+			Test:       s.Test,
+			Runs:       s.Runs,
+			Flips:      s.Flips,
+			Retried:    s.Retried,
+			Score:      s.Score,
+		}))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package flaky
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/subscriber"
+)
+
+type fakerepo struct {
+	recs []goqa.Record
+}
+
+func (f *fakerepo) Save(ctx context.Context, repository, ref string, covs ...goqa.Coverage) error {
+	panic("not implemented")
+}
+
+func (f *fakerepo) Load(ctx context.Context) ([]goqa.Coverage, error) {
+	panic("not implemented")
+}
+
+func (f *fakerepo) Append(ctx context.Context, rec goqa.Record) error {
+	f.recs = append(f.recs, rec)
+	return nil
+}
+
+func (f *fakerepo) History(ctx context.Context, q goqa.HistoryQuery) ([]goqa.Record, error) {
+	return q.Filter(f.recs), nil
+}
+
+func (f *fakerepo) Close() error {
+	return nil
+}
+
+type fakebroker struct {
+	events []goqa.Event
+}
+
+func (f *fakebroker) Listen(ctx context.Context) (<-chan goqa.Event, error) {
+	return nil, nil
+}
+
+func (f *fakebroker) Publish(ctx context.Context, event goqa.Event) error {
+	f.events = append(f.events, goqa.Open(event)) // envelopes are tested by goqa
+	return nil
+}
+
+func (f *fakebroker) Close() error {
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	t.Run("New", func(t *testing.T) {
+		var _ goqa.Subscriber = New(nil, nil, 0, 0)
+	})
+}
+
+func makeEvent(commit, time, status string) goqa.GithubEvent {
+	return goqa.GithubEvent{
+		Repository: "acme/foo",
+		Ref:        "master",
+		Commit:     commit,
+		Workflow:   "Go",
+		Tests: []goqa.TestResult{
+			{Pkg: "foo", Test: "TestA", Status: status, Time: time},
+		},
+	}
+}
+
+func TestFlaky_Notify(t *testing.T) {
+	t.Run("unsupported event", func(t *testing.T) {
+		var f = New(&fakerepo{}, &fakebroker{}, 0, 0)
+		if err := f.Notify(goqa.CoverageEvent{}); err != subscriber.ErrUnsupportedEvent {
+			t.Errorf("Notify() error = %v", err)
+		}
+	})
+
+	t.Run("crossing threshold", func(t *testing.T) {
+		var (
+			r = &fakerepo{}
+			b = &fakebroker{}
+			f = New(r, b, 0.5, 10)
+		)
+
+		var events = []goqa.GithubEvent{
+			makeEvent("c1", "2021-01-01T00:00:00Z", goqa.TestPass),
+			makeEvent("c2", "2021-01-02T00:00:00Z", goqa.TestPass),
+			makeEvent("c3", "2021-01-03T00:00:00Z", goqa.TestFail), // 1 flip in 3 runs
+			makeEvent("c4", "2021-01-04T00:00:00Z", goqa.TestPass), // 2 flips in 4 runs, crosses
+			makeEvent("c5", "2021-01-05T00:00:00Z", goqa.TestFail), // 3 flips in 5 runs, already flaky
+		}
+
+		for i := range events {
+			// history is appended before flaky is notified for even events, after for odd ones
+			if i%2 == 0 {
+				_ = r.Append(context.Background(), events[i].Record())
+			}
+
+			if err := f.Notify(events[i]); err != nil {
+				t.Errorf("Notify(%d) error = %v", i, err)
+				return
+			}
+
+			if i%2 != 0 {
+				_ = r.Append(context.Background(), events[i].Record())
+			}
+		}
+
+		if len(b.events) != 1 {
+			t.Errorf("events want = 1, got = %d: %v", len(b.events), b.events)
+			return
+		}
+
+		var want = goqa.FlakyTestEvent{
+			Repository: "acme/foo",
+			Ref:        "master",
+			Commit:     "c4",
+			Pkg:        "foo",
+			Test:       "TestA",
+			Runs:       4,
+			Flips:      2,
+			Score:      0.5,
+		}
+
+		if b.events[0] != want {
+			t.Errorf("event\nwant = %v\ngot  = %v", want, b.events[0])
+		}
+	})
+}
@@ -19,7 +19,7 @@ type Repo struct {
 func (r Repo) Notify(event goqa.Event) error {
 	var e *goqa.GithubEvent
 
-	switch v := event.(type) {
+	switch v := goqa.Open(event).(type) {
 	default:
 		return subscriber.ErrUnsupportedEvent
 	case *goqa.GithubEvent:
@@ -28,5 +28,12 @@ func (r Repo) Notify(event goqa.Event) error {
 		e = &v
 	}
 
-	return r.repo.Save(context.Background(), e.Coverage...)
+	if len(e.Coverage) != 0 { // only tests were run otherwise; keep what we know
+		var err = r.repo.Save(context.Background(), e.Repository, e.Ref, e.Coverage...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.repo.Append(context.Background(), e.Record())
 }
@@ -9,10 +9,15 @@ import (
 )
 
 type fakerepo struct {
-	covs []goqa.Coverage
+	repository string
+	ref        string
+	covs       []goqa.Coverage
+	recs       []goqa.Record
 }
 
-func (f *fakerepo) Save(ctx context.Context, covs ...goqa.Coverage) error {
+func (f *fakerepo) Save(ctx context.Context, repository, ref string, covs ...goqa.Coverage) error {
+	f.repository = repository
+	f.ref = ref
 	f.covs = covs
 	return nil
 }
@@ -21,6 +26,15 @@ func (f *fakerepo) Load(ctx context.Context) ([]goqa.Coverage, error) {
 	return f.covs, nil
 }
 
+func (f *fakerepo) Append(ctx context.Context, rec goqa.Record) error {
+	f.recs = append(f.recs, rec)
+	return nil
+}
+
+func (f *fakerepo) History(ctx context.Context, q goqa.HistoryQuery) ([]goqa.Record, error) {
+	return q.Filter(f.recs), nil
+}
+
 func (f *fakerepo) Close() error {
 	return nil
 }
@@ -217,6 +231,12 @@ func TestRepo_Notify(t *testing.T) {
 				t.Errorf("Notify() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
+			if e, ok := tt.args.event.(goqa.GithubEvent); ok {
+				var recs = tt.repo.(*fakerepo).recs
+				if len(recs) != 1 || recs[0].Commit != e.Commit || len(recs[0].Coverage) != len(e.Coverage) {
+					t.Errorf("history not appended: %v", recs)
+				}
+			}
 		})
 	}
 }
@@ -0,0 +1,354 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/subscriber"
+)
+
+const (
+	// Context of the commit statuses set
+	Context = "goqa/coverage"
+
+	// Marker of the pull request comments, so that they are edited rather than added on every push
+	Marker = "<!-- goqa/coverage -->"
+
+	// remembered is the number of commits whose summary is kept, as events about a commit may come in any order
+	remembered = 100
+
+	// descriptionLength is the maximum length of a status description allowed by GitHub
+	descriptionLength = 140
+
+	// Delay after the last event about a commit before it is reported, so that a push of many packages is reported once
+	Delay = 2 * time.Second
+)
+
+// summary of what is known about a commit
+type summary struct {
+	repository string
+	ref        string
+	commit     string
+	aggregate  *goqa.Aggregate
+	deltas     map[string]goqa.CoverageDeltaEvent
+	diff       *goqa.Diff
+}
+
+// summaries of the latest commits, shared by clones
+type summaries struct {
+	mut     sync.Mutex
+	commits map[string]*summary
+	order   []string
+
+	// pending reports of commits, by key
+	pending map[string]*time.Timer
+
+	// reporting one commit at a time, so that an older summary is never reported last
+	reporting sync.Mutex
+}
+
+// New report subscriber telling forge about coverage, Delay after the last event about a commit
+func New(forge goqa.Forge) *Report {
+	return &Report{
+		forge: forge,
+		delay: Delay,
+		summaries: &summaries{
+			commits: make(map[string]*summary),
+			pending: make(map[string]*time.Timer),
+		},
+	}
+}
+
+// Report is a subscriber that listens to goqa.GithubEvent, goqa.CoverageDeltaEvent and goqa.DiffEvent, setting the
+// status of commits and keeping a summary comment up to date on pull requests; events about a commit coming in a
+// burst are reported once
+type Report struct {
+	subscriber.Identifiable
+	forge     goqa.Forge
+	summaries *summaries
+
+	// delay before reporting a commit; reported right away when 0
+	delay time.Duration
+}
+
+// Clone sharing what is known about commits, so that it can be subscribed to another event
+func (r *Report) Clone() *Report {
+	return &Report{forge: r.forge, summaries: r.summaries, delay: r.delay}
+}
+
+// commitKey of a commit among summaries
+func commitKey(repository, commit string) string {
+	return repository + "#" + commit
+}
+
+// update the summary of a commit, returning a copy safe to read once unlocked
+func (s *summaries) update(repository, ref, commit string, fn func(*summary)) summary {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var key = commitKey(repository, commit)
+
+	var sum, ok = s.commits[key]
+	if !ok {
+		sum = &summary{repository: repository, ref: ref, commit: commit, deltas: make(map[string]goqa.CoverageDeltaEvent)}
+		s.commits[key] = sum
+		s.order = append(s.order, key)
+
+		if len(s.order) > remembered {
+			delete(s.commits, s.order[0])
+			s.order = s.order[1:]
+		}
+	}
+
+	fn(sum)
+
+	return sum.copy()
+}
+
+// get a copy of the summary of a commit
+func (s *summaries) get(key string) (summary, bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var sum, ok = s.commits[key]
+	if !ok {
+		return summary{}, false
+	}
+
+	return sum.copy(), true
+}
+
+// copy of a summary, not sharing deltas
+func (s *summary) copy() summary {
+	var c = *s
+	c.deltas = make(map[string]goqa.CoverageDeltaEvent, len(s.deltas))
+	for k, v := range s.deltas {
+		c.deltas[k] = v
+	}
+
+	return c
+}
+
+func (r *Report) Notify(event goqa.Event) error {
+	var sum summary
+
+	switch v := goqa.Open(event).(type) {
+	default:
+		return subscriber.ErrUnsupportedEvent
+	case *goqa.GithubEvent:
+		sum = r.github(v)
+	case goqa.GithubEvent:
+		sum = r.github(&v)
+	case goqa.CoverageDeltaEvent:
+		sum = r.summaries.update(v.Repository, v.Ref, v.Commit, func(s *summary) {
+			s.deltas[v.Pkg] = v
+		})
+	case goqa.DiffEvent:
+		sum = r.summaries.update(v.Repository, v.Ref, v.Commit, func(s *summary) {
+			var d = goqa.Diff(v)
+			s.diff = &d
+		})
+	}
+
+	if sum.aggregate == nil {
+		return nil // coverage of the commit is not known yet
+	}
+
+	var key = commitKey(sum.repository, sum.commit)
+	if r.delay == 0 {
+		return r.report(key)
+	}
+
+	r.schedule(key)
+	return nil
+}
+
+// schedule the report of a commit after the delay, postponing any pending one
+func (r *Report) schedule(key string) {
+	var s = r.summaries
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if t, ok := s.pending[key]; ok && t.Stop() {
+		t.Reset(r.delay)
+		return
+	}
+
+	// the timer is assigned before it can fire, as firing waits for the lock held here
+	var t *time.Timer
+	t = time.AfterFunc(r.delay, func() {
+		// a timer scheduled since this one fired is left pending
+		s.mut.Lock()
+		if s.pending[key] == t {
+			delete(s.pending, key)
+		}
+		s.mut.Unlock()
+
+		if err := r.report(key); err != nil {
+			log.Printf("failed to report coverage of %s\n%s\n", key, err.Error())
+		}
+	})
+
+	s.pending[key] = t
+}
+
+// report the latest summary of a commit to the forge
+func (r *Report) report(key string) error {
+	r.summaries.reporting.Lock()
+	defer r.summaries.reporting.Unlock()
+
+	var sum, ok = r.summaries.get(key)
+	if !ok {
+		return nil // forgotten meanwhile
+	}
+
+	var err = r.forge.SetStatus(context.Background(), sum.repository, sum.commit, status(sum))
+	if err != nil {
+		return err
+	}
+
+	if number, ok := PullRequest(sum.ref); ok {
+		err = r.forge.Comment(context.Background(), sum.repository, number, Marker, comment(sum))
+	}
+
+	return err
+}
+
+func (r *Report) github(e *goqa.GithubEvent) summary {
+	var a = goqa.Aggregated(e.Repository, e.Ref, "", e.Record().Coverage)
+
+	return r.summaries.update(e.Repository, e.Ref, e.Commit, func(s *summary) {
+		s.aggregate = &a
+	})
+}
+
+// PullRequest number out of a ref e.g. refs/pull/12/merge
+func PullRequest(ref string) (int, bool) {
+	var parts = strings.Split(ref, "/")
+	if len(parts) != 4 || parts[0] != "refs" || parts[1] != "pull" {
+		return 0, false
+	}
+
+	var n, err = strconv.Atoi(parts[2])
+	return n, err == nil && n > 0
+}
+
+// regressions of a commit, sorted by package
+func regressions(s summary) []string {
+	var pkgs []string
+
+	if s.diff != nil {
+		for _, p := range s.diff.Packages {
+			if p.Delta < 0 {
+				pkgs = append(pkgs, p.Pkg)
+			}
+		}
+
+		return pkgs
+	}
+
+	for pkg, d := range s.deltas {
+		if d.Delta() < 0 {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// status of a commit; coverage going down in any package is a failure
+func status(s summary) goqa.CommitStatus {
+	var st = goqa.CommitStatus{
+		State:       goqa.StatusSuccess,
+		Context:     Context,
+		Description: goqa.FormatPercentage(s.aggregate.Precise) + " % of statements",
+	}
+
+	if s.diff != nil && s.diff.Patch != nil {
+		st.Description += "; patch " + goqa.FormatPercentage(s.diff.Patch.Percentage) + " %"
+	}
+
+	if n := len(regressions(s)); n == 1 {
+		st.State = goqa.StatusFailure
+		st.Description += "; 1 package regressed"
+	} else if n > 1 {
+		st.State = goqa.StatusFailure
+		st.Description += "; " + strconv.Itoa(n) + " packages regressed"
+	}
+
+	if len(st.Description) > descriptionLength {
+		st.Description = st.Description[:descriptionLength]
+	}
+
+	return st
+}
+
+// comment of a pull request in markdown
+func comment(s summary) string {
+	var b strings.Builder
+
+	b.WriteString(Marker + "\n### Coverage\n\n")
+
+	if a := s.aggregate; a.Statements != 0 {
+		b.WriteString(fmt.Sprintf("**%s %%** of statements (%d / %d) at %s\n", goqa.FormatPercentage(a.Precise), a.Covered, a.Statements, s.commit))
+	} else {
+		b.WriteString(fmt.Sprintf("**%s %%** over %d packages at %s\n", goqa.FormatPercentage(a.Precise), a.Packages, s.commit))
+	}
+
+	if s.diff == nil {
+		if len(s.deltas) == 0 {
+			return b.String()
+		}
+
+		var pkgs = make([]string, 0, len(s.deltas))
+		for pkg := range s.deltas {
+			pkgs = append(pkgs, pkg)
+		}
+
+		sort.Strings(pkgs)
+
+		b.WriteString("\n| Package | Before | After | Δ |\n| --- | ---: | ---: | ---: |\n")
+		for _, pkg := range pkgs {
+			var d = s.deltas[pkg]
+			b.WriteString(fmt.Sprintf("| %s | %s %% | %s %% | %+g |\n", pkg, goqa.FormatPercentage(d.Old), goqa.FormatPercentage(d.New), d.Delta()))
+		}
+
+		return b.String()
+	}
+
+	b.WriteString(fmt.Sprintf("\nCompared to %s", s.diff.Base))
+	if p := s.diff.Patch; p != nil {
+		b.WriteString(fmt.Sprintf("; **%s %%** of the %d changed lines with statements are covered", goqa.FormatPercentage(p.Percentage), p.Lines))
+	}
+
+	b.WriteString("\n")
+
+	if len(s.diff.Packages) != 0 {
+		b.WriteString("\n| Package | Base | Head | Δ |\n| --- | ---: | ---: | ---: |\n")
+		for _, p := range s.diff.Packages {
+			switch {
+			case p.Added:
+				b.WriteString(fmt.Sprintf("| %s | new | %s %% | |\n", p.Pkg, goqa.FormatPercentage(p.Head)))
+			case p.Removed:
+				b.WriteString(fmt.Sprintf("| %s | %s %% | removed | |\n", p.Pkg, goqa.FormatPercentage(p.Base)))
+			default:
+				b.WriteString(fmt.Sprintf("| %s | %s %% | %s %% | %+g |\n", p.Pkg, goqa.FormatPercentage(p.Base), goqa.FormatPercentage(p.Head), p.Delta))
+			}
+		}
+	}
+
+	if len(s.diff.Untested) != 0 {
+		b.WriteString("\nNew packages without tests: " + strings.Join(s.diff.Untested, ", ") + "\n")
+	}
+
+	return b.String()
+}
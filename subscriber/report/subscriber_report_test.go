@@ -0,0 +1,281 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/subscriber"
+)
+
+var errForge = errors.New("forge err")
+
+type fakecomment struct {
+	repository string
+	number     int
+	body       string
+}
+
+type fakeforge struct {
+	mut      sync.Mutex
+	statuses []goqa.CommitStatus
+	comments []fakecomment
+	err      error
+}
+
+func (f *fakeforge) SetStatus(ctx context.Context, repository, commit string, status goqa.CommitStatus) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if f.err != nil {
+		return f.err
+	}
+
+	f.statuses = append(f.statuses, status)
+	return nil
+}
+
+func (f *fakeforge) Comment(ctx context.Context, repository string, number int, marker, body string) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if !strings.Contains(body, marker) {
+		return errors.New("marker missing from body")
+	}
+
+	f.comments = append(f.comments, fakecomment{repository: repository, number: number, body: body})
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	t.Run("New", func(t *testing.T) {
+		var _ goqa.Subscriber = New(nil)
+		var _ goqa.Subscriber = New(nil).Clone()
+	})
+}
+
+func TestPullRequest(t *testing.T) {
+	tests := []struct {
+		ref    string
+		want   int
+		wantOk bool
+	}{
+		{ref: "refs/pull/12/merge", want: 12, wantOk: true},
+		{ref: "refs/pull/3/head", want: 3, wantOk: true},
+		{ref: "refs/heads/master"},
+		{ref: "refs/pull/x/merge"},
+		{ref: "refs/pull/0/merge"},
+		{ref: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, ok := PullRequest(tt.ref)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("PullRequest() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestReport_Notify(t *testing.T) {
+	var push = goqa.GithubEvent{
+		Repository: "acme/foo",
+		Commit:     "c1",
+		Ref:        "refs/heads/master",
+		Coverage: []goqa.Coverage{
+			{Pkg: "foo", Statements: 4, Covered: 3, Percentage: 75, Precise: 75},
+			{Pkg: "foo/bar", Statements: 2, Covered: 2, Percentage: 100, Precise: 100},
+		},
+	}
+
+	var pr = push
+	pr.Commit = "h1"
+	pr.Ref = "refs/pull/7/merge"
+
+	var regression = goqa.CoverageDeltaEvent{Repository: "acme/foo", Ref: "refs/heads/master", Commit: "c1", Pkg: "foo", Old: 80, New: 75}
+
+	var diff = goqa.DiffEvent{
+		Repository: "acme/foo",
+		Ref:        "refs/pull/7/merge",
+		Base:       "b1",
+		Commit:     "h1",
+		Packages:   []goqa.PackageDiff{{Pkg: "foo", Base: 70, Head: 75, Delta: 5}, {Pkg: "foo/baz", Head: 0, Added: true}},
+		Untested:   []string{"foo/baz"},
+		Patch:      &goqa.PatchCoverage{Lines: 4, Covered: 3, Percentage: 75},
+	}
+
+	tests := []struct {
+		name         string
+		forge        *fakeforge
+		events       []goqa.Event
+		wantStatuses []goqa.CommitStatus
+		wantComments []string // substrings of the last comment
+		wantErr      error
+	}{
+		{
+			name:    "unsupported event",
+			forge:   &fakeforge{},
+			events:  []goqa.Event{goqa.CoverageEvent{}},
+			wantErr: subscriber.ErrUnsupportedEvent,
+		},
+		{
+			name:   "coverage not known yet",
+			forge:  &fakeforge{},
+			events: []goqa.Event{regression},
+		},
+		{
+			name:    "forge error",
+			forge:   &fakeforge{err: errForge},
+			events:  []goqa.Event{push},
+			wantErr: errForge,
+		},
+		{
+			name:   "push",
+			forge:  &fakeforge{},
+			events: []goqa.Event{&push},
+			wantStatuses: []goqa.CommitStatus{
+				{State: goqa.StatusSuccess, Context: Context, Description: "83.3 % of statements"},
+			},
+		},
+		{
+			name:   "regression after push",
+			forge:  &fakeforge{},
+			events: []goqa.Event{regression, push},
+			wantStatuses: []goqa.CommitStatus{
+				{State: goqa.StatusFailure, Context: Context, Description: "83.3 % of statements; 1 package regressed"},
+			},
+		},
+		{
+			name:   "pull request with diff",
+			forge:  &fakeforge{},
+			events: []goqa.Event{pr, diff},
+			wantStatuses: []goqa.CommitStatus{
+				{State: goqa.StatusSuccess, Context: Context, Description: "83.3 % of statements"},
+				{State: goqa.StatusSuccess, Context: Context, Description: "83.3 % of statements; patch 75 %"},
+			},
+			wantComments: []string{
+				Marker,
+				"**83.3 %** of statements (5 / 6) at h1",
+				"Compared to b1; **75 %** of the 4 changed lines",
+				"| foo | 70 % | 75 % | +5 |",
+				"| foo/baz | new | 0 % | |",
+				"New packages without tests: foo/baz",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				r   = New(tt.forge)
+				err error
+			)
+
+			r.delay = 0 // reported right away
+
+			for _, e := range tt.events {
+				if err = r.Clone().Notify(e); err != nil {
+					break
+				}
+			}
+
+			if err != tt.wantErr {
+				t.Errorf("Notify() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(tt.forge.statuses, tt.wantStatuses) {
+				t.Errorf("statuses\nwant = %v\ngot  = %v", tt.wantStatuses, tt.forge.statuses)
+			}
+
+			if len(tt.wantComments) == 0 {
+				if len(tt.forge.comments) != 0 {
+					t.Errorf("comments; want none, got %v", tt.forge.comments)
+				}
+
+				return
+			}
+
+			var last = tt.forge.comments[len(tt.forge.comments)-1]
+			if last.repository != "acme/foo" || last.number != 7 {
+				t.Errorf("comment on %s#%d; want acme/foo#7", last.repository, last.number)
+			}
+
+			for _, want := range tt.wantComments {
+				if !strings.Contains(last.body, want) {
+					t.Errorf("comment does not contain %q\n%s", want, last.body)
+				}
+			}
+		})
+	}
+}
+
+func TestReport_Remembered(t *testing.T) {
+	var f = &fakeforge{}
+	var r = New(f)
+
+	for i := 0; i <= remembered; i++ {
+		_ = r.Notify(goqa.CoverageDeltaEvent{Repository: "acme/foo", Commit: string(rune('a' + i)), Pkg: "foo"})
+	}
+
+	if got := len(r.summaries.commits); got != remembered {
+		t.Errorf("commits remembered = %d, want %d", got, remembered)
+	}
+}
+
+func TestReport_Delay(t *testing.T) {
+	var (
+		f = &fakeforge{}
+		r = New(f)
+	)
+
+	r.delay = 20 * time.Millisecond
+
+	var push = goqa.GithubEvent{
+		Repository: "acme/foo",
+		Commit:     "h1",
+		Ref:        "refs/pull/7/merge",
+		Coverage:   []goqa.Coverage{{Pkg: "foo", Statements: 4, Covered: 3, Percentage: 75, Precise: 75}},
+	}
+
+	var events = []goqa.Event{
+		push,
+		goqa.CoverageDeltaEvent{Repository: "acme/foo", Ref: "refs/pull/7/merge", Commit: "h1", Pkg: "foo", Old: 80, New: 75},
+		goqa.CoverageDeltaEvent{Repository: "acme/foo", Ref: "refs/pull/7/merge", Commit: "h1", Pkg: "foo/bar", Old: 80, New: 70},
+		goqa.CoverageDeltaEvent{Repository: "acme/foo", Ref: "refs/pull/7/merge", Commit: "h1", Pkg: "foo/baz", Old: 80, New: 90},
+	}
+
+	for _, e := range events {
+		if err := r.Clone().Notify(e); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+
+	var counts = func() (int, int) {
+		f.mut.Lock()
+		defer f.mut.Unlock()
+
+		return len(f.statuses), len(f.comments)
+	}
+
+	if s, c := counts(); s != 0 || c != 0 {
+		t.Errorf("reported before the delay: %d statuses, %d comments", s, c)
+	}
+
+	time.Sleep(10 * r.delay)
+
+	if s, c := counts(); s != 1 || c != 1 {
+		t.Fatalf("reports; want 1 status and 1 comment, got %d, %d", s, c)
+	}
+
+	var want = goqa.CommitStatus{State: goqa.StatusFailure, Context: Context, Description: "75 % of statements; 2 packages regressed"}
+	if f.statuses[0] != want {
+		t.Errorf("status\nwant = %v\ngot  = %v", want, f.statuses[0])
+	}
+}
@@ -0,0 +1,111 @@
+package slow
+
+import (
+	"context"
+
+	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/duration"
+	"github.com/fluxynet/goqa/subscriber"
+)
+
+// Source of the goqa.SlowTestEvents published, see goqa.Envelope
+const Source = "subscriber/slow"
+
+// Slow is a subscriber that listens to goqa.GithubEvent and emits a goqa.SlowTestEvent for every passed test or package
+// of the event which took factor times longer than its median duration over the previous runs
+type Slow struct {
+	subscriber.Identifiable
+	repo       goqa.Repo
+	broker     goqa.Broker
+	factor     float64
+	minElapsed float64
+	window     int
+}
+
+// New slow subscriber; zero values mean duration.DefaultFactor, duration.DefaultMinElapsed and duration.DefaultWindow
+func New(repo goqa.Repo, broker goqa.Broker, factor, minElapsed float64, window int) *Slow {
+	if factor <= 0 {
+		factor = duration.DefaultFactor
+	}
+
+	if minElapsed <= 0 {
+		minElapsed = duration.DefaultMinElapsed
+	}
+
+	if window <= 0 {
+		window = duration.DefaultWindow
+	}
+
+	return &Slow{repo: repo, broker: broker, factor: factor, minElapsed: minElapsed, window: window}
+}
+
+func (s *Slow) Notify(event goqa.Event) error {
+	var e *goqa.GithubEvent
+
+	switch v := goqa.Open(event).(type) {
+	default:
+		return subscriber.ErrUnsupportedEvent
+	case *goqa.GithubEvent:
+		e = v
+	case goqa.GithubEvent:
+		e = &v
+	}
+
+	if len(e.Tests) == 0 && len(e.Packages) == 0 {
+		return nil
+	}
+
+	var recs, err = s.repo.History(context.Background(), goqa.HistoryQuery{Repository: e.Repository, Ref: e.Ref})
+	if err != nil {
+		return err
+	}
+
+	// the event may or may not have been appended to history yet
+	var (
+		current = e.Record()
+		before  = make([]goqa.Record, 0, len(recs))
+	)
+
+	for i := range recs {
+		if recs[i].ID() != current.ID() {
+			before = append(before, recs[i])
+		}
+	}
+
+	var baselines = make(map[string]float64)
+	for _, t := range duration.Analyze(before, s.window) {
+		baselines[t.Pkg+" "+t.Test] = t.P50
+	}
+
+	var check = func(pkg, test, status string, elapsed float64) error {
+		var baseline, ok = baselines[pkg+" "+test]
+		if !ok || baseline <= 0 || status != goqa.TestPass || elapsed < s.minElapsed || elapsed < s.factor*baseline {
+			return nil
+		}
+
+		return s.broker.Publish(context.Background(), goqa.Derive(event, Source, goqa.SlowTestEvent{
+			Repository: e.Repository,
+			Ref:        e.Ref,
+			Commit:     e.Commit,
+			Pkg:        pkg,
+			Test:       test,
+			Elapsed:    elapsed,
+			Baseline:   baseline,
+			Factor:     elapsed / baseline,
+		}))
+	}
+
+	for _, p := range e.Packages {
+		if err = check(p.Pkg, "", p.Status, p.Elapsed); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range e.Tests {
+		if err = check(t.Pkg, t.Test, t.Status, t.Elapsed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package slow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/subscriber"
+)
+
+type fakerepo struct {
+	recs []goqa.Record
+}
+
+func (f *fakerepo) Save(ctx context.Context, repository, ref string, covs ...goqa.Coverage) error {
+	panic("not implemented")
+}
+
+func (f *fakerepo) Load(ctx context.Context) ([]goqa.Coverage, error) {
+	panic("not implemented")
+}
+
+func (f *fakerepo) Append(ctx context.Context, rec goqa.Record) error {
+	f.recs = append(f.recs, rec)
+	return nil
+}
+
+func (f *fakerepo) History(ctx context.Context, q goqa.HistoryQuery) ([]goqa.Record, error) {
+	return q.Filter(f.recs), nil
+}
+
+func (f *fakerepo) Close() error {
+	return nil
+}
+
+type fakebroker struct {
+	events []goqa.Event
+}
+
+func (f *fakebroker) Listen(ctx context.Context) (<-chan goqa.Event, error) {
+	return nil, nil
+}
+
+func (f *fakebroker) Publish(ctx context.Context, event goqa.Event) error {
+	f.events = append(f.events, goqa.Open(event)) // envelopes are tested by goqa
+	return nil
+}
+
+func (f *fakebroker) Close() error {
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	t.Run("New", func(t *testing.T) {
+		var _ goqa.Subscriber = New(nil, nil, 0, 0, 0)
+	})
+}
+
+func makeEvent(commit, time, status string, test, pkg float64) goqa.GithubEvent {
+	return goqa.GithubEvent{
+		Repository: "acme/foo",
+		Ref:        "master",
+		Commit:     commit,
+		Workflow:   "Go",
+		Tests: []goqa.TestResult{
+			{Pkg: "foo", Test: "TestA", Status: status, Elapsed: test, Time: time},
+		},
+		Packages: []goqa.PackageResult{
+			{Pkg: "foo", Status: status, Elapsed: pkg, Time: time},
+		},
+	}
+}
+
+func TestSlow_Notify(t *testing.T) {
+	t.Run("unsupported event", func(t *testing.T) {
+		var s = New(&fakerepo{}, &fakebroker{}, 0, 0, 0)
+		if err := s.Notify(goqa.CoverageEvent{}); err != subscriber.ErrUnsupportedEvent {
+			t.Errorf("Notify() error = %v", err)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		event goqa.GithubEvent
+		want  []goqa.Event
+	}{
+		{
+			name:  "usual duration",
+			event: makeEvent("c4", "2021-01-04T00:00:00Z", goqa.TestPass, 1.1, 2.2),
+		},
+		{
+			name:  "slow failure",
+			event: makeEvent("c4", "2021-01-04T00:00:00Z", goqa.TestFail, 30, 60),
+		},
+		{
+			name:  "slow test",
+			event: makeEvent("c4", "2021-01-04T00:00:00Z", goqa.TestPass, 3, 2.2),
+			want: []goqa.Event{
+				goqa.SlowTestEvent{Repository: "acme/foo", Ref: "master", Commit: "c4", Pkg: "foo", Test: "TestA", Elapsed: 3, Baseline: 1, Factor: 3},
+			},
+		},
+		{
+			name:  "slow package",
+			event: makeEvent("c4", "2021-01-04T00:00:00Z", goqa.TestPass, 1, 8),
+			want: []goqa.Event{
+				goqa.SlowTestEvent{Repository: "acme/foo", Ref: "master", Commit: "c4", Pkg: "foo", Elapsed: 8, Baseline: 2, Factor: 4},
+			},
+		},
+		{
+			name:  "redelivered",
+			event: makeEvent("c3", "2021-01-03T00:00:00Z", goqa.TestPass, 1, 2),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				r = &fakerepo{}
+				b = &fakebroker{}
+				s = New(r, b, 2, 0.5, 10)
+			)
+
+			// usually, TestA takes 1s and package foo takes 2s
+			for _, e := range []goqa.GithubEvent{
+				makeEvent("c1", "2021-01-01T00:00:00Z", goqa.TestPass, 1, 2),
+				makeEvent("c2", "2021-01-02T00:00:00Z", goqa.TestPass, 0.9, 1.9),
+				makeEvent("c3", "2021-01-03T00:00:00Z", goqa.TestPass, 1.2, 2.1),
+			} {
+				_ = r.Append(context.Background(), e.Record())
+			}
+
+			if err := s.Notify(tt.event); err != nil {
+				t.Errorf("Notify() error = %v", err)
+				return
+			}
+
+			if len(b.events) != len(tt.want) {
+				t.Errorf("events want = %v, got = %v", tt.want, b.events)
+				return
+			}
+
+			for i := range tt.want {
+				if b.events[i] != tt.want[i] {
+					t.Errorf("event(%d)\nwant = %v\ngot  = %v", i, tt.want[i], b.events[i])
+				}
+			}
+		})
+	}
+}
@@ -2,10 +2,12 @@ package sse
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/fluxynet/goqa"
 	"github.com/fluxynet/goqa/subscriber"
@@ -16,7 +18,22 @@ func init() {
 }
 
 func New(writer io.Writer, flusher http.Flusher) *SSE {
-	return &SSE{writer: writer, flusher: flusher}
+	return &SSE{writer: writer, flusher: flusher, mut: &sync.Mutex{}}
+}
+
+// NewFiltered only forwards coverage events whose key matches filter
+func NewFiltered(writer io.Writer, flusher http.Flusher, filter goqa.Key) *SSE {
+	return &SSE{writer: writer, flusher: flusher, filter: filter, mut: &sync.Mutex{}}
+}
+
+// NewJSON is NewFiltered with events data written as json documents of their goqa.Envelope rather than text
+func NewJSON(writer io.Writer, flusher http.Flusher, filter goqa.Key) *SSE {
+	return &SSE{writer: writer, flusher: flusher, filter: filter, json: true, mut: &sync.Mutex{}}
+}
+
+// Clone writing to the same stream, so that it can be subscribed to another event; writes of clones do not interleave
+func (s *SSE) Clone() *SSE {
+	return &SSE{ctx: s.ctx, writer: s.writer, flusher: s.flusher, filter: s.filter, json: s.json, mut: s.mut}
 }
 
 type SSE struct {
@@ -24,14 +41,62 @@ type SSE struct {
 	ctx     context.Context
 	flusher http.Flusher
 	writer  io.Writer
+	filter  goqa.Key
+	json    bool
+
+	// mut is shared with clones
+	mut *sync.Mutex
+}
+
+// skip events not matching the filter
+func (s *SSE) skip(event goqa.Event) bool {
+	switch v := goqa.Open(event).(type) {
+	case goqa.CoverageEvent:
+		return !s.filter.Match(goqa.Coverage(v).Key())
+	case *goqa.CoverageEvent:
+		return !s.filter.Match(goqa.Coverage(*v).Key())
+	case goqa.AggregateEvent:
+		return !s.filter.Match(goqa.Key{Repository: v.Repository, Ref: v.Ref, Pkg: s.filter.Pkg})
+	case *goqa.AggregateEvent:
+		return !s.filter.Match(goqa.Key{Repository: v.Repository, Ref: v.Ref, Pkg: s.filter.Pkg})
+	}
+
+	return false
 }
 
 func (s *SSE) Notify(event goqa.Event) error {
+	if s.skip(event) {
+		return nil
+	}
+
 	var (
 		ev   = strings.ReplaceAll(event.Name(), "\n", "_")
 		data = strings.ReplaceAll(event.String(), "\n", "_")
 	)
 
+	var envelope, sealed = goqa.Enveloped(event)
+
+	if s.json {
+		if !sealed {
+			envelope = goqa.Seal("", event)
+		}
+
+		var b, err = json.Marshal(envelope)
+		if err != nil {
+			return err
+		}
+
+		data = string(b) // json documents never contain raw new lines
+	}
+
+	if s.mut != nil {
+		defer s.mut.Unlock()
+		s.mut.Lock()
+	}
+
+	if sealed {
+		fmt.Fprintf(s.writer, "id: %s\n", envelope.ID)
+	}
 	if ev != "" {
 		fmt.Fprintf(s.writer, "event: %s\n", ev)
 	}
@@ -2,9 +2,11 @@ package sse
 
 import (
 	"context"
+	"encoding/json"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fluxynet/goqa"
 )
@@ -102,3 +104,136 @@ func TestSSE_Notify(t *testing.T) {
 		})
 	}
 }
+
+func TestSSE_NotifyFiltered(t *testing.T) {
+	var events = []goqa.Event{
+		goqa.CoverageEvent{Repository: "acme/foo", Ref: "master", Pkg: "foo", Percentage: 10},
+		goqa.CoverageEvent{Repository: "acme/bar", Ref: "master", Pkg: "bar", Percentage: 20},
+		&goqa.CoverageEvent{Repository: "acme/foo", Ref: "develop", Pkg: "foo", Percentage: 30},
+		fakeevent{name: "foo"},
+	}
+
+	tests := []struct {
+		name   string
+		filter goqa.Key
+		want   string
+	}{
+		{
+			name:   "no filter",
+			filter: goqa.Key{},
+			want: "event: EVENT_COVERAGE\ndata: repository: acme/foo; ref: master; pkg: foo; percentage: 10 %; time: \n\n" +
+				"event: EVENT_COVERAGE\ndata: repository: acme/bar; ref: master; pkg: bar; percentage: 20 %; time: \n\n" +
+				"event: EVENT_COVERAGE\ndata: repository: acme/foo; ref: develop; pkg: foo; percentage: 30 %; time: \n\n" +
+				"event: foo\ndata: foo::data\n\n",
+		},
+		{
+			name:   "repository and ref",
+			filter: goqa.Key{Repository: "acme/foo", Ref: "master"},
+			want: "event: EVENT_COVERAGE\ndata: repository: acme/foo; ref: master; pkg: foo; percentage: 10 %; time: \n\n" +
+				"event: foo\ndata: foo::data\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s := NewFiltered(w, w, tt.filter)
+
+			for i := range events {
+				if err := s.Notify(events[i]); err != nil {
+					t.Errorf("error not nil = %v", err)
+					return
+				}
+			}
+
+			b := w.Body.String()
+			if b != tt.want {
+				r := strings.NewReplacer("\r", "[R]", "\n", "[N]")
+				t.Errorf("body not same\nwant = %s\ngot  = %s", r.Replace(tt.want), r.Replace(b))
+			}
+		})
+	}
+}
+
+func TestSSE_NotifyJSON(t *testing.T) {
+	var (
+		w = httptest.NewRecorder()
+		s = NewJSON(w, w, goqa.Key{Repository: "acme/foo"})
+	)
+
+	var events = []goqa.Event{
+		goqa.Envelope{
+			ID:          "e2",
+			Time:        time.Date(2021, 3, 7, 23, 9, 38, 0, time.UTC),
+			Source:      "subscriber/coverage",
+			Correlation: "e1",
+			Payload:     goqa.CoverageEvent{Repository: "acme/foo", Ref: "master", Pkg: "foo", Percentage: 10, Precise: 10.5, Time: "now"},
+		},
+		goqa.CoverageEvent{Repository: "acme/bar", Ref: "master", Pkg: "bar", Percentage: 20},
+	}
+
+	for i := range events {
+		if err := s.Notify(events[i]); err != nil {
+			t.Errorf("error not nil = %v", err)
+			return
+		}
+	}
+
+	var want = "id: e2\nevent: EVENT_COVERAGE\ndata: {\"id\":\"e2\",\"name\":\"EVENT_COVERAGE\",\"time\":\"2021-03-07T23:09:38Z\"," +
+		"\"source\":\"subscriber/coverage\",\"correlation_id\":\"e1\",\"payload\":" +
+		"{\"repository\":\"acme/foo\",\"ref\":\"master\",\"pkg\":\"foo\",\"percentage\":10,\"precise\":10.5,\"time\":\"now\"}}\n\n"
+
+	if b := w.Body.String(); b != want {
+		r := strings.NewReplacer("\r", "[R]", "\n", "[N]")
+		t.Errorf("body not same\nwant = %s\ngot  = %s", r.Replace(want), r.Replace(b))
+	}
+
+	// events not sealed are sent in an envelope of their own
+	w.Body.Reset()
+	if err := s.Notify(goqa.CoverageEvent{Repository: "acme/foo", Pkg: "foo"}); err != nil {
+		t.Errorf("error not nil = %v", err)
+		return
+	}
+
+	var e goqa.Envelope
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(w.Body.String()), "event: EVENT_COVERAGE\ndata: ")), &e); err != nil {
+		t.Errorf("envelope error = %v\n%s", err, w.Body.String())
+		return
+	}
+
+	if e.ID == "" || e.Payload != (goqa.CoverageEvent{Repository: "acme/foo", Pkg: "foo"}) {
+		t.Errorf("envelope = %#v", e)
+	}
+}
+
+func TestSSE_Clone(t *testing.T) {
+	var (
+		w     = httptest.NewRecorder()
+		s     = NewFiltered(w, w, goqa.Key{Repository: "acme/foo", Pkg: "foo"})
+		clone = s.Clone()
+	)
+
+	clone.SetID("clone")
+	if s.ID() == clone.ID() || s.mut != clone.mut {
+		t.Errorf("Clone() want own id and shared mutex")
+	}
+
+	var events = []goqa.Event{
+		goqa.AggregateEvent{Repository: "acme/foo", Ref: "master", Packages: 1, Percentage: 10, Precise: 10},
+		goqa.AggregateEvent{Repository: "acme/bar", Ref: "master", Packages: 1, Percentage: 20, Precise: 20},
+	}
+
+	for i := range events {
+		if err := clone.Notify(events[i]); err != nil {
+			t.Errorf("error not nil = %v", err)
+			return
+		}
+	}
+
+	var want = "event: EVENT_AGGREGATE\ndata: repository: acme/foo; ref: master; path: ; percentage: 10 %; statements: 0/0; packages: 1\n\n"
+
+	if b := w.Body.String(); b != want {
+		r := strings.NewReplacer("\r", "[R]", "\n", "[N]")
+		t.Errorf("body not same\nwant = %s\ngot  = %s", r.Replace(want), r.Replace(b))
+	}
+}
@@ -0,0 +1,120 @@
+package goqa
+
+import (
+	"context"
+	"strings"
+)
+
+// TopicRepo prefixes the topic of the events of a repository e.g. repo.acme/foo
+const TopicRepo = "repo."
+
+// Router is a Broker which can route events by topic
+type Router interface {
+	// ListenTopics to events with a topic matching any of patterns; all events without patterns
+	ListenTopics(ctx context.Context, patterns ...string) (<-chan Event, error)
+}
+
+// Topics of an event: its name, its name and repository e.g. EVENT_COVERAGE.acme/foo and its repository
+// e.g. repo.acme/foo; only the name for events not about a repository
+func Topics(e Event) []string {
+	var (
+		name = e.Name()
+		repo = repositoryOf(e)
+	)
+
+	if repo == "" {
+		return []string{name}
+	}
+
+	return []string{name, name + "." + repo, TopicRepo + repo}
+}
+
+// repositoryOf an event, enveloped or not; empty when unknown
+func repositoryOf(e Event) string {
+	switch v := Open(e).(type) {
+	case *GithubEvent:
+		return v.Repository
+	case GithubEvent:
+		return v.Repository
+	case CoverageEvent:
+		return v.Repository
+	case CoverageDeltaEvent:
+		return v.Repository
+	case GateFailedEvent:
+		return v.Repository
+	case FlakyTestEvent:
+		return v.Repository
+	case SlowTestEvent:
+		return v.Repository
+	case AggregateEvent:
+		return v.Repository
+	case DiffEvent:
+		return v.Repository
+	}
+
+	return ""
+}
+
+// Match a topic against a pattern where * stands for any characters, dots and slashes included
+func Match(pattern, topic string) bool {
+	var star = strings.IndexByte(pattern, '*')
+	if star == -1 {
+		return pattern == topic
+	}
+
+	if !strings.HasPrefix(topic, pattern[:star]) {
+		return false
+	}
+
+	pattern, topic = pattern[star+1:], topic[star:]
+	for i := 0; i <= len(topic); i++ {
+		if Match(pattern, topic[i:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Routed tells whether an event has a topic matching any of patterns; all events are without patterns
+func Routed(e Event, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, topic := range Topics(e) {
+		for _, pattern := range patterns {
+			if Match(pattern, topic) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ListenTopics of a broker, routed by the broker itself when it is a Router
+func ListenTopics(ctx context.Context, b Broker, patterns ...string) (<-chan Event, error) {
+	if r, ok := b.(Router); ok {
+		return r.ListenTopics(ctx, patterns...)
+	}
+
+	var all, err = b.Listen(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var routed = make(chan Event)
+
+	go func() {
+		defer close(routed)
+
+		for e := range all {
+			if Routed(e, patterns) {
+				routed <- e
+			}
+		}
+	}()
+
+	return routed, nil
+}
@@ -0,0 +1,131 @@
+package goqa
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTopics(t *testing.T) {
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{name: "not about a repository", event: fakeevent{name: "EVENT_FOO"}, want: []string{"EVENT_FOO"}},
+		{name: "github", event: &GithubEvent{Repository: "acme/foo"}, want: []string{EventGithub, EventGithub + ".acme/foo", "repo.acme/foo"}},
+		{
+			name:  "regression",
+			event: CoverageDeltaEvent{Repository: "acme/foo", Old: 2, New: 1},
+			want:  []string{EventCoverageRegression, EventCoverageRegression + ".acme/foo", "repo.acme/foo"},
+		},
+		{name: "without repository", event: CoverageEvent{}, want: []string{EventCoverage}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Topics(tt.event); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Topics()\nwant = %v\ngot  = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		topic   string
+		want    bool
+	}{
+		{pattern: "EVENT_COVERAGE", topic: "EVENT_COVERAGE", want: true},
+		{pattern: "EVENT_COVERAGE", topic: "EVENT_COVERAGE_REGRESSION"},
+		{pattern: "EVENT_COVERAGE.*", topic: "EVENT_COVERAGE.acme/foo", want: true},
+		{pattern: "EVENT_COVERAGE.*", topic: "EVENT_COVERAGE_REGRESSION.acme/foo"},
+		{pattern: "EVENT_COVERAGE.*", topic: "EVENT_COVERAGE"},
+		{pattern: "EVENT_COVERAGE*", topic: "EVENT_COVERAGE_REGRESSION", want: true},
+		{pattern: "repo.acme/*", topic: "repo.acme/foo", want: true},
+		{pattern: "repo.acme/*", topic: "repo.acme/foo.js", want: true},
+		{pattern: "repo.acme/*", topic: "repo.other/foo"},
+		{pattern: "*.acme/foo", topic: "EVENT_DIFF.acme/foo", want: true},
+		{pattern: "EVENT_*.acme/*", topic: "EVENT_DIFF.acme/foo", want: true},
+		{pattern: "EVENT_*.acme/*", topic: "EVENT_DIFF.other/foo"},
+		{pattern: "*", topic: "anything", want: true},
+		{pattern: "", topic: "anything"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.topic, func(t *testing.T) {
+			if got := Match(tt.pattern, tt.topic); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouted(t *testing.T) {
+	var e = CoverageEvent{Repository: "acme/foo"}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     bool
+	}{
+		{name: "no patterns", want: true},
+		{name: "by name", patterns: []string{EventCoverage}, want: true},
+		{name: "by repository", patterns: []string{"repo.acme/*"}, want: true},
+		{name: "any", patterns: []string{EventDiff, "EVENT_COVERAGE.acme/foo"}, want: true},
+		{name: "none", patterns: []string{EventDiff, "repo.other/*"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Routed(e, tt.patterns); got != tt.want {
+				t.Errorf("Routed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakechannel is a Broker which is not a Router
+type fakechannel struct {
+	c chan Event
+}
+
+func (f *fakechannel) Listen(ctx context.Context) (<-chan Event, error) {
+	return f.c, nil
+}
+
+func (f *fakechannel) Publish(ctx context.Context, event Event) error {
+	f.c <- event
+	return nil
+}
+
+func (f *fakechannel) Close() error {
+	close(f.c)
+	return nil
+}
+
+func TestListenTopics(t *testing.T) {
+	var b = &fakechannel{c: make(chan Event, 3)}
+
+	var c, err = ListenTopics(context.Background(), b, "repo.acme/*")
+	if err != nil {
+		t.Errorf("ListenTopics() error = %v", err)
+		return
+	}
+
+	_ = b.Publish(context.Background(), CoverageEvent{Repository: "acme/foo"})
+	_ = b.Publish(context.Background(), CoverageEvent{Repository: "other/foo"})
+	_ = b.Publish(context.Background(), CoverageEvent{Repository: "acme/bar"})
+	_ = b.Close()
+
+	var got []Event
+	for e := range c {
+		got = append(got, e)
+	}
+
+	var want = []Event{CoverageEvent{Repository: "acme/foo"}, CoverageEvent{Repository: "acme/bar"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events\nwant = %v\ngot  = %v", want, got)
+	}
+}
@@ -0,0 +1,192 @@
+package hook
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/fluxynet/goqa/web"
+)
+
+const (
+	gitlabHeaderToken     = "X-Gitlab-Token"
+	gitlabHeaderDelivery  = "X-Gitlab-Event-UUID"
+	giteaHeaderSignature  = "X-Gitea-Signature"
+	giteaHeaderDelivery   = "X-Gitea-Delivery"
+	genericHeaderDefault  = "X-Goqa-Token"
+	genericHeaderDelivery = "X-Goqa-Delivery"
+	gitlabEventMerge      = "merge_request"
+	gitlabRefMergeRequest = "refs/merge-requests/"
+)
+
+// Adapter reads the web hooks of a CI, each with its own way of authenticating them, into a Payload
+type Adapter interface {
+	// Verify the request is authentic
+	Verify(r *http.Request, body []byte) error
+
+	// Payload out of the body of a verified request
+	Payload(body []byte) (*Payload, error)
+
+	// Delivery ID of the request, the same when redelivered; empty when unknown
+	Delivery(r *http.Request) string
+}
+
+// Github web hooks are sent by GitHub Actions, signed with HMAC-SHA256 in X-Hub-Signature-256 which is preferred,
+// or with HMAC-SHA1 in X-Hub-Signature
+type Github struct {
+	// SigKeys any of which may have signed the payload, to rotate them without dropping deliveries
+	SigKeys []string
+
+	// RejectSHA1 signatures, so that payloads are only trusted when signed with HMAC-SHA256
+	RejectSHA1 bool
+}
+
+func (g Github) Verify(r *http.Request, body []byte) error {
+	var signature = r.Header.Get(githubHeaderSignature256)
+	if signature == "" {
+		signature = r.Header.Get(githubHeaderSignature)
+	}
+
+	if signature == "" {
+		return errIncompleteRequest
+	}
+
+	return web.VerifySignature(body, signature, g.RejectSHA1, g.SigKeys...)
+}
+
+func (g Github) Payload(body []byte) (*Payload, error) {
+	return decode(body)
+}
+
+func (g Github) Delivery(r *http.Request) string {
+	return r.Header.Get(githubHeaderDelivery)
+}
+
+// Gitea web hooks are sent by Gitea Actions or Woodpecker in the same shape as Github ones, signed with
+// HMAC-SHA256 in X-Gitea-Signature
+type Gitea struct {
+	Secret string
+}
+
+func (g Gitea) Verify(r *http.Request, body []byte) error {
+	var signature = r.Header.Get(giteaHeaderSignature)
+	if signature == "" {
+		return errIncompleteRequest
+	}
+
+	return web.VerifyBodySHA256(body, signature, g.Secret)
+}
+
+func (g Gitea) Payload(body []byte) (*Payload, error) {
+	return decode(body)
+}
+
+func (g Gitea) Delivery(r *http.Request) string {
+	return r.Header.Get(giteaHeaderDelivery)
+}
+
+// Generic web hooks are sent by any CI in the same shape as Github ones, with a token in a header
+type Generic struct {
+	// Header holding the token; empty for X-Goqa-Token
+	Header string
+	Token  string
+}
+
+func (g Generic) Verify(r *http.Request, body []byte) error {
+	var header = g.Header
+	if header == "" {
+		header = genericHeaderDefault
+	}
+
+	var token = r.Header.Get(header)
+	if token == "" {
+		return errIncompleteRequest
+	}
+
+	return web.VerifyToken(token, g.Token)
+}
+
+func (g Generic) Payload(body []byte) (*Payload, error) {
+	return decode(body)
+}
+
+func (g Generic) Delivery(r *http.Request) string {
+	return r.Header.Get(genericHeaderDelivery)
+}
+
+// Gitlab web hooks are sent by GitLab CI jobs with the token in X-Gitlab-Token
+type Gitlab struct {
+	Token string
+}
+
+// GitlabPayload is named after the predefined variables of GitLab CI
+type GitlabPayload struct {
+	Project  string  `json:"ci_project_path"`
+	Sha      string  `json:"ci_commit_sha"`
+	RefName  string  `json:"ci_commit_ref_name"`
+	Tag      string  `json:"ci_commit_tag"`
+	Source   string  `json:"ci_pipeline_source"`
+	Job      string  `json:"ci_job_name"`
+	Data     []Datum `json:"data"`
+	Diff     string  `json:"diff"`
+	MergeIID string  `json:"ci_merge_request_iid"`
+
+	// DiffBase is the commit merge requests are compared to
+	DiffBase string `json:"ci_merge_request_diff_base_sha"`
+}
+
+func (g Gitlab) Verify(r *http.Request, body []byte) error {
+	var token = r.Header.Get(gitlabHeaderToken)
+	if token == "" {
+		return errIncompleteRequest
+	}
+
+	return web.VerifyToken(token, g.Token)
+}
+
+func (g Gitlab) Payload(body []byte) (*Payload, error) {
+	var gp *GitlabPayload
+	if err := json.Unmarshal(body, &gp); err != nil || gp == nil {
+		return nil, errIncompleteRequest
+	}
+
+	var p = Payload{
+		Event:      gp.Source,
+		Repository: gp.Project,
+		Commit:     gp.Sha,
+		Workflow:   gp.Job,
+		Data:       gp.Data,
+		Diff:       gp.Diff,
+	}
+
+	switch {
+	case gp.MergeIID != "":
+		// merge request pipelines run on the source branch, GitLab keeps their head at this ref
+		p.Event = gitlabEventMerge
+		p.Ref = gitlabRefMergeRequest + gp.MergeIID + "/head"
+		p.Head = gp.RefName
+		p.Base = gp.DiffBase
+	case gp.Tag != "":
+		p.Ref = "refs/tags/" + gp.Tag
+	case strings.HasPrefix(gp.RefName, "refs/"):
+		p.Ref = gp.RefName
+	default:
+		p.Ref = "refs/heads/" + gp.RefName
+	}
+
+	return &p, nil
+}
+
+func (g Gitlab) Delivery(r *http.Request) string {
+	return r.Header.Get(gitlabHeaderDelivery)
+}
+
+// decode a payload in the shape of Github ones
+func decode(body []byte) (*Payload, error) {
+	var p *Payload
+	if err := json.Unmarshal(body, &p); err != nil || p == nil {
+		return nil, errIncompleteRequest
+	}
+
+	return p, nil
+}
@@ -0,0 +1,260 @@
+package hook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/internal"
+	"github.com/fluxynet/goqa/web"
+)
+
+func signSHA256(body, key string) string {
+	var h = hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(body))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestAdapter_Verify(t *testing.T) {
+	const body = `{"repository":"acme/foo"}`
+
+	tests := []struct {
+		name    string
+		adapter Adapter
+		headers map[string]string
+		want    error
+	}{
+		{name: "github", adapter: Github{SigKeys: []string{"foobar"}}, headers: map[string]string{githubHeaderSignature: sign(body, "foobar")}},
+		{name: "github bad", adapter: Github{SigKeys: []string{"foobar"}}, headers: map[string]string{githubHeaderSignature: sign(body, "barfoo")}, want: web.ErrPayloadUnverified},
+		{name: "github missing", adapter: Github{SigKeys: []string{"foobar"}}, want: errIncompleteRequest},
+		{name: "github sha256", adapter: Github{SigKeys: []string{"foobar"}}, headers: map[string]string{githubHeaderSignature256: "sha256=" + signSHA256(body, "foobar")}},
+		{
+			name:    "github sha256 preferred",
+			adapter: Github{SigKeys: []string{"foobar"}},
+			headers: map[string]string{githubHeaderSignature256: "sha256=" + signSHA256(body, "barfoo"), githubHeaderSignature: sign(body, "foobar")},
+			want:    web.ErrPayloadUnverified,
+		},
+		{name: "github rotated", adapter: Github{SigKeys: []string{"barfoo", "foobar"}}, headers: map[string]string{githubHeaderSignature256: "sha256=" + signSHA256(body, "foobar")}},
+		{name: "github sha1 rejected", adapter: Github{SigKeys: []string{"foobar"}, RejectSHA1: true}, headers: map[string]string{githubHeaderSignature: sign(body, "foobar")}, want: web.ErrSHA1Rejected},
+		{
+			name:    "github sha256 with sha1 rejected",
+			adapter: Github{SigKeys: []string{"foobar"}, RejectSHA1: true},
+			headers: map[string]string{githubHeaderSignature256: "sha256=" + signSHA256(body, "foobar"), githubHeaderSignature: sign(body, "foobar")},
+		},
+		{name: "gitea", adapter: Gitea{Secret: "foobar"}, headers: map[string]string{giteaHeaderSignature: signSHA256(body, "foobar")}},
+		{name: "gitea sha1", adapter: Gitea{Secret: "foobar"}, headers: map[string]string{giteaHeaderSignature: sign(body, "foobar")}, want: web.ErrPayloadUnverified},
+		{name: "gitea missing", adapter: Gitea{Secret: "foobar"}, want: errIncompleteRequest},
+		{name: "gitlab", adapter: Gitlab{Token: "t0ken"}, headers: map[string]string{gitlabHeaderToken: "t0ken"}},
+		{name: "gitlab bad", adapter: Gitlab{Token: "t0ken"}, headers: map[string]string{gitlabHeaderToken: "t0kem"}, want: web.ErrPayloadUnverified},
+		{name: "gitlab not configured", adapter: Gitlab{}, headers: map[string]string{gitlabHeaderToken: "t0ken"}, want: web.ErrPayloadUnverified},
+		{name: "gitlab missing", adapter: Gitlab{Token: "t0ken"}, want: errIncompleteRequest},
+		{name: "generic", adapter: Generic{Token: "t0ken"}, headers: map[string]string{genericHeaderDefault: "t0ken"}},
+		{name: "generic header", adapter: Generic{Header: "X-Ci-Token", Token: "t0ken"}, headers: map[string]string{"X-Ci-Token": "t0ken"}},
+		{name: "generic wrong header", adapter: Generic{Header: "X-Ci-Token", Token: "t0ken"}, headers: map[string]string{genericHeaderDefault: "t0ken"}, want: errIncompleteRequest},
+		{name: "generic bad", adapter: Generic{Token: "t0ken"}, headers: map[string]string{genericHeaderDefault: "bad"}, want: web.ErrPayloadUnverified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			if err := tt.adapter.Verify(r, []byte(body)); err != tt.want {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitlab_Payload(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    *Payload
+		wantErr error
+	}{
+		{
+			name:    "invalid json",
+			body:    `hello=world`,
+			wantErr: errIncompleteRequest,
+		},
+		{
+			name:    "null",
+			body:    `null`,
+			wantErr: errIncompleteRequest,
+		},
+		{
+			name: "branch",
+			body: `{"ci_project_path":"acme/foo","ci_commit_sha":"c1","ci_commit_ref_name":"master","ci_pipeline_source":"push","ci_job_name":"test"}`,
+			want: &Payload{Event: "push", Repository: "acme/foo", Commit: "c1", Ref: "refs/heads/master", Workflow: "test"},
+		},
+		{
+			name: "tag",
+			body: `{"ci_project_path":"acme/foo","ci_commit_sha":"c1","ci_commit_ref_name":"v1.0.0","ci_commit_tag":"v1.0.0","ci_pipeline_source":"push","ci_job_name":"test"}`,
+			want: &Payload{Event: "push", Repository: "acme/foo", Commit: "c1", Ref: "refs/tags/v1.0.0", Workflow: "test"},
+		},
+		{
+			name: "merge request",
+			body: `{"ci_project_path":"acme/foo","ci_commit_sha":"h1","ci_commit_ref_name":"feature","ci_pipeline_source":"merge_request_event","ci_job_name":"test",` +
+				`"ci_merge_request_iid":"12","ci_merge_request_diff_base_sha":"b1","diff":"--- a/foo.go\n+++ b/foo.go\n",` +
+				`"data":[{"Time":"2021-03-07T23:09:38Z","Action":"output","Package":"gitlab.com/acme/foo","Output":"coverage: 50.0% of statements\n"}]}`,
+			want: &Payload{
+				Event:      "merge_request",
+				Repository: "acme/foo",
+				Commit:     "h1",
+				Ref:        "refs/merge-requests/12/head",
+				Head:       "feature",
+				Workflow:   "test",
+				Base:       "b1",
+				Diff:       "--- a/foo.go\n+++ b/foo.go\n",
+				Data:       []Datum{{Time: "2021-03-07T23:09:38Z", Action: "output", Package: "gitlab.com/acme/foo", Output: "coverage: 50.0% of statements\n"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Gitlab{}.Payload([]byte(tt.body))
+			if err != tt.wantErr {
+				t.Errorf("Payload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Payload()\nwant = %+v\ngot  = %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHook_Ingest(t *testing.T) {
+	const body = `{"event":"push","repository":"acme/foo","commit":"c1","ref":"refs/heads/master","workflow":"Go","data":[` +
+		`{"Time":"2021-03-07T23:09:38Z","Action":"output","Package":"gitea.com/acme/foo","Output":"coverage: 83.3% of statements\n"}]}`
+
+	tests := []struct {
+		name       string
+		signature  string
+		wantStatus int
+		wantBody   string
+		wantEvent  *goqa.GithubEvent
+	}{
+		{
+			name:       "unverified",
+			signature:  signSHA256(body, "barfoo"),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"payload could not be verified"}`,
+		},
+		{
+			name:       "verified",
+			signature:  signSHA256(body, "foobar"),
+			wantStatus: http.StatusOK,
+			wantBody:   `{"message":"web hook well received"}`,
+			wantEvent: &goqa.GithubEvent{
+				Event:      "push",
+				Repository: "acme/foo",
+				Commit:     "c1",
+				Ref:        "refs/heads/master",
+				Workflow:   "Go",
+				Coverage: []goqa.Coverage{
+					{Repository: "acme/foo", Ref: "refs/heads/master", Pkg: "gitea.com/acme/foo", Percentage: 83, Precise: 83.3, Time: "2021-03-07T23:09:38Z"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				b = &fakebroker{}
+				h = &Hook{Broker: b}
+				w = httptest.NewRecorder()
+				r = httptest.NewRequest(http.MethodPost, "/gitea", strings.NewReader(body))
+			)
+
+			r.Header.Set(giteaHeaderSignature, tt.signature)
+			h.Ingest(Gitea{Secret: "foobar"})(w, r)
+
+			internal.AssertHttp(t, w, tt.wantStatus, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.wantBody)
+
+			if tt.wantEvent == nil {
+				if b.event != nil {
+					t.Errorf("event; want none, got %v", b.event)
+				}
+
+				return
+			}
+
+			var got, ok = b.event.(*goqa.GithubEvent)
+			if !ok {
+				t.Errorf("got event is not github event")
+				return
+			}
+
+			internal.AssertGithubEventsEqual(t, tt.wantEvent, got)
+		})
+	}
+}
+
+func TestHook_ReceiveRotation(t *testing.T) {
+	const body = `{"event":"push","repository":"acme/foo","commit":"c1","ref":"refs/heads/master","workflow":"Go","data":[` +
+		`{"Time":"2021-03-07T23:09:38Z","Action":"output","Package":"github.com/acme/foo","Output":"coverage: 83.3% of statements\n"}]}`
+
+	tests := []struct {
+		name       string
+		hook       Hook
+		header     string
+		signature  string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "current key",
+			hook:       Hook{SigKey: "new", SigKeys: []string{"old"}},
+			header:     githubHeaderSignature256,
+			signature:  "sha256=" + signSHA256(body, "new"),
+			wantStatus: http.StatusOK,
+			wantBody:   `{"message":"web hook well received"}`,
+		},
+		{
+			name:       "previous key",
+			hook:       Hook{SigKey: "new", SigKeys: []string{"old"}},
+			header:     githubHeaderSignature,
+			signature:  sign(body, "old"),
+			wantStatus: http.StatusOK,
+			wantBody:   `{"message":"web hook well received"}`,
+		},
+		{
+			name:       "sha1 rejected",
+			hook:       Hook{SigKey: "new", RejectSHA1: true},
+			header:     githubHeaderSignature,
+			signature:  sign(body, "new"),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"sha1 signatures are rejected"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				h = tt.hook
+				w = httptest.NewRecorder()
+				r = httptest.NewRequest(http.MethodPost, "/github", strings.NewReader(body))
+			)
+
+			h.Broker = &fakebroker{}
+			r.Header.Set(tt.header, tt.signature)
+			h.Receive(w, r)
+
+			internal.AssertHttp(t, w, tt.wantStatus, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.wantBody)
+		})
+	}
+}
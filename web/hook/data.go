@@ -1,12 +1,25 @@
 package hook
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/coverprofile"
+	"github.com/fluxynet/goqa/patch"
 )
 
+var errNoProfile = errors.New("coverprofile is missing")
+
+// now is replaced in tests
+var now = time.Now
+
 // Payload received from the hook
 type Payload struct {
 	Event      string  `json:"event"`
@@ -16,15 +29,115 @@ type Payload struct {
 	Head       string  `json:"head"`
 	Workflow   string  `json:"workflow"`
 	Data       []Datum `json:"data"`
+
+	// Base commit of a pull request
+	Base string `json:"base"`
+
+	// Diff of the pull request against Base, as written by git diff
+	Diff string `json:"diff"`
 }
 
 // Datum is the singular of data
 type Datum struct {
-	Time    string `json:"Time"`
-	Action  string `json:"Action"`
-	Package string `json:"Package"`
-	Test    string `json:"Test"`
-	Output  string `json:"Output"`
+	Time    string  `json:"Time"`
+	Action  string  `json:"Action"`
+	Package string  `json:"Package"`
+	Test    string  `json:"Test"`
+	Output  string  `json:"Output"`
+	Elapsed float64 `json:"Elapsed"`
+}
+
+// testActions are those reported by go test -json
+var testActions = map[string]bool{
+	"run":         true,
+	"pause":       true,
+	"cont":        true,
+	"bench":       true,
+	"output":      true,
+	goqa.TestPass: true,
+	goqa.TestFail: true,
+	goqa.TestSkip: true,
+}
+
+// CreateTestResults out of test events, in the order tests started; tests without an outcome are considered failed
+func CreateTestResults(data []Datum) []goqa.TestResult {
+	var (
+		results []goqa.TestResult
+		indexes = make(map[string]int) // package + test => index in results
+		outputs = make(map[int]*strings.Builder)
+		done    = make(map[int]bool) // attempt has an outcome
+	)
+
+	for i := range data {
+		var d = data[i]
+		if d.Test == "" || !testActions[d.Action] {
+			continue // package level or not from go test
+		}
+
+		var k = d.Package + " " + d.Test
+		var n, ok = indexes[k]
+
+		if !ok {
+			n = len(results)
+			indexes[k] = n
+			outputs[n] = &strings.Builder{}
+			results = append(results, goqa.TestResult{
+				Pkg:    d.Package,
+				Test:   d.Test,
+				Status: goqa.TestFail,
+				Time:   d.Time,
+			})
+		}
+
+		switch d.Action {
+		case "run":
+			if done[n] { // another attempt
+				if results[n].Status == goqa.TestFail {
+					results[n].Failures++
+				}
+
+				results[n].Retries++
+				results[n].Status = goqa.TestFail
+				done[n] = false
+			}
+		case "output":
+			outputs[n].WriteString(d.Output)
+		case goqa.TestPass, goqa.TestFail, goqa.TestSkip:
+			results[n].Status = d.Action
+			results[n].Elapsed = d.Elapsed
+			done[n] = true
+		}
+	}
+
+	for n := range results {
+		results[n].Output = outputs[n].String()
+	}
+
+	return results
+}
+
+// CreatePackageResults out of package level test events, in the order packages finished
+func CreatePackageResults(data []Datum) []goqa.PackageResult {
+	var results []goqa.PackageResult
+
+	for i := range data {
+		var d = data[i]
+		if d.Test != "" {
+			continue
+		}
+
+		switch d.Action {
+		case goqa.TestPass, goqa.TestFail, goqa.TestSkip:
+			results = append(results, goqa.PackageResult{
+				Pkg:     d.Package,
+				Status:  d.Action,
+				Elapsed: d.Elapsed,
+				Time:    d.Time,
+			})
+		}
+	}
+
+	return results
 }
 
 // CreateGithubEvent get coverage information from a Payload
@@ -41,8 +154,10 @@ func CreateGithubEvent(p *Payload) *goqa.GithubEvent {
 		}
 
 		var c = goqa.Coverage{
-			Pkg:  p.Data[i].Package,
-			Time: p.Data[i].Time,
+			Repository: p.Repository,
+			Ref:        p.Ref,
+			Pkg:        p.Data[i].Package,
+			Time:       p.Data[i].Time,
 		}
 
 		var perc string
@@ -53,7 +168,8 @@ func CreateGithubEvent(p *Payload) *goqa.GithubEvent {
 		}
 
 		if v, err := strconv.ParseFloat(perc, 64); err == nil {
-			c.Percentage = int(v) // really, we do not need that level of precision
+			c.Percentage = int(v) // kept for backward compatibility
+			c.Precise = v
 		} else {
 			continue
 		}
@@ -69,7 +185,95 @@ func CreateGithubEvent(p *Payload) *goqa.GithubEvent {
 		Head:       p.Head,
 		Workflow:   p.Workflow,
 		Coverage:   covs,
+		Tests:      CreateTestResults(p.Data),
+		Packages:   CreatePackageResults(p.Data),
+		Base:       p.Base,
+	}
+
+	if p.Diff != "" {
+		event.Changes, _ = patch.Parse(strings.NewReader(p.Diff)) // a broken diff only costs patch coverage
 	}
 
 	return &event
 }
+
+// CreateProfileEvent get coverage information from an uploaded coverprofile; measured now
+func CreateProfileEvent(repository, commit, ref, workflow string, p *coverprofile.Profile) *goqa.GithubEvent {
+	if p == nil {
+		return nil
+	}
+
+	var event = goqa.GithubEvent{
+		Event:      "coverprofile",
+		Repository: repository,
+		Commit:     commit,
+		Ref:        ref,
+		Workflow:   workflow,
+		Coverage:   p.Coverage(repository, ref, now().UTC().Format(time.RFC3339Nano)),
+		Files:      p.Files,
+	}
+
+	return &event
+}
+
+// ReadUpload out of a body which is either a coverprofile or a multipart form of a profile, sources and the diff of
+// a pull request; changes are nil without a diff
+func ReadUpload(ctype string, body []byte) (*coverprofile.Profile, map[string][]int, error) {
+	var mediatype, params, err = mime.ParseMediaType(ctype)
+	if err != nil || mediatype != "multipart/form-data" {
+		var p *coverprofile.Profile
+		p, err = coverprofile.Parse(bytes.NewReader(body))
+		return p, nil, err
+	}
+
+	var (
+		reader  = multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		profile []byte
+		sources = make(map[string][]byte)
+		changes map[string][]int
+	)
+
+	for {
+		var part, err = reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		var b []byte
+		if b, err = io.ReadAll(part); err != nil {
+			return nil, nil, err
+		}
+
+		switch part.FormName() {
+		case "profile":
+			profile = b
+		case "diff":
+			if changes, err = patch.Parse(bytes.NewReader(b)); err != nil {
+				return nil, nil, err
+			}
+		case "source":
+			// part.FileName() keeps the base name only, sources are named after their full path
+			var _, params, _ = mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+			if params["filename"] != "" {
+				sources[params["filename"]] = b
+			}
+		}
+	}
+
+	if profile == nil {
+		return nil, nil, errNoProfile
+	}
+
+	var p *coverprofile.Profile
+	if p, err = coverprofile.Parse(bytes.NewReader(profile)); err != nil {
+		return nil, nil, err
+	}
+
+	if err = p.Annotate(sources); err != nil {
+		return nil, nil, err
+	}
+
+	return p, changes, nil
+}
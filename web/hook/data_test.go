@@ -1,6 +1,10 @@
 package hook
 
 import (
+	"bytes"
+	"mime/multipart"
+	"net/textproto"
+	"reflect"
 	"testing"
 
 	"github.com/fluxynet/goqa"
@@ -107,7 +111,7 @@ func TestCreateGithubEvent(t *testing.T) {
 				Head:       "Head A",
 				Workflow:   "Workflow A",
 				Coverage: []goqa.Coverage{
-					{Pkg: "Package 1", Percentage: 10, Time: "2006-01-02T15:04:05Z07:00"},
+					{Repository: "Repository A", Ref: "Ref A", Pkg: "Package 1", Percentage: 10, Precise: 10.5, Time: "2006-01-02T15:04:05Z07:00"},
 				},
 			},
 		},
@@ -155,18 +159,49 @@ func TestCreateGithubEvent(t *testing.T) {
 				Workflow:   "Workflow Foo",
 				Coverage: []goqa.Coverage{
 					{
+						Repository: "Repository Foo",
+						Ref:        "Ref Foo",
 						Pkg:        "Package 1",
 						Percentage: 5,
+						Precise:    5,
 						Time:       "2006-01-02T15:04:05Z07:00",
 					},
 					{
+						Repository: "Repository Foo",
+						Ref:        "Ref Foo",
 						Pkg:        "Package 3",
 						Percentage: 7,
+						Precise:    7.4,
 						Time:       "2006-01-02T15:04:05Z07:00",
 					},
 				},
 			},
 		},
+		{
+			name: "pull request",
+			args: args{
+				p: &Payload{
+					Event:      "pull_request",
+					Repository: "acme/foo",
+					Commit:     "h1",
+					Ref:        "refs/pull/1/merge",
+					Head:       "feature",
+					Workflow:   "Go",
+					Base:       "b1",
+					Diff:       "--- a/foo.go\n+++ b/foo.go\n@@ -1 +1,3 @@\n package foo\n+\n+// Sum\n",
+				},
+			},
+			want: &goqa.GithubEvent{
+				Event:      "pull_request",
+				Repository: "acme/foo",
+				Commit:     "h1",
+				Ref:        "refs/pull/1/merge",
+				Head:       "feature",
+				Workflow:   "Go",
+				Base:       "b1",
+				Changes:    map[string][]int{"foo.go": {2, 3}},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -177,3 +212,212 @@ func TestCreateGithubEvent(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateTestResults(t *testing.T) {
+	tests := []struct {
+		name string
+		data []Datum
+		want []goqa.TestResult
+	}{
+		{
+			name: "nil",
+			data: nil,
+			want: nil,
+		},
+		{
+			name: "package level only",
+			data: []Datum{
+				{Time: "t0", Action: "output", Package: "foo", Output: "PASS\n"},
+				{Time: "t1", Action: "pass", Package: "foo", Elapsed: 0.5},
+			},
+			want: nil,
+		},
+		{
+			name: "pass, fail, skip and no outcome",
+			data: []Datum{
+				{Time: "t0", Action: "run", Package: "foo", Test: "TestA"},
+				{Time: "t1", Action: "run", Package: "foo", Test: "TestA/sub"},
+				{Time: "t2", Action: "output", Package: "foo", Test: "TestA/sub", Output: "=== RUN   TestA/sub\n"},
+				{Time: "t3", Action: "output", Package: "foo", Test: "TestA/sub", Output: "    a_test.go:10: oops\n"},
+				{Time: "t4", Action: "fail", Package: "foo", Test: "TestA/sub", Elapsed: 0.25},
+				{Time: "t5", Action: "fail", Package: "foo", Test: "TestA", Elapsed: 0.5},
+				{Time: "t6", Action: "run", Package: "bar", Test: "TestA"},
+				{Time: "t7", Action: "skip", Package: "bar", Test: "TestA", Elapsed: 0.01},
+				{Time: "t8", Action: "run", Package: "bar", Test: "TestB"},
+				{Time: "t9", Action: "pass", Package: "bar", Test: "TestB", Elapsed: 1.5},
+				{Time: "t10", Action: "run", Package: "bar", Test: "TestC"},
+				{Time: "t11", Action: "something else", Package: "bar", Test: "TestD"},
+			},
+			want: []goqa.TestResult{
+				{Pkg: "foo", Test: "TestA", Status: goqa.TestFail, Elapsed: 0.5, Time: "t0"},
+				{Pkg: "foo", Test: "TestA/sub", Status: goqa.TestFail, Elapsed: 0.25, Output: "=== RUN   TestA/sub\n    a_test.go:10: oops\n", Time: "t1"},
+				{Pkg: "bar", Test: "TestA", Status: goqa.TestSkip, Elapsed: 0.01, Time: "t6"},
+				{Pkg: "bar", Test: "TestB", Status: goqa.TestPass, Elapsed: 1.5, Time: "t8"},
+				{Pkg: "bar", Test: "TestC", Status: goqa.TestFail, Time: "t10"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			internal.AssertTestResultsEqual(t, CreateTestResults(tt.data), tt.want)
+		})
+	}
+}
+
+func TestCreateTestResults_Retries(t *testing.T) {
+	var data = []Datum{
+		{Time: "t0", Action: "run", Package: "foo", Test: "TestA"},
+		{Time: "t1", Action: "fail", Package: "foo", Test: "TestA", Elapsed: 0.5},
+		{Time: "t2", Action: "run", Package: "foo", Test: "TestB"},
+		{Time: "t3", Action: "pass", Package: "foo", Test: "TestB", Elapsed: 0.1},
+		{Time: "t4", Action: "run", Package: "foo", Test: "TestA"},
+		{Time: "t5", Action: "fail", Package: "foo", Test: "TestA", Elapsed: 0.4},
+		{Time: "t6", Action: "run", Package: "foo", Test: "TestB"},
+		{Time: "t7", Action: "pass", Package: "foo", Test: "TestB", Elapsed: 0.2},
+		{Time: "t8", Action: "run", Package: "foo", Test: "TestA"},
+		{Time: "t9", Action: "pass", Package: "foo", Test: "TestA", Elapsed: 0.3},
+	}
+
+	var want = []goqa.TestResult{
+		{Pkg: "foo", Test: "TestA", Status: goqa.TestPass, Elapsed: 0.3, Retries: 2, Failures: 2, Time: "t0"},
+		{Pkg: "foo", Test: "TestB", Status: goqa.TestPass, Elapsed: 0.2, Retries: 1, Time: "t2"},
+	}
+
+	var got = CreateTestResults(data)
+	internal.AssertTestResultsEqual(t, got, want)
+
+	if len(got) == 2 && (!got[0].PassedOnRetry() || got[1].PassedOnRetry()) {
+		t.Errorf("PassedOnRetry() want = true, false; got = %t, %t", got[0].PassedOnRetry(), got[1].PassedOnRetry())
+	}
+}
+
+// form of a profile, a diff and sources, returning the content type and the body
+func form(profile, diff string, sources map[string]string) (string, []byte) {
+	var (
+		buf bytes.Buffer
+		m   = multipart.NewWriter(&buf)
+	)
+
+	if profile != "" {
+		var w, _ = m.CreateFormFile("profile", "cover.out")
+		w.Write([]byte(profile))
+	}
+
+	if diff != "" {
+		var w, _ = m.CreateFormFile("diff", "pr.diff")
+		w.Write([]byte(diff))
+	}
+
+	for name, src := range sources {
+		var h = make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="source"; filename="`+name+`"`)
+
+		var w, _ = m.CreatePart(h)
+		w.Write([]byte(src))
+	}
+
+	m.Close()
+
+	return m.FormDataContentType(), buf.Bytes()
+}
+
+func TestReadUpload(t *testing.T) {
+	const (
+		profile = "mode: set\ngithub.com/acme/foo/foo.go:3.24,5.2 1 1\n"
+		source  = "package foo\n\nfunc Sum(a, b int) int {\n\treturn a + b\n}\n"
+	)
+
+	var (
+		funcs = []goqa.FuncCoverage{{Name: "Sum", StartLine: 3, EndLine: 5, Statements: 1, Covered: 1}}
+
+		plainType, plainBody             = form(profile, "", nil)
+		sourcedType, sourcedBody         = form(profile, "", map[string]string{"github.com/acme/foo/foo.go": source})
+		profilelessType, profilelessBody = form("", "", map[string]string{"github.com/acme/foo/foo.go": source})
+		badType, badBody                 = form(profile, "", map[string]string{"github.com/acme/foo/foo.go": "package"})
+		diffType, diffBody               = form(profile, "+++ b/foo.go\n@@ -3,0 +4 @@\n+\t// sum\n", nil)
+		badDiffType, badDiffBody         = form(profile, "+++ b/foo.go\n@@ -3 @@\n", nil)
+	)
+
+	tests := []struct {
+		name        string
+		ctype       string
+		body        []byte
+		wantFuncs   []goqa.FuncCoverage
+		wantChanges map[string][]int
+		wantErr     bool
+	}{
+		{
+			name:  "plain profile",
+			ctype: "text/plain",
+			body:  []byte(profile),
+		},
+		{
+			name:  "no content type",
+			ctype: "",
+			body:  []byte(profile),
+		},
+		{
+			name:  "form without source",
+			ctype: plainType,
+			body:  plainBody,
+		},
+		{
+			name:      "form with source",
+			ctype:     sourcedType,
+			body:      sourcedBody,
+			wantFuncs: funcs,
+		},
+		{
+			name:    "form without profile",
+			ctype:   profilelessType,
+			body:    profilelessBody,
+			wantErr: true,
+		},
+		{
+			name:    "form with bad source",
+			ctype:   badType,
+			body:    badBody,
+			wantErr: true,
+		},
+		{
+			name:        "form with diff",
+			ctype:       diffType,
+			body:        diffBody,
+			wantChanges: map[string][]int{"foo.go": {4}},
+		},
+		{
+			name:    "form with bad diff",
+			ctype:   badDiffType,
+			body:    badDiffBody,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, changes, err = ReadUpload(tt.ctype, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadUpload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if len(got.Files) != 1 || got.Files[0].File != "github.com/acme/foo/foo.go" || got.Files[0].Covered != 1 {
+				t.Errorf("ReadUpload() files = %+v", got.Files)
+				return
+			}
+
+			if !reflect.DeepEqual(got.Files[0].Funcs, tt.wantFuncs) {
+				t.Errorf("ReadUpload() funcs\nwant = %v\ngot  = %v", tt.wantFuncs, got.Files[0].Funcs)
+			}
+
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("ReadUpload() changes\nwant = %v\ngot  = %v", tt.wantChanges, changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package hook
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDeliveries is the number of deliveries remembered by default
+	DefaultDeliveries = 10000
+
+	// DefaultDeliveryTTL is how long deliveries are remembered by default; GitHub redelivers up to three days later
+	DefaultDeliveryTTL = 72 * time.Hour
+)
+
+// NewDeliveries remembering up to size deliveries for ttl; 0 for the defaults
+func NewDeliveries(size int, ttl time.Duration) *Deliveries {
+	if size <= 0 {
+		size = DefaultDeliveries
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultDeliveryTTL
+	}
+
+	return &Deliveries{size: size, ttl: ttl, seen: make(map[string]received)}
+}
+
+// Deliveries received lately by their ID, so that redeliveries are only processed once and replied to the same; nil
+// remembers none
+type Deliveries struct {
+	mut   sync.Mutex
+	size  int
+	ttl   time.Duration
+	seen  map[string]received
+	order []delivery // oldest first
+}
+
+// received delivery, with the receipt replied to it once processed
+type received struct {
+	at      time.Time
+	receipt *Receipt
+}
+
+type delivery struct {
+	id string
+	at time.Time
+}
+
+// Add a delivery received at t; false when it was already, along the receipt replied to it unless still processed,
+// deliveries without ID are never remembered
+func (d *Deliveries) Add(id string, t time.Time) (*Receipt, bool) {
+	if d == nil || id == "" {
+		return nil, true
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	for len(d.order) != 0 && t.Sub(d.order[0].at) >= d.ttl {
+		d.pop()
+	}
+
+	if r, ok := d.seen[id]; ok {
+		return r.receipt, false
+	}
+
+	for len(d.order) >= d.size {
+		d.pop()
+	}
+
+	d.seen[id] = received{at: t}
+	d.order = append(d.order, delivery{id: id, at: t})
+
+	return nil, true
+}
+
+// Reply to a delivery with a receipt, which its redeliveries get as well
+func (d *Deliveries) Reply(id string, receipt Receipt) {
+	if d == nil || id == "" {
+		return
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if r, ok := d.seen[id]; ok {
+		r.receipt = &receipt
+		d.seen[id] = r
+	}
+}
+
+// Forget a delivery, so that it is processed when delivered again e.g. it failed
+func (d *Deliveries) Forget(id string) {
+	if d == nil || id == "" {
+		return
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	delete(d.seen, id)
+}
+
+// pop the oldest delivery, unless it was forgotten and added again since
+func (d *Deliveries) pop() {
+	var oldest = d.order[0]
+	d.order = d.order[1:]
+
+	if r, ok := d.seen[oldest.id]; ok && r.at.Equal(oldest.at) {
+		delete(d.seen, oldest.id)
+	}
+}
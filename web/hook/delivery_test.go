@@ -0,0 +1,285 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/gate"
+	"github.com/fluxynet/goqa/internal"
+	"github.com/fluxynet/goqa/web"
+)
+
+var errPublish = errors.New("publish err")
+
+// failingbroker fails to publish until told otherwise
+type failingbroker struct {
+	fail   bool
+	events []goqa.Event
+}
+
+func (f *failingbroker) Listen(ctx context.Context) (<-chan goqa.Event, error) {
+	return nil, nil
+}
+
+func (f *failingbroker) Publish(ctx context.Context, event goqa.Event) error {
+	if f.fail {
+		return errPublish
+	}
+
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *failingbroker) Close() error {
+	return nil
+}
+
+func TestDeliveries_Add(t *testing.T) {
+	var t0 = time.Date(2021, 3, 7, 23, 9, 38, 0, time.UTC)
+
+	type add struct {
+		id   string
+		at   time.Duration // since t0
+		want bool
+	}
+
+	tests := []struct {
+		name   string
+		d      *Deliveries
+		forget string // forgotten after the first add
+		adds   []add
+	}{
+		{
+			name: "nil remembers none",
+			d:    nil,
+			adds: []add{{id: "a", want: true}, {id: "a", want: true}},
+		},
+		{
+			name: "without id",
+			d:    NewDeliveries(0, 0),
+			adds: []add{{id: "", want: true}, {id: "", want: true}},
+		},
+		{
+			name: "redelivered",
+			d:    NewDeliveries(0, 0),
+			adds: []add{{id: "a", want: true}, {id: "b", want: true}, {id: "a", at: time.Hour, want: false}},
+		},
+		{
+			name: "expired",
+			d:    NewDeliveries(10, time.Hour),
+			adds: []add{{id: "a", want: true}, {id: "a", at: 59 * time.Minute, want: false}, {id: "a", at: time.Hour, want: true}},
+		},
+		{
+			name: "bounded",
+			d:    NewDeliveries(2, time.Hour),
+			adds: []add{{id: "a", want: true}, {id: "b", want: true}, {id: "c", want: true}, {id: "b", want: false}, {id: "a", want: true}},
+		},
+		{
+			name:   "forgotten",
+			d:      NewDeliveries(2, time.Hour),
+			forget: "a",
+			adds:   []add{{id: "a", want: true}, {id: "a", at: time.Minute, want: true}, {id: "b", at: time.Minute, want: true}, {id: "a", at: time.Minute, want: false}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, a := range tt.adds {
+				if _, got := tt.d.Add(a.id, t0.Add(a.at)); got != a.want {
+					t.Errorf("Add(%d: %s) = %v, want %v", i, a.id, got, a.want)
+				}
+
+				if i == 0 {
+					tt.d.Forget(tt.forget)
+				}
+			}
+		})
+	}
+}
+
+func TestHook_Redelivery(t *testing.T) {
+	const body = `{"event":"push","repository":"acme/foo","commit":"c1","ref":"refs/heads/master","workflow":"Go","data":[` +
+		`{"Time":"2021-03-07T23:09:38Z","Action":"output","Package":"github.com/acme/foo","Output":"coverage: 83.3% of statements\n"}]}`
+
+	now = func() time.Time {
+		return time.Date(2021, 3, 8, 23, 9, 38, 0, time.UTC)
+	}
+
+	defer func() {
+		now = time.Now
+	}()
+
+	var (
+		b = &failingbroker{}
+		h = &Hook{Broker: b, SigKey: "foobar", Deliveries: NewDeliveries(0, 0), MaxAge: 48 * time.Hour}
+	)
+
+	tests := []struct {
+		name       string
+		delivery   string
+		fail       bool
+		maxAge     time.Duration
+		wantStatus int
+		wantBody   string
+		wantEvents int
+	}{
+		{
+			name:       "publish failed",
+			delivery:   "d1",
+			fail:       true,
+			maxAge:     48 * time.Hour,
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"error":"publish err"}`,
+		},
+		{
+			name:       "retried",
+			delivery:   "d1",
+			maxAge:     48 * time.Hour,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"message":"web hook well received"}`,
+			wantEvents: 1,
+		},
+		{
+			name:       "redelivered",
+			delivery:   "d1",
+			maxAge:     48 * time.Hour,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"message":"web hook already received"}`,
+			wantEvents: 1,
+		},
+		{
+			name:       "without delivery",
+			maxAge:     48 * time.Hour,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"message":"web hook well received"}`,
+			wantEvents: 2,
+		},
+		{
+			name:       "stale",
+			delivery:   "d2",
+			maxAge:     time.Hour,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"payload is too old"}`,
+			wantEvents: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				w = httptest.NewRecorder()
+				r = httptest.NewRequest(http.MethodPost, "/github", strings.NewReader(body))
+			)
+
+			b.fail = tt.fail
+			h.MaxAge = tt.maxAge
+
+			r.Header.Set(githubHeaderSignature, sign(body, "foobar"))
+			if tt.delivery != "" {
+				r.Header.Set(githubHeaderDelivery, tt.delivery)
+			}
+
+			h.Receive(w, r)
+
+			internal.AssertHttp(t, w, tt.wantStatus, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.wantBody)
+
+			if len(b.events) != tt.wantEvents {
+				t.Errorf("events; want %d, got %d", tt.wantEvents, len(b.events))
+			}
+		})
+	}
+}
+
+func TestDeliveries_Reply(t *testing.T) {
+	var (
+		d  = NewDeliveries(0, 0)
+		t0 = time.Date(2021, 3, 7, 23, 9, 38, 0, time.UTC)
+	)
+
+	d.Reply("a", Receipt{Message: "not received"}) // ignored
+
+	if receipt, first := d.Add("a", t0); !first || receipt != nil {
+		t.Errorf("Add() = %v, %v; want nil, true", receipt, first)
+	}
+
+	if receipt, first := d.Add("a", t0); first || receipt != nil {
+		t.Errorf("Add() while processed = %v, %v; want nil, false", receipt, first)
+	}
+
+	d.Reply("a", Receipt{Message: "received"})
+
+	if receipt, first := d.Add("a", t0); first || receipt == nil || receipt.Message != "received" {
+		t.Errorf("Add() once replied = %v, %v; want received, false", receipt, first)
+	}
+
+	var none *Deliveries
+	none.Reply("a", Receipt{}) // safe on nil
+}
+
+func TestHook_RedeliveryGate(t *testing.T) {
+	const body = `{"event":"push","repository":"acme/foo","commit":"c1","ref":"refs/heads/master","workflow":"Go","data":[` +
+		`{"Time":"2021-03-07T23:09:38Z","Action":"output","Package":"github.com/acme/foo","Output":"coverage: 40% of statements\n"}]}`
+
+	var policy, err = gate.New(gate.Rule{Min: 50})
+	if err != nil {
+		t.Fatalf("gate.New() error = %v", err)
+	}
+
+	var (
+		b = &failingbroker{}
+		h = &Hook{Broker: b, SigKey: "foobar", Gate: policy, Deliveries: NewDeliveries(0, 0)}
+	)
+
+	const failed = `"gate":{"passed":false,"checks":[{"pkg":"github.com/acme/foo","percentage":40,"passed":false,"reason":"coverage 40 % is below minimum 50 %"}]}}`
+
+	// received while the first delivery is processed
+	h.Deliveries.Add("d0", time.Now())
+
+	tests := []struct {
+		name       string
+		delivery   string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "first",
+			delivery:   "d1",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"message":"web hook well received",` + failed,
+		},
+		{
+			name:       "redelivered",
+			delivery:   "d1",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"message":"web hook already received",` + failed,
+		},
+		{
+			name:       "being processed",
+			delivery:   "d0",
+			wantStatus: http.StatusConflict,
+			wantBody:   `{"error":"web hook is being processed"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				w = httptest.NewRecorder()
+				r = httptest.NewRequest(http.MethodPost, "/github", strings.NewReader(body))
+			)
+
+			r.Header.Set(githubHeaderSignature, sign(body, "foobar"))
+			r.Header.Set(githubHeaderDelivery, tt.delivery)
+
+			h.Receive(w, r)
+
+			internal.AssertHttp(t, w, tt.wantStatus, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.wantBody)
+		})
+	}
+}
@@ -1,61 +1,248 @@
 package hook
 
 import (
-	"encoding/json"
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/coverprofile"
+	"github.com/fluxynet/goqa/gate"
 	"github.com/fluxynet/goqa/web"
 )
 
-const githubHeaderSignature = "X-Hub-Signature"
+// Source of the events published, see goqa.Envelope
+const Source = "hook"
+
+const (
+	githubHeaderSignature    = "X-Hub-Signature"
+	githubHeaderSignature256 = "X-Hub-Signature-256"
+	githubHeaderDelivery     = "X-Github-Delivery"
+)
 
 var (
 	errIncompleteRequest = errors.New("request incomplete")
+	errStale             = errors.New("payload is too old")
+	errInProgress        = errors.New("web hook is being processed")
 )
 
 type Hook struct {
 	Broker goqa.Broker
 	// SigKey used in hash
 	SigKey string
+
+	// SigKeys also accepted along SigKey, while rotating it
+	SigKeys []string
+
+	// RejectSHA1 signatures of GitHub, only trusting X-Hub-Signature-256
+	RejectSHA1 bool
+
+	// Gate is the quality gate policy; nil means no gates
+	Gate *gate.Policy
+
+	// Cache holds the coverage prior to the hook, for gates limiting drops
+	Cache goqa.Cache
+
+	// Deliveries received, so that redeliveries get the receipt of the first one without being processed again; nil to
+	// process all
+	Deliveries *Deliveries
+
+	// MaxAge of payloads, judged by the latest time reported by tests; 0 for no limit
+	MaxAge time.Duration
+}
+
+// Receipt is the reply to a web hook; CI may fail the build based on Gate
+type Receipt struct {
+	Message string       `json:"message"`
+	Gate    *gate.Result `json:"gate,omitempty"`
 }
 
-// Receive a web hook
+// Receive a web hook from GitHub Actions
 func (h *Hook) Receive(w http.ResponseWriter, r *http.Request) {
+	h.ingest(w, r, h.github())
+}
+
+// github adapter out of the signature settings
+func (h *Hook) github() Github {
+	return Github{SigKeys: append([]string{h.SigKey}, h.SigKeys...), RejectSHA1: h.RejectSHA1}
+}
+
+// Ingest web hooks of another CI through its adapter
+func (h *Hook) Ingest(a Adapter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.ingest(w, r, a)
+	}
+}
+
+// ingest a web hook verified and read by an adapter
+func (h *Hook) ingest(w http.ResponseWriter, r *http.Request, a Adapter) {
+	var body, err = web.ReadBody(r)
+	if err != nil || len(body) == 0 {
+		web.JsonError(w, http.StatusBadRequest, errIncompleteRequest)
+		return
+	}
+
+	if err = a.Verify(r, body); err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload *Payload
+	if payload, err = a.Payload(body); err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if h.stale(payload.Data) {
+		web.JsonError(w, http.StatusBadRequest, errStale)
+		return
+	}
+
+	var delivery = a.Delivery(r)
+	if h.redelivered(w, delivery, "web hook already received") {
+		return
+	}
+
+	var event = CreateGithubEvent(payload)
+	if len(event.Coverage) == 0 && len(event.Tests) == 0 {
+		var receipt = Receipt{Message: "web hook was not very interesting"}
+		h.Deliveries.Reply(delivery, receipt)
+		web.Json(w, receipt)
+		return
+	}
+
+	h.publish(w, r, event, delivery)
+}
+
+// stale tells whether the latest time reported by tests is older than MaxAge; payloads without times are not
+func (h *Hook) stale(data []Datum) bool {
+	if h.MaxAge <= 0 {
+		return false
+	}
+
+	var latest time.Time
+	for i := range data {
+		if t, err := time.Parse(time.RFC3339Nano, data[i].Time); err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+
+	return !latest.IsZero() && now().Sub(latest) > h.MaxAge
+}
+
+// Upload a coverprofile as written by go test -coverprofile; /upload?repository=&commit=&ref=&workflow=&head=&base=
+// the query followed by a line feed then the body are signed the same way as web hooks, so that a signed upload
+// cannot be replayed for another repository or commit; the body is either the coverprofile itself or a multipart
+// form with a profile part and source parts named after files of the profile, for a function breakdown;
+// pull requests give their base commit and may add a diff part against it, for patch coverage
+func (h *Hook) Upload(w http.ResponseWriter, r *http.Request) {
 	var (
 		body, err = web.ReadBody(r)
-		signature = r.Header.Get(githubHeaderSignature)
+		q         = r.URL.Query()
 	)
 
-	if err != nil || len(body) == 0 || signature == "" {
+	if err != nil || len(body) == 0 || q.Get("repository") == "" || q.Get("commit") == "" {
 		web.JsonError(w, http.StatusBadRequest, errIncompleteRequest)
 		return
 	}
 
-	if err = web.VerifyBody(body, signature, h.SigKey); err != nil {
+	if err = h.github().Verify(r, signedUpload(r.URL.RawQuery, body)); err != nil {
 		web.JsonError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	var payload *Payload
-	err = json.Unmarshal(body, &payload)
-	if err != nil {
-		web.JsonError(w, http.StatusBadRequest, errIncompleteRequest)
+	var (
+		profile *coverprofile.Profile
+		changes map[string][]int
+	)
+
+	if profile, changes, err = ReadUpload(r.Header.Get("Content-Type"), body); err != nil {
+		web.JsonError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	var event = CreateGithubEvent(payload)
+	var event = CreateProfileEvent(q.Get("repository"), q.Get("commit"), q.Get("ref"), q.Get("workflow"), profile)
 	if len(event.Coverage) == 0 {
-		web.Json(w, web.Response{Message: "web hook was not very interesting"})
+		web.Json(w, web.Response{Message: "coverprofile was not very interesting"})
 		return
 	}
 
-	err = h.Broker.Publish(r.Context(), event)
+	var delivery = h.github().Delivery(r)
+	if h.redelivered(w, delivery, "coverprofile already received") {
+		return
+	}
+
+	event.Head = q.Get("head")
+	event.Base = q.Get("base")
+	event.Changes = changes
+
+	h.publish(w, r, event, delivery)
+}
+
+// signedUpload is what the signature of an upload is made of: its query, a line feed then its body
+func signedUpload(query string, body []byte) []byte {
+	var signed = make([]byte, 0, len(query)+1+len(body))
+	signed = append(signed, query...)
+	signed = append(signed, '\n')
+
+	return append(signed, body...)
+}
+
+// redelivered replies to a delivery already received with the receipt of the first one under another message, or
+// with a conflict while the first one is processed; false when it was not received yet
+func (h *Hook) redelivered(w http.ResponseWriter, delivery, message string) bool {
+	var receipt, first = h.Deliveries.Add(delivery, now())
+	if first {
+		return false
+	}
+
+	if receipt == nil {
+		web.JsonError(w, http.StatusConflict, errInProgress)
+		return true
+	}
+
+	var again = *receipt
+	again.Message = message
+	web.Json(w, again)
+
+	return true
+}
+
+// publish an event once gates are evaluated, replying with a Receipt; the delivery is forgotten when it fails,
+// its redeliveries get the receipt otherwise even if the gate failure could not be published
+func (h *Hook) publish(w http.ResponseWriter, r *http.Request, event *goqa.GithubEvent, delivery string) {
+	// evaluated before publishing, while the cache still holds previous values
+	var result = h.Gate.Evaluate(event, h.Cache)
+
+	var sealed = goqa.Seal(Source, event)
+
+	var err = h.Broker.Publish(r.Context(), sealed)
 	if err != nil {
+		h.Deliveries.Forget(delivery)
 		web.JsonError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	web.Json(w, web.Response{Message: "web hook well received"})
+	var receipt = Receipt{Message: "web hook well received"}
+	if h.Gate != nil {
+		receipt.Gate = &result
+	}
+
+	h.Deliveries.Reply(delivery, receipt)
+
+	if !result.Passed {
+		err = h.Broker.Publish(r.Context(), goqa.Derive(sealed, Source, goqa.GateFailedEvent{
+			Repository: event.Repository,
+			Ref:        event.Ref,
+			Commit:     event.Commit,
+			Failures:   result.Failures(),
+		}))
+
+		if err != nil {
+			web.JsonError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	web.Json(w, receipt)
 }
@@ -2,12 +2,17 @@ package hook
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/gate"
 	"github.com/fluxynet/goqa/internal"
 	"github.com/fluxynet/goqa/web"
 )
@@ -21,7 +26,7 @@ func (f *fakebroker) Listen(ctx context.Context) (<-chan goqa.Event, error) {
 }
 
 func (f *fakebroker) Publish(ctx context.Context, event goqa.Event) error {
-	f.event = event
+	f.event = goqa.Open(event) // envelopes are tested by goqa
 	return nil
 }
 
@@ -129,15 +134,15 @@ func TestHook_Receive(t *testing.T) {
 			},
 		},
 		{
-			name: "good signature no coverages",
+			name: "good signature nothing interesting",
 			fields: fields{
 				SigKey: "foobar",
 			},
 			args: args{
 				headers: http.Header{
-					githubHeaderSignature: []string{"sha1=c059bcbae7f4ca03f2313bce5ec7692a7e0cba87"},
+					githubHeaderSignature: []string{"sha1=63628f2ae35499fd169975ccb1a8ac591128edea"},
 				},
-				body: `{"event":"push","repository":"fluxynet/go-test-example","commit":"1320d4f1cf36041e6d34ff45ed8661d5940806db","ref":"refs/heads/master","head":"","workflow":"Go","data":[{"Time":"2021-03-07T23:09:38.67302542Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/1_Positive","Output":"    --- PASS: TestSum/1_Positive (0.00s)\n"},{"Time":"2021-03-07T23:09:38.67302942Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/1_Positive","Elapsed":0},{"Time":"2021-03-07T23:09:38.67303332Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/2_Positive","Output":"    --- PASS: TestSum/2_Positive (0.00s)\n"},{"Time":"2021-03-07T23:09:38.67303712Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/2_Positive","Elapsed":0},{"Time":"2021-03-07T23:09:38.673040821Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/1_Negative","Output":"    --- PASS: TestSum/1_Negative (0.00s)\n"},{"Time":"2021-03-07T23:09:38.673044821Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/1_Negative","Elapsed":0},{"Time":"2021-03-07T23:09:38.673048321Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/2_Negative","Output":"    --- PASS: TestSum/2_Negative (0.00s)\n"},{"Time":"2021-03-07T23:09:38.673052121Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/2_Negative","Elapsed":0},{"Time":"2021-03-07T23:09:38.673056222Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/4_Negative_/_Positive","Output":"    --- PASS: TestSum/4_Negative_/_Positive (0.00s)\n"},{"Time":"2021-03-07T23:09:38.673062122Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/4_Negative_/_Positive","Elapsed":0},{"Time":"2021-03-07T23:09:38.673065422Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Test":"TestSum","Elapsed":0},{"Time":"2021-03-07T23:09:38.673068822Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Output":"PASS\n"},{"Time":"2021-03-07T23:09:38.675521879Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Elapsed":0.006}]}`,
+				body: `{"event":"push","repository":"fluxynet/go-test-example","commit":"1320d4f1cf36041e6d34ff45ed8661d5940806db","ref":"refs/heads/master","head":"","workflow":"Go","data":[{"Time":"2021-03-07T23:09:38.673068822Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Output":"PASS\n"},{"Time":"2021-03-07T23:09:38.675521879Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Elapsed":0.006}]}`,
 			},
 			want: want{
 				status: http.StatusOK,
@@ -148,6 +153,85 @@ func TestHook_Receive(t *testing.T) {
 				event: nil,
 			},
 		},
+		{
+			name: "good signature tests only",
+			fields: fields{
+				SigKey: "foobar",
+			},
+			args: args{
+				headers: http.Header{
+					githubHeaderSignature: []string{"sha1=c059bcbae7f4ca03f2313bce5ec7692a7e0cba87"},
+				},
+				body: `{"event":"push","repository":"fluxynet/go-test-example","commit":"1320d4f1cf36041e6d34ff45ed8661d5940806db","ref":"refs/heads/master","head":"","workflow":"Go","data":[{"Time":"2021-03-07T23:09:38.67302542Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/1_Positive","Output":"    --- PASS: TestSum/1_Positive (0.00s)\n"},{"Time":"2021-03-07T23:09:38.67302942Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/1_Positive","Elapsed":0},{"Time":"2021-03-07T23:09:38.67303332Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/2_Positive","Output":"    --- PASS: TestSum/2_Positive (0.00s)\n"},{"Time":"2021-03-07T23:09:38.67303712Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/2_Positive","Elapsed":0},{"Time":"2021-03-07T23:09:38.673040821Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/1_Negative","Output":"    --- PASS: TestSum/1_Negative (0.00s)\n"},{"Time":"2021-03-07T23:09:38.673044821Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/1_Negative","Elapsed":0},{"Time":"2021-03-07T23:09:38.673048321Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/2_Negative","Output":"    --- PASS: TestSum/2_Negative (0.00s)\n"},{"Time":"2021-03-07T23:09:38.673052121Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/2_Negative","Elapsed":0},{"Time":"2021-03-07T23:09:38.673056222Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/4_Negative_/_Positive","Output":"    --- PASS: TestSum/4_Negative_/_Positive (0.00s)\n"},{"Time":"2021-03-07T23:09:38.673062122Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Test":"TestSum/4_Negative_/_Positive","Elapsed":0},{"Time":"2021-03-07T23:09:38.673065422Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Test":"TestSum","Elapsed":0},{"Time":"2021-03-07T23:09:38.673068822Z","Action":"output","Package":"github.com/fluxynet/go-test-example","Output":"PASS\n"},{"Time":"2021-03-07T23:09:38.675521879Z","Action":"pass","Package":"github.com/fluxynet/go-test-example","Elapsed":0.006}]}`,
+			},
+			want: want{
+				status: http.StatusOK,
+				headers: http.Header{
+					"Content-Type": []string{web.ContentTypeJSON},
+				},
+				body: `{"message":"web hook well received"}`,
+				event: goqa.GithubEvent{
+					Event:      "push",
+					Repository: "fluxynet/go-test-example",
+					Commit:     "1320d4f1cf36041e6d34ff45ed8661d5940806db",
+					Ref:        "refs/heads/master",
+					Head:       "",
+					Workflow:   "Go",
+					Tests: []goqa.TestResult{
+						{
+							Pkg:    "github.com/fluxynet/go-test-example",
+							Test:   "TestSum/1_Positive",
+							Status: goqa.TestPass,
+							Output: "    --- PASS: TestSum/1_Positive (0.00s)\n",
+							Time:   "2021-03-07T23:09:38.67302542Z",
+						},
+						{
+							Pkg:    "github.com/fluxynet/go-test-example",
+							Test:   "TestSum/2_Positive",
+							Status: goqa.TestPass,
+							Output: "    --- PASS: TestSum/2_Positive (0.00s)\n",
+							Time:   "2021-03-07T23:09:38.67303332Z",
+						},
+						{
+							Pkg:    "github.com/fluxynet/go-test-example",
+							Test:   "TestSum/1_Negative",
+							Status: goqa.TestPass,
+							Output: "    --- PASS: TestSum/1_Negative (0.00s)\n",
+							Time:   "2021-03-07T23:09:38.673040821Z",
+						},
+						{
+							Pkg:    "github.com/fluxynet/go-test-example",
+							Test:   "TestSum/2_Negative",
+							Status: goqa.TestPass,
+							Output: "    --- PASS: TestSum/2_Negative (0.00s)\n",
+							Time:   "2021-03-07T23:09:38.673048321Z",
+						},
+						{
+							Pkg:    "github.com/fluxynet/go-test-example",
+							Test:   "TestSum/4_Negative_/_Positive",
+							Status: goqa.TestPass,
+							Output: "    --- PASS: TestSum/4_Negative_/_Positive (0.00s)\n",
+							Time:   "2021-03-07T23:09:38.673056222Z",
+						},
+						{
+							Pkg:    "github.com/fluxynet/go-test-example",
+							Test:   "TestSum",
+							Status: goqa.TestPass,
+							Output: "",
+							Time:   "2021-03-07T23:09:38.673065422Z",
+						},
+					},
+					Packages: []goqa.PackageResult{
+						{
+							Pkg:     "github.com/fluxynet/go-test-example",
+							Status:  goqa.TestPass,
+							Elapsed: 0.006,
+							Time:    "2021-03-07T23:09:38.675521879Z",
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "good signature with coverages",
 			fields: fields{
@@ -174,11 +258,66 @@ func TestHook_Receive(t *testing.T) {
 					Workflow:   "Go",
 					Coverage: []goqa.Coverage{
 						{
+							Repository: "fluxynet/go-test-example",
+							Ref:        "refs/heads/master",
 							Pkg:        "github.com/fluxynet/go-test-example",
 							Percentage: 83,
+							Precise:    83.3,
 							Time:       "2021-03-07T23:09:38.673072523Z",
 						},
 					},
+					Tests: []goqa.TestResult{
+						{
+							Pkg:    "github.com/fluxynet/go-test-example",
+							Test:   "TestSum/1_Positive",
+							Status: goqa.TestPass,
+							Output: "    --- PASS: TestSum/1_Positive (0.00s)\n",
+							Time:   "2021-03-07T23:09:38.67302542Z",
+						},
+						{
+							Pkg:    "github.com/fluxynet/go-test-example",
+							Test:   "TestSum/2_Positive",
+							Status: goqa.TestPass,
+							Output: "    --- PASS: TestSum/2_Positive (0.00s)\n",
+							Time:   "2021-03-07T23:09:38.67303332Z",
+						},
+						{
+							Pkg:    "github.com/fluxynet/go-test-example",
+							Test:   "TestSum/1_Negative",
+							Status: goqa.TestPass,
+							Output: "    --- PASS: TestSum/1_Negative (0.00s)\n",
+							Time:   "2021-03-07T23:09:38.673040821Z",
+						},
+						{
+							Pkg:    "github.com/fluxynet/go-test-example",
+							Test:   "TestSum/2_Negative",
+							Status: goqa.TestPass,
+							Output: "    --- PASS: TestSum/2_Negative (0.00s)\n",
+							Time:   "2021-03-07T23:09:38.673048321Z",
+						},
+						{
+							Pkg:    "github.com/fluxynet/go-test-example",
+							Test:   "TestSum/4_Negative_/_Positive",
+							Status: goqa.TestPass,
+							Output: "    --- PASS: TestSum/4_Negative_/_Positive (0.00s)\n",
+							Time:   "2021-03-07T23:09:38.673056222Z",
+						},
+						{
+							Pkg:    "github.com/fluxynet/go-test-example",
+							Test:   "TestSum",
+							Status: goqa.TestPass,
+							Output: "",
+							Time:   "2021-03-07T23:09:38.673065422Z",
+						},
+					},
+					Packages: []goqa.PackageResult{
+						{
+							Pkg:     "github.com/fluxynet/go-test-example",
+							Status:  goqa.TestPass,
+							Elapsed: 0.006,
+							Time:    "2021-03-07T23:09:38.675521879Z",
+						},
+					},
 				},
 			},
 		},
@@ -230,3 +369,245 @@ func TestHook_Receive(t *testing.T) {
 		})
 	}
 }
+
+type eventsbroker struct {
+	events []goqa.Event
+}
+
+func (f *eventsbroker) Listen(ctx context.Context) (<-chan goqa.Event, error) {
+	return nil, nil
+}
+
+func (f *eventsbroker) Publish(ctx context.Context, event goqa.Event) error {
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *eventsbroker) Close() error {
+	return nil
+}
+
+func sign(body, key string) string {
+	var h = hmac.New(sha1.New, []byte(key))
+	h.Write([]byte(body))
+
+	return "sha1=" + hex.EncodeToString(h.Sum(nil))
+}
+
+// signUpload as uploads are signed, along with the query of their path
+func signUpload(path, body, key string) string {
+	return sign(path[strings.Index(path, "?")+1:]+"\n"+body, key)
+}
+
+func TestHook_ReceiveGate(t *testing.T) {
+	const body = `{"event":"push","repository":"acme/foo","commit":"c1","ref":"refs/heads/master","workflow":"Go","data":[` +
+		`{"Time":"2021-03-07T23:09:38Z","Action":"output","Package":"github.com/acme/foo","Output":"coverage: 83.3% of statements\n"},` +
+		`{"Time":"2021-03-07T23:09:38Z","Action":"output","Package":"github.com/acme/foo/internal/bar","Output":"coverage: 40% of statements\n"}]}`
+
+	var policy, err = gate.New(gate.Rule{Pkg: "github.com/acme/*/internal/...", Min: 50})
+	if err != nil {
+		t.Errorf("gate.New() error = %v", err)
+		return
+	}
+
+	tests := []struct {
+		name   string
+		gate   *gate.Policy
+		body   string
+		events []string
+	}{
+		{
+			name:   "no gate",
+			gate:   nil,
+			body:   `{"message":"web hook well received"}`,
+			events: []string{goqa.EventGithub},
+		},
+		{
+			name: "failed gate",
+			gate: policy,
+			body: `{"message":"web hook well received","gate":{"passed":false,"checks":[` +
+				`{"pkg":"github.com/acme/foo/internal/bar","percentage":40,"passed":false,"reason":"coverage 40 % is below minimum 50 %"}]}}`,
+			events: []string{goqa.EventGithub, goqa.EventGateFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &eventsbroker{}
+			h := &Hook{
+				Broker: b,
+				SigKey: "foobar",
+				Gate:   tt.gate,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			r.Header.Set(githubHeaderSignature, sign(body, "foobar"))
+
+			h.Receive(w, r)
+
+			internal.AssertHttp(t, w, http.StatusOK, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.body)
+
+			if len(b.events) != len(tt.events) {
+				t.Errorf("events want = %v, got = %v", tt.events, b.events)
+				return
+			}
+
+			for i := range tt.events {
+				if b.events[i].Name() != tt.events[i] {
+					t.Errorf("event(%d) want = %s, got = %s", i, tt.events[i], b.events[i].Name())
+				}
+			}
+
+			// events are sealed, those caused by the web hook sharing its correlation
+			var first, ok = goqa.Enveloped(b.events[0])
+			if !ok || first.Source != Source || first.Correlation != first.ID {
+				t.Errorf("event(0) envelope = %#v", first)
+			}
+
+			for i := 1; i < len(b.events); i++ {
+				if e, _ := goqa.Enveloped(b.events[i]); e.Correlation != first.ID {
+					t.Errorf("event(%d) correlation want = %s, got = %s", i, first.ID, e.Correlation)
+				}
+			}
+		})
+	}
+}
+
+func TestHook_Upload(t *testing.T) {
+	const profile = "mode: set\n" +
+		"github.com/acme/foo/foo.go:3.10,5.2 1 1\n" +
+		"github.com/acme/foo/foo.go:7.2,9.3 2 0\n"
+
+	now = func() time.Time {
+		return time.Date(2021, 3, 7, 23, 9, 38, 0, time.UTC)
+	}
+
+	defer func() {
+		now = time.Now
+	}()
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		sig    string
+		status int
+		reply  string
+		want   *goqa.GithubEvent
+	}{
+		{
+			name:   "no repository",
+			path:   "/upload?commit=c1",
+			body:   profile,
+			sig:    signUpload("/upload?commit=c1", profile, "foobar"),
+			status: http.StatusBadRequest,
+			reply:  `{"error":"request incomplete"}`,
+		},
+		{
+			name:   "no signature",
+			path:   "/upload?repository=acme/foo&commit=c1",
+			body:   profile,
+			status: http.StatusBadRequest,
+			reply:  `{"error":"request incomplete"}`,
+		},
+		{
+			name:   "bad signature",
+			path:   "/upload?repository=acme/foo&commit=c1",
+			body:   profile,
+			sig:    signUpload("/upload?repository=acme/foo&commit=c1", profile, "barfoo"),
+			status: http.StatusBadRequest,
+			reply:  `{"error":"payload could not be verified"}`,
+		},
+		{
+			name:   "replayed for another repository",
+			path:   "/upload?repository=acme/bar&commit=c1",
+			body:   profile,
+			sig:    signUpload("/upload?repository=acme/foo&commit=c1", profile, "foobar"),
+			status: http.StatusBadRequest,
+			reply:  `{"error":"payload could not be verified"}`,
+		},
+		{
+			name:   "not a coverprofile",
+			path:   "/upload?repository=acme/foo&commit=c1",
+			body:   "coverage: 50% of statements",
+			sig:    signUpload("/upload?repository=acme/foo&commit=c1", "coverage: 50% of statements", "foobar"),
+			status: http.StatusBadRequest,
+			reply:  `{"error":"coverprofile mode is missing or unsupported"}`,
+		},
+		{
+			name:   "nothing interesting",
+			path:   "/upload?repository=acme/foo&commit=c1",
+			body:   "mode: set\n",
+			sig:    signUpload("/upload?repository=acme/foo&commit=c1", "mode: set\n", "foobar"),
+			status: http.StatusOK,
+			reply:  `{"message":"coverprofile was not very interesting"}`,
+		},
+		{
+			name:   "coverprofile",
+			path:   "/upload?repository=acme/foo&commit=c1&ref=refs/heads/master&workflow=Go",
+			body:   profile,
+			sig:    signUpload("/upload?repository=acme/foo&commit=c1&ref=refs/heads/master&workflow=Go", profile, "foobar"),
+			status: http.StatusOK,
+			reply:  `{"message":"web hook well received"}`,
+			want: &goqa.GithubEvent{
+				Event:      "coverprofile",
+				Repository: "acme/foo",
+				Commit:     "c1",
+				Ref:        "refs/heads/master",
+				Workflow:   "Go",
+				Coverage: []goqa.Coverage{
+					{Repository: "acme/foo", Ref: "refs/heads/master", Pkg: "github.com/acme/foo", Percentage: 33, Precise: 33.3, Statements: 3, Covered: 1, Time: "2021-03-07T23:09:38Z"},
+				},
+				Files: []goqa.FileCoverage{
+					{
+						Pkg:        "github.com/acme/foo",
+						File:       "github.com/acme/foo/foo.go",
+						Statements: 3,
+						Covered:    1,
+						Blocks: []goqa.Block{
+							{StartLine: 3, StartCol: 10, EndLine: 5, EndCol: 2, Statements: 1, Count: 1},
+							{StartLine: 7, StartCol: 2, EndLine: 9, EndCol: 3, Statements: 2},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &fakebroker{}
+			h := &Hook{
+				Broker: b,
+				SigKey: "foobar",
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.sig != "" {
+				r.Header.Set(githubHeaderSignature, tt.sig)
+			}
+
+			h.Upload(w, r)
+
+			internal.AssertHttp(t, w, tt.status, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.reply)
+
+			if tt.want == nil {
+				if b.event != nil {
+					t.Errorf("unexpected event: %v", b.event)
+				}
+
+				return
+			}
+
+			var got, ok = b.event.(*goqa.GithubEvent)
+			if !ok {
+				t.Errorf("got event is not github event: %v", b.event)
+				return
+			}
+
+			internal.AssertGithubEventsEqual(t, got, tt.want)
+		})
+	}
+}
@@ -1,11 +1,21 @@
 package server
 
 import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/badge"
+	"github.com/fluxynet/goqa/duration"
+	"github.com/fluxynet/goqa/flaky"
 	"github.com/fluxynet/goqa/roster"
 	"github.com/fluxynet/goqa/subscriber/sse"
 	"github.com/fluxynet/goqa/web"
@@ -14,36 +24,666 @@ import (
 type Server struct {
 	Prefix    string
 	Cache     goqa.Cache
+	Repo      goqa.Repo
 	Broker    goqa.Broker
 	Roster    goqa.Roster
 	IndexHTML []byte
+
+	// FlakyWindow is the number of latest runs considered per test for flakiness
+	FlakyWindow int
+
+	// DurationWindow is the number of latest passed runs considered per test for durations
+	DurationWindow int
+
+	// BadgeBands are the colors of badges by coverage; nil for badge.DefaultBands
+	BadgeBands []badge.Band
+}
+
+// badgePrefix is where badges are served, outside of Prefix as they are not part of the api
+const badgePrefix = "/badge/"
+
+// Point is the coverage of a package at a commit, for charting
+type Point struct {
+	Repository string  `json:"repository"`
+	Ref        string  `json:"ref"`
+	Commit     string  `json:"commit"`
+	Workflow   string  `json:"workflow"`
+	Pkg        string  `json:"pkg"`
+	Percentage int     `json:"percentage"`
+	Precise    float64 `json:"precise"`
+	Time       string  `json:"time"`
+}
+
+// points out of records, in the order of records then packages
+func points(recs []goqa.Record) []Point {
+	var pts = []Point{}
+
+	for i := range recs {
+		var covs = make([]goqa.Coverage, len(recs[i].Coverage))
+		copy(covs, recs[i].Coverage)
+
+		sort.SliceStable(covs, func(a, b int) bool {
+			return covs[a].Pkg < covs[b].Pkg
+		})
+
+		for j := range covs {
+			pts = append(pts, Point{
+				Repository: recs[i].Repository,
+				Ref:        recs[i].Ref,
+				Commit:     recs[i].Commit,
+				Workflow:   recs[i].Workflow,
+				Pkg:        covs[j].Pkg,
+				Percentage: covs[j].Percentage,
+				Precise:    covs[j].Value(),
+				Time:       recs[i].Time,
+			})
+		}
+	}
+
+	return pts
+}
+
+// parseTime from a query parameter; either RFC3339 or a plain date
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, nil
+	}
+
+	return time.Parse("2006-01-02", v)
+}
+
+// keyOf a request; pkg is the path after the prefix, repository and ref are query parameters
+func (s *Server) keyOf(r *http.Request) goqa.Key {
+	var q = r.URL.Query()
+
+	return goqa.Key{
+		Repository: q.Get("repository"),
+		Ref:        q.Get("ref"),
+		Pkg:        strings.TrimPrefix(r.URL.Path, s.Prefix),
+	}
 }
 
-// List endpoint for coverage list api endpoint
+// List endpoint for coverage list api endpoint; optionally filtered by repository and ref query parameters
 func (s *Server) List(w http.ResponseWriter, r *http.Request) {
-	var keys, err = s.Cache.Keys()
+	var all, err = s.Cache.Keys()
 	if err != nil {
 		web.JsonError(w, http.StatusInternalServerError, err)
 		return
-	} else if keys == nil {
-		keys = []string{}
 	}
 
+	var (
+		q      = r.URL.Query()
+		filter = goqa.Key{Repository: q.Get("repository"), Ref: q.Get("ref")}
+		keys   = []goqa.Key{}
+	)
+
+	for i := range all {
+		if filter.Match(all[i]) {
+			keys = append(keys, all[i])
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
 	web.Json(w, keys)
 }
 
-// Get endpoint for single coverage api endpoint
+// Get endpoint for single coverage api endpoint; the latest of the package when repository or ref are not given
+// paths of files which are not those of a package are served by FileCoverage
 func (s *Server) Get(w http.ResponseWriter, r *http.Request) {
-	var pkg = strings.TrimPrefix(r.URL.Path, s.Prefix)
+	var (
+		key     = s.keyOf(r)
+		cov, ok = s.Cache.Get(key)
+	)
+
+	if !ok && (key.Repository == "" || key.Ref == "") {
+		var err error
+		if cov, err = s.latest(key); err != nil {
+			web.JsonError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		ok = cov != nil
+	}
+
+	if !ok {
+		if _, _, _, files := filesRoute(key.Pkg, key.Repository); files {
+			s.FileCoverage(w, r) // as the paths of files start like those of packages
+			return
+		}
+
+		web.JsonError(w, http.StatusNotFound, web.ErrResourceNotFound)
+		return
+	}
+
+	web.Json(w, cov.Migrate())
+}
+
+// History endpoint for coverage of a package over time; /history/{pkg}?repository=&ref=&from=&to=
+func (s *Server) History(w http.ResponseWriter, r *http.Request) {
+	var (
+		q   = r.URL.Query()
+		pkg = strings.TrimPrefix(r.URL.Path, s.Prefix+"history/")
+	)
+
+	if pkg == "" {
+		web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+		return
+	}
+
+	var from, err = parseTime(q.Get("from"))
+	if err != nil {
+		web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+		return
+	}
+
+	var to time.Time
+	if to, err = parseTime(q.Get("to")); err != nil {
+		web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+		return
+	}
+
+	var recs []goqa.Record
+	recs, err = s.Repo.History(r.Context(), goqa.HistoryQuery{
+		Repository: q.Get("repository"),
+		Ref:        q.Get("ref"),
+		Pkg:        pkg,
+		From:       from,
+		To:         to,
+	})
+
+	if err != nil {
+		web.JsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	web.Json(w, points(recs))
+}
+
+// Commit endpoint for coverage of all packages at a commit; /commits/{sha}?repository=
+func (s *Server) Commit(w http.ResponseWriter, r *http.Request) {
+	var sha = strings.TrimPrefix(r.URL.Path, s.Prefix+"commits/")
+	if sha == "" || strings.Contains(sha, "/") {
+		web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+		return
+	}
+
+	var recs, err = s.Repo.History(r.Context(), goqa.HistoryQuery{
+		Repository: r.URL.Query().Get("repository"),
+		Commit:     sha,
+	})
+
+	if err != nil {
+		web.JsonError(w, http.StatusInternalServerError, err)
+		return
+	} else if len(recs) == 0 {
+		web.JsonError(w, http.StatusNotFound, web.ErrResourceNotFound)
+		return
+	}
+
+	web.Json(w, points(recs))
+}
+
+// Diff endpoint for coverage of a pull request compared to its base; /diff?repository=&base=&head=&workflow=
+// base and head are commits, records of the workflow are preferred when a commit has several
+func (s *Server) Diff(w http.ResponseWriter, r *http.Request) {
+	var (
+		q          = r.URL.Query()
+		repository = q.Get("repository")
+	)
+
+	if repository == "" || q.Get("base") == "" || q.Get("head") == "" {
+		web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+		return
+	}
+
+	var recs [2]goqa.Record
+	for i, commit := range []string{q.Get("base"), q.Get("head")} {
+		var found, err = s.Repo.History(r.Context(), goqa.HistoryQuery{Repository: repository, Commit: commit, Files: true})
+		if err != nil {
+			web.JsonError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		var ok bool
+		if recs[i], ok = goqa.Latest(found, q.Get("workflow")); !ok {
+			web.JsonError(w, http.StatusNotFound, web.ErrResourceNotFound)
+			return
+		}
+	}
+
+	web.Json(w, goqa.Compare(recs[0], recs[1]))
+}
+
+// Tests endpoint for test results of a package; /tests/{pkg}?repository=&ref=&commit=
+// results are those of the latest matching record
+func (s *Server) Tests(w http.ResponseWriter, r *http.Request) {
+	var (
+		q   = r.URL.Query()
+		pkg = strings.TrimPrefix(r.URL.Path, s.Prefix+"tests/")
+	)
+
+	if pkg == "" {
+		web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+		return
+	}
+
+	var recs, err = s.Repo.History(r.Context(), goqa.HistoryQuery{
+		Repository: q.Get("repository"),
+		Ref:        q.Get("ref"),
+		Commit:     q.Get("commit"),
+		Pkg:        pkg,
+	})
+
+	if err != nil {
+		web.JsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	for i := len(recs) - 1; i >= 0; i-- {
+		if len(recs[i].Tests) != 0 {
+			web.Json(w, recs[i].Tests)
+			return
+		}
+	}
+
+	web.JsonError(w, http.StatusNotFound, web.ErrResourceNotFound)
+}
+
+// Flaky endpoint for flakiness scores of tests; /flaky?repository=&ref=&min=
+// only tests with a positive score are listed unless min is given
+func (s *Server) Flaky(w http.ResponseWriter, r *http.Request) {
+	var (
+		q       = r.URL.Query()
+		least   float64
+		minimal = q.Get("min") != ""
+	)
+
+	if minimal {
+		var err error
+		if least, err = strconv.ParseFloat(q.Get("min"), 64); err != nil {
+			web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+			return
+		}
+	}
+
+	var recs, err = s.Repo.History(r.Context(), goqa.HistoryQuery{
+		Repository: q.Get("repository"),
+		Ref:        q.Get("ref"),
+	})
+
+	if err != nil {
+		web.JsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var scores = []flaky.Score{}
+	for _, sc := range flaky.Analyze(recs, s.FlakyWindow) {
+		if (minimal && sc.Score >= least) || (!minimal && sc.Score > 0) {
+			scores = append(scores, sc)
+		}
+	}
+
+	web.Json(w, scores)
+}
+
+// Durations endpoint for duration trends of a package and its tests; /durations/{pkg}?repository=&ref=&from=&to=
+// trends are sorted by repository, package then test, the package itself being listed first with an empty test
+func (s *Server) Durations(w http.ResponseWriter, r *http.Request) {
+	var (
+		q   = r.URL.Query()
+		pkg = strings.TrimPrefix(r.URL.Path, s.Prefix+"durations/")
+	)
+
+	if pkg == "" {
+		web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+		return
+	}
+
+	var from, err = parseTime(q.Get("from"))
+	if err != nil {
+		web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+		return
+	}
+
+	var to time.Time
+	if to, err = parseTime(q.Get("to")); err != nil {
+		web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+		return
+	}
+
+	var recs []goqa.Record
+	recs, err = s.Repo.History(r.Context(), goqa.HistoryQuery{
+		Repository: q.Get("repository"),
+		Ref:        q.Get("ref"),
+		Pkg:        pkg,
+		From:       from,
+		To:         to,
+	})
+
+	if err != nil {
+		web.JsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var trends = append([]duration.Trend{}, duration.Analyze(recs, s.DurationWindow)...)
+	sort.SliceStable(trends, func(i, j int) bool {
+		if trends[i].Repository != trends[j].Repository {
+			return trends[i].Repository < trends[j].Repository
+		}
+
+		if trends[i].Pkg != trends[j].Pkg {
+			return trends[i].Pkg < trends[j].Pkg
+		}
+
+		return trends[i].Test < trends[j].Test
+	})
+
+	web.Json(w, trends)
+}
+
+// FileSummary is the coverage of a file, without its blocks
+type FileSummary struct {
+	Pkg        string  `json:"pkg"`
+	File       string  `json:"file"`
+	Statements int     `json:"statements"`
+	Covered    int     `json:"covered"`
+	Percentage float64 `json:"percentage"`
+}
+
+// Files of a package at a commit
+type Files struct {
+	Repository string        `json:"repository"`
+	Ref        string        `json:"ref"`
+	Commit     string        `json:"commit"`
+	Time       string        `json:"time"`
+	Files      []FileSummary `json:"files"`
+}
+
+// Func is the coverage of a function
+type Func struct {
+	goqa.FuncCoverage
+	Percentage float64 `json:"percentage"`
+}
+
+// FileDetail is the coverage of a file at a commit, split into covered and uncovered blocks
+type FileDetail struct {
+	Repository string `json:"repository"`
+	Ref        string `json:"ref"`
+	Commit     string `json:"commit"`
+	Time       string `json:"time"`
+	FileSummary
+	CoveredBlocks   []goqa.Block `json:"covered_blocks"`
+	UncoveredBlocks []goqa.Block `json:"uncovered_blocks"`
+	UncoveredLines  []int        `json:"uncovered_lines"`
+	Funcs           []Func       `json:"funcs"`
+
+	// Lines of the annotated source; only when the source was uploaded
+	Lines []goqa.Line `json:"lines,omitempty"`
+}
 
-	var cov, ok = s.Cache.Get(pkg)
+func summary(f goqa.FileCoverage) FileSummary {
+	return FileSummary{
+		Pkg:        f.Pkg,
+		File:       f.File,
+		Statements: f.Statements,
+		Covered:    f.Covered,
+		Percentage: f.Percentage(),
+	}
+}
+
+// FileCoverage endpoint for files of a package, or the detail of a single file; /{repo}/{pkg}/files?ref=&commit=
+// or /{repo}/{pkg}/files/{file}?ref=&commit= where file is named within the package e.g. foo.go; coverage is that
+// of the latest matching coverprofile; repository= is needed for repositories not named {owner}/{name}
+func (s *Server) FileCoverage(w http.ResponseWriter, r *http.Request) {
+	var (
+		q                         = r.URL.Query()
+		repository, pkg, file, ok = filesRoute(strings.TrimPrefix(r.URL.Path, s.Prefix), q.Get("repository"))
+	)
 
 	if !ok {
+		web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+		return
+	}
+
+	var recs, err = s.Repo.History(r.Context(), goqa.HistoryQuery{
+		Repository: repository,
+		Ref:        q.Get("ref"),
+		Commit:     q.Get("commit"),
+		Pkg:        pkg,
+		Files:      true,
+	})
+
+	if err != nil {
+		web.JsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var rec *goqa.Record
+	for i := len(recs) - 1; i >= 0 && rec == nil; i-- {
+		if len(recs[i].Files) != 0 {
+			rec = &recs[i]
+		}
+	}
+
+	if rec == nil {
 		web.JsonError(w, http.StatusNotFound, web.ErrResourceNotFound)
 		return
 	}
 
-	web.Json(w, cov)
+	if file == "" {
+		var files = Files{Repository: rec.Repository, Ref: rec.Ref, Commit: rec.Commit, Time: rec.Time, Files: []FileSummary{}}
+		for i := range rec.Files {
+			files.Files = append(files.Files, summary(rec.Files[i]))
+		}
+
+		web.Json(w, files)
+		return
+	}
+
+	for _, f := range rec.Files {
+		if f.File != file {
+			continue
+		}
+
+		var detail = FileDetail{
+			Repository:      rec.Repository,
+			Ref:             rec.Ref,
+			Commit:          rec.Commit,
+			Time:            rec.Time,
+			FileSummary:     summary(f),
+			CoveredBlocks:   []goqa.Block{},
+			UncoveredBlocks: []goqa.Block{},
+			UncoveredLines:  f.Uncovered(),
+			Funcs:           []Func{},
+			Lines:           f.Lines(),
+		}
+
+		for _, b := range f.Blocks {
+			if b.Count > 0 {
+				detail.CoveredBlocks = append(detail.CoveredBlocks, b)
+			} else {
+				detail.UncoveredBlocks = append(detail.UncoveredBlocks, b)
+			}
+		}
+
+		for _, fn := range f.Funcs {
+			detail.Funcs = append(detail.Funcs, Func{FuncCoverage: fn, Percentage: fn.Percentage()})
+		}
+
+		web.Json(w, detail)
+		return
+	}
+
+	web.JsonError(w, http.StatusNotFound, web.ErrResourceNotFound)
+}
+
+// filesRoute out of a path of the form {repository}/{pkg}/files or {repository}/{pkg}/files/{file}, the file
+// being named within the package; file is then its import path e.g. github.com/acme/foo/foo.go
+// the repository is {owner}/{name} unless given e.g. group/sub/proj of a GitLab subgroup, the path then starting with it
+func filesRoute(name, repository string) (string, string, string, bool) {
+	var rest, pkg, file string
+
+	if repository != "" {
+		if !strings.HasPrefix(name, repository+"/") {
+			return "", "", "", false
+		}
+
+		rest = name[len(repository)+1:]
+	} else {
+		var parts = strings.SplitN(name, "/", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return "", "", "", false
+		}
+
+		repository, rest = parts[0]+"/"+parts[1], parts[2]
+	}
+
+	if strings.HasSuffix(rest, "/files") {
+		pkg = strings.TrimSuffix(rest, "/files")
+	} else if i := strings.LastIndex(rest, "/files/"); i != -1 && !strings.Contains(rest[i+len("/files/"):], "/") {
+		pkg = rest[:i]
+		file = pkg + "/" + rest[i+len("/files/"):]
+	}
+
+	if pkg == "" || strings.HasSuffix(file, "/") {
+		return "", "", "", false
+	}
+
+	return repository, pkg, file, true
+}
+
+// latest coverage matching a key, by time of measurement
+func (s *Server) latest(key goqa.Key) (*goqa.Coverage, error) {
+	var covs, err = goqa.Coverages(s.Cache, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *goqa.Coverage
+	for i := range covs {
+		if latest == nil || covs[i].Time > latest.Time {
+			latest = &covs[i]
+		}
+	}
+
+	return latest, nil
+}
+
+// aggregate coverage of a repository at a ref
+func (s *Server) aggregate(repository, ref string) (*float64, error) {
+	var covs, err = goqa.Coverages(s.Cache, goqa.Key{Repository: repository, Ref: ref})
+	if err != nil || len(covs) == 0 {
+		return nil, err
+	}
+
+	var a = goqa.Aggregated(repository, ref, "", covs)
+	return &a.Precise, nil
+}
+
+// Aggregates endpoint for coverage of a repository weighted by statements; /aggregates?repository=&ref=&path=
+// lists the whole repository then every directory subtree, or only the subtree at path e.g. that of a module
+func (s *Server) Aggregates(w http.ResponseWriter, r *http.Request) {
+	var (
+		q          = r.URL.Query()
+		repository = q.Get("repository")
+		ref        = q.Get("ref")
+	)
+
+	if repository == "" || ref == "" {
+		web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+		return
+	}
+
+	var covs, err = goqa.Coverages(s.Cache, goqa.Key{Repository: repository, Ref: ref})
+	if err != nil {
+		web.JsonError(w, http.StatusInternalServerError, err)
+		return
+	} else if len(covs) == 0 {
+		web.JsonError(w, http.StatusNotFound, web.ErrResourceNotFound)
+		return
+	}
+
+	if _, ok := q["path"]; ok {
+		web.Json(w, goqa.Aggregated(repository, ref, q.Get("path"), covs))
+		return
+	}
+
+	web.Json(w, goqa.Subtrees(repository, ref, covs))
+}
+
+// Badge endpoint for coverage badges of a package or a whole repository; /badge/{owner}/{name}/{pkg}.svg?ref=
+// or /badge/{owner}/{name}.svg?ref= for the repository, .json instead of .svg for a shields.io endpoint badge;
+// the latest ref measured is used when none is given
+func (s *Server) Badge(w http.ResponseWriter, r *http.Request) {
+	var (
+		name  = strings.TrimPrefix(r.URL.Path, badgePrefix)
+		ext   = path.Ext(name)
+		parts = strings.SplitN(strings.TrimSuffix(name, ext), "/", 3)
+	)
+
+	if (ext != ".svg" && ext != ".json") || len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		web.JsonError(w, http.StatusBadRequest, web.ErrInvalidRequest)
+		return
+	}
+
+	var key = goqa.Key{Repository: parts[0] + "/" + parts[1], Ref: r.URL.Query().Get("ref")}
+	if len(parts) == 3 {
+		key.Pkg = parts[2]
+	}
+
+	var latest, err = s.latest(key)
+	if err != nil {
+		web.JsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var percentage *float64
+	if latest != nil && key.Pkg != "" {
+		var v = latest.Value()
+		percentage = &v
+	} else if latest != nil {
+		if percentage, err = s.aggregate(latest.Repository, latest.Ref); err != nil {
+			web.JsonError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	var (
+		b     = badge.New(s.BadgeBands, percentage)
+		ctype = web.ContentTypeSVG
+		body  []byte
+	)
+
+	if ext == ".json" {
+		ctype = web.ContentTypeJSON
+		body, err = json.Marshal(b)
+	} else {
+		body = b.SVG()
+	}
+
+	if err != nil {
+		web.JsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// badges are embedded in pages cached by third parties e.g. README on github; they must always revalidate
+	var etag = fmt.Sprintf(`"%x"`, sha1.Sum(body))
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	web.Print(w, http.StatusOK, ctype, body)
 }
 
 // Index endpoint for proper display of IndexHTML index page
@@ -51,7 +691,7 @@ func (s *Server) Index(w http.ResponseWriter, r *http.Request) {
 	web.Print(w, http.StatusOK, web.ContentTypeHTML, s.IndexHTML)
 }
 
-// SSE endpoint for events updates
+// SSE endpoint for events updates; ?repository=&ref=&pkg= filter coverage events and ?format=json sends json envelopes
 func (s *Server) SSE(w http.ResponseWriter, r *http.Request) {
 	var flusher, ok = w.(http.Flusher)
 	if !ok {
@@ -64,16 +704,31 @@ func (s *Server) SSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "keep-alive")
 
 	var (
-		ctx = r.Context()
-		sub = sse.New(w, flusher)
+		ctx    = r.Context()
+		q      = r.URL.Query()
+		filter = goqa.Key{Repository: q.Get("repository"), Ref: q.Get("ref"), Pkg: q.Get("pkg")}
+		sub    = sse.NewFiltered(w, flusher, filter)
 	)
 
+	if q.Get("format") == "json" {
+		sub = sse.NewJSON(w, flusher, filter)
+	}
+
+	var aggregates = sub.Clone()
+
 	var err = s.Roster.Subscribe(ctx, goqa.EventCoverage, sub)
+	if err == nil {
+		if err = s.Roster.Subscribe(ctx, goqa.EventAggregate, aggregates); err != nil {
+			s.Roster.Unsubscribe(context.Background(), sub.ID())
+		}
+	}
+
 	if err != nil {
 		fmt.Fprintf(w, "event: error")
 		fmt.Fprintf(w, "data: failed to subscribe to event")
 		return
 	}
 
+	go roster.WatchCtx(ctx, s.Roster, aggregates)
 	roster.WatchCtx(ctx, s.Roster, sub)
 }
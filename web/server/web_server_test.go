@@ -1,34 +1,42 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/fluxynet/goqa"
+	"github.com/fluxynet/goqa/badge"
+	"github.com/fluxynet/goqa/cache/memory"
 	"github.com/fluxynet/goqa/internal"
 	"github.com/fluxynet/goqa/web"
 )
 
 type fakecache struct {
-	pkg      string
-	coverage goqa.Coverage
-	keys     []string
+	coverage *goqa.Coverage
+	keys     []goqa.Key
 }
 
 func (f fakecache) Reset(covs ...goqa.Coverage) error {
 	panic("not implemented")
 }
 
-func (f fakecache) Get(pkg string) (*goqa.Coverage, bool) {
-	if f.pkg == pkg {
-		return &f.coverage, true
+func (f fakecache) Replace(repository, ref string, covs ...goqa.Coverage) error {
+	panic("not implemented")
+}
+
+func (f fakecache) Get(key goqa.Key) (*goqa.Coverage, bool) {
+	if f.coverage != nil && f.coverage.Key() == key {
+		return f.coverage, true
 	}
 
 	return nil, false
 }
 
-func (f fakecache) Keys() ([]string, error) {
+func (f fakecache) Keys() ([]goqa.Key, error) {
 	return f.keys, nil
 }
 
@@ -52,6 +60,13 @@ func TestServer_Get(t *testing.T) {
 		body    string
 	}
 
+	var latest = memory.New()
+	_ = latest.Reset(
+		goqa.Coverage{Repository: "acme/foo", Ref: "refs/heads/master", Pkg: "github.com/acme/foo", Percentage: 10, Precise: 10, Time: "2000-01-01T00:00:00Z"},
+		goqa.Coverage{Repository: "acme/foo", Ref: "refs/heads/develop", Pkg: "github.com/acme/foo", Percentage: 20, Precise: 20, Time: "2000-01-02T00:00:00Z"},
+		goqa.Coverage{Repository: "acme/foo", Ref: "refs/heads/next", Pkg: "github.com/acme/foo/bar", Percentage: 30, Precise: 30, Time: "2000-01-03T00:00:00Z"},
+	)
+
 	tests := []struct {
 		name   string
 		fields fields
@@ -78,23 +93,78 @@ func TestServer_Get(t *testing.T) {
 			name: "found",
 			fields: fields{
 				Cache: fakecache{
-					pkg: "foo",
-					coverage: goqa.Coverage{
-						Pkg:        "foo",
+					coverage: &goqa.Coverage{
+						Repository: "acme/foo",
+						Ref:        "refs/heads/master",
+						Pkg:        "github.com/acme/foo",
 						Percentage: 10,
 						Time:       "2000-01-01T00:00:00.673068822Z",
 					},
 				},
 			},
 			args: args{
-				path: "/api/foo",
+				path: "/api/github.com/acme/foo?repository=acme/foo&ref=refs/heads/master",
 			},
 			want: want{
 				status: http.StatusOK,
 				headers: http.Header{
 					"Content-Type": []string{web.ContentTypeJSON},
 				},
-				body: `{"pkg":"foo","percentage":10,"time":"2000-01-01T00:00:00.673068822Z"}`,
+				body: `{"repository":"acme/foo","ref":"refs/heads/master","pkg":"github.com/acme/foo","percentage":10,"precise":10,"time":"2000-01-01T00:00:00.673068822Z"}`,
+			},
+		},
+		{
+			name: "other ref",
+			fields: fields{
+				Cache: fakecache{
+					coverage: &goqa.Coverage{
+						Repository: "acme/foo",
+						Ref:        "refs/heads/master",
+						Pkg:        "github.com/acme/foo",
+						Percentage: 10,
+						Time:       "2000-01-01T00:00:00.673068822Z",
+					},
+				},
+			},
+			args: args{
+				path: "/api/github.com/acme/foo?repository=acme/foo&ref=refs/heads/develop",
+			},
+			want: want{
+				status: http.StatusNotFound,
+				headers: http.Header{
+					"Content-Type": []string{web.ContentTypeJSON},
+				},
+				body: `{"error":"resource not found"}`,
+			},
+		},
+		{
+			name:   "latest without repository and ref",
+			fields: fields{Cache: latest},
+			args:   args{path: "/api/github.com/acme/foo"},
+			want: want{
+				status:  http.StatusOK,
+				headers: http.Header{"Content-Type": []string{web.ContentTypeJSON}},
+				body:    `{"repository":"acme/foo","ref":"refs/heads/develop","pkg":"github.com/acme/foo","percentage":20,"precise":20,"time":"2000-01-02T00:00:00Z"}`,
+			},
+		},
+		{
+			name:   "latest of a repository without ref",
+			fields: fields{Cache: latest},
+			args:   args{path: "/api/github.com/acme/foo?repository=acme/foo"},
+			want: want{
+				status:  http.StatusOK,
+				headers: http.Header{"Content-Type": []string{web.ContentTypeJSON}},
+				body:    `{"repository":"acme/foo","ref":"refs/heads/develop","pkg":"github.com/acme/foo","percentage":20,"precise":20,"time":"2000-01-02T00:00:00Z"}`,
+			},
+		},
+		{
+			name:   "latest of another repository",
+			fields: fields{Cache: latest},
+			args:   args{path: "/api/github.com/acme/foo?repository=acme/bar"},
+			want: want{
+				status:  http.StatusNotFound,
+				headers: http.Header{"Content-Type": []string{web.ContentTypeJSON}},
+				body:    `{"error":"resource not found"}`,
 			},
 		},
 	}
@@ -135,6 +205,12 @@ func TestServer_Index(t *testing.T) {
 				IndexHTML: []byte(`<html><head></head><body></body></html>`),
 			},
 		},
+		{
+			name: "embedded",
+			fields: fields{
+				IndexHTML: goqa.AssetIndexHtml,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -158,6 +234,12 @@ func TestServer_List(t *testing.T) {
 		Cache goqa.Cache
 	}
 
+	var keys = []goqa.Key{
+		{Repository: "acme/foo", Ref: "master", Pkg: "foo"},
+		{Repository: "acme/bar", Ref: "master", Pkg: "bar"},
+		{Repository: "acme/foo", Ref: "develop", Pkg: "foo"},
+	}
+
 	type want struct {
 		status  int
 		headers http.Header
@@ -167,6 +249,7 @@ func TestServer_List(t *testing.T) {
 	tests := []struct {
 		name   string
 		fields fields
+		path   string
 		want   want
 	}{
 		{
@@ -186,15 +269,33 @@ func TestServer_List(t *testing.T) {
 			name: "non-empty",
 			fields: fields{
 				Cache: fakecache{
-					keys: []string{"foo", "bar", "baz"},
+					keys: keys,
+				},
+			},
+			want: want{
+				status: http.StatusOK,
+				headers: http.Header{
+					"Content-Type": []string{web.ContentTypeJSON},
+				},
+				body: `[{"repository":"acme/bar","ref":"master","pkg":"bar"},` +
+					`{"repository":"acme/foo","ref":"develop","pkg":"foo"},` +
+					`{"repository":"acme/foo","ref":"master","pkg":"foo"}]`,
+			},
+		},
+		{
+			name: "filtered",
+			fields: fields{
+				Cache: fakecache{
+					keys: keys,
 				},
 			},
+			path: "/?repository=acme/foo&ref=master",
 			want: want{
 				status: http.StatusOK,
 				headers: http.Header{
 					"Content-Type": []string{web.ContentTypeJSON},
 				},
-				body: `["foo","bar","baz"]`,
+				body: `[{"repository":"acme/foo","ref":"master","pkg":"foo"}]`,
 			},
 		},
 	}
@@ -205,8 +306,13 @@ func TestServer_List(t *testing.T) {
 				Cache: tt.fields.Cache,
 			}
 
+			var path = tt.path
+			if path == "" {
+				path = "/"
+			}
+
 			w := httptest.NewRecorder()
-			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r := httptest.NewRequest(http.MethodGet, path, nil)
 
 			s.List(w, r)
 
@@ -214,3 +320,893 @@ func TestServer_List(t *testing.T) {
 		})
 	}
 }
+
+type fakerepo struct {
+	recs []goqa.Record
+	err  error
+}
+
+func (f fakerepo) Save(ctx context.Context, repository, ref string, covs ...goqa.Coverage) error {
+	panic("not implemented")
+}
+
+func (f fakerepo) Load(ctx context.Context) ([]goqa.Coverage, error) {
+	panic("not implemented")
+}
+
+func (f fakerepo) Append(ctx context.Context, rec goqa.Record) error {
+	panic("not implemented")
+}
+
+func (f fakerepo) History(ctx context.Context, q goqa.HistoryQuery) ([]goqa.Record, error) {
+	return q.Filter(f.recs), f.err
+}
+
+func (f fakerepo) Close() error {
+	return nil
+}
+
+var historyRecs = []goqa.Record{
+	{
+		Repository: "acme/foo",
+		Ref:        "master",
+		Commit:     "c2",
+		Workflow:   "Go",
+		Time:       "2021-02-01T00:00:00Z",
+		Coverage: []goqa.Coverage{
+			{Pkg: "github.com/acme/foo", Percentage: 20},
+			{Pkg: "github.com/acme/foo/bar", Percentage: 30},
+		},
+	},
+	{
+		Repository: "acme/foo",
+		Ref:        "master",
+		Commit:     "c1",
+		Workflow:   "Go",
+		Time:       "2021-01-01T00:00:00Z",
+		Coverage: []goqa.Coverage{
+			{Pkg: "github.com/acme/foo", Percentage: 10},
+		},
+	},
+	{
+		Repository: "acme/foo",
+		Ref:        "develop",
+		Commit:     "c3",
+		Workflow:   "Go",
+		Time:       "2021-03-01T00:00:00Z",
+		Coverage: []goqa.Coverage{
+			{Pkg: "github.com/acme/foo", Percentage: 40},
+		},
+	},
+}
+
+func TestServer_History(t *testing.T) {
+	tests := []struct {
+		name   string
+		repo   goqa.Repo
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "no package",
+			repo:   fakerepo{},
+			path:   "/api/history/",
+			status: http.StatusBadRequest,
+			body:   `{"error":"request is invalid"}`,
+		},
+		{
+			name:   "bad time",
+			repo:   fakerepo{},
+			path:   "/api/history/github.com/acme/foo?from=yesterday",
+			status: http.StatusBadRequest,
+			body:   `{"error":"request is invalid"}`,
+		},
+		{
+			name:   "repo error",
+			repo:   fakerepo{err: errors.New("oops")},
+			path:   "/api/history/github.com/acme/foo",
+			status: http.StatusInternalServerError,
+			body:   `{"error":"oops"}`,
+		},
+		{
+			name:   "empty",
+			repo:   fakerepo{},
+			path:   "/api/history/github.com/acme/foo",
+			status: http.StatusOK,
+			body:   `[]`,
+		},
+		{
+			name:   "by ref",
+			repo:   fakerepo{recs: historyRecs},
+			path:   "/api/history/github.com/acme/foo?ref=master",
+			status: http.StatusOK,
+			body: `[{"repository":"acme/foo","ref":"master","commit":"c1","workflow":"Go","pkg":"github.com/acme/foo","percentage":10,"precise":10,"time":"2021-01-01T00:00:00Z"},` +
+				`{"repository":"acme/foo","ref":"master","commit":"c2","workflow":"Go","pkg":"github.com/acme/foo","percentage":20,"precise":20,"time":"2021-02-01T00:00:00Z"}]`,
+		},
+		{
+			name:   "time range",
+			repo:   fakerepo{recs: historyRecs},
+			path:   "/api/history/github.com/acme/foo?from=2021-01-15&to=2021-03-01T00:00:00Z",
+			status: http.StatusOK,
+			body:   `[{"repository":"acme/foo","ref":"master","commit":"c2","workflow":"Go","pkg":"github.com/acme/foo","percentage":20,"precise":20,"time":"2021-02-01T00:00:00Z"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Repo:   tt.repo,
+				Prefix: "/api/",
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			s.History(w, r)
+
+			internal.AssertHttp(t, w, tt.status, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.body)
+		})
+	}
+}
+
+func TestServer_Commit(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "no sha",
+			path:   "/api/commits/",
+			status: http.StatusBadRequest,
+			body:   `{"error":"request is invalid"}`,
+		},
+		{
+			name:   "not found",
+			path:   "/api/commits/c9",
+			status: http.StatusNotFound,
+			body:   `{"error":"resource not found"}`,
+		},
+		{
+			name:   "found",
+			path:   "/api/commits/c2",
+			status: http.StatusOK,
+			body: `[{"repository":"acme/foo","ref":"master","commit":"c2","workflow":"Go","pkg":"github.com/acme/foo","percentage":20,"precise":20,"time":"2021-02-01T00:00:00Z"},` +
+				`{"repository":"acme/foo","ref":"master","commit":"c2","workflow":"Go","pkg":"github.com/acme/foo/bar","percentage":30,"precise":30,"time":"2021-02-01T00:00:00Z"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Repo:   fakerepo{recs: historyRecs},
+				Prefix: "/api/",
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			s.Commit(w, r)
+
+			internal.AssertHttp(t, w, tt.status, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.body)
+		})
+	}
+}
+
+func TestServer_Diff(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "no head",
+			path:   "/api/diff?repository=acme/foo&base=c1",
+			status: http.StatusBadRequest,
+			body:   `{"error":"request is invalid"}`,
+		},
+		{
+			name:   "base not found",
+			path:   "/api/diff?repository=acme/foo&base=c9&head=c2",
+			status: http.StatusNotFound,
+			body:   `{"error":"resource not found"}`,
+		},
+		{
+			name:   "found",
+			path:   "/api/diff?repository=acme/foo&base=c1&head=c2",
+			status: http.StatusOK,
+			body: `{"repository":"acme/foo","ref":"master","head":"","base":"c1","commit":"c2","time":"2021-02-01T00:00:00Z","packages":[` +
+				`{"pkg":"github.com/acme/foo","base":10,"head":20,"delta":10,"added":false,"removed":false,"changed":false},` +
+				`{"pkg":"github.com/acme/foo/bar","base":0,"head":30,"delta":30,"added":true,"removed":false,"changed":false}],` +
+				`"untested":[],"patch":null}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Repo:   fakerepo{recs: historyRecs},
+				Prefix: "/api/",
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			s.Diff(w, r)
+
+			internal.AssertHttp(t, w, tt.status, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.body)
+		})
+	}
+}
+
+func TestServer_Tests(t *testing.T) {
+	var recs = []goqa.Record{
+		{
+			Repository: "acme/foo",
+			Ref:        "master",
+			Commit:     "c1",
+			Time:       "2021-01-01T00:00:00Z",
+			Tests: []goqa.TestResult{
+				{Pkg: "foo", Test: "TestA", Status: goqa.TestFail, Elapsed: 0.5, Time: "t0"},
+				{Pkg: "bar", Test: "TestB", Status: goqa.TestPass, Time: "t1"},
+			},
+		},
+		{
+			Repository: "acme/foo",
+			Ref:        "master",
+			Commit:     "c2",
+			Time:       "2021-02-01T00:00:00Z",
+			Tests: []goqa.TestResult{
+				{Pkg: "foo", Test: "TestA", Status: goqa.TestPass, Elapsed: 0.25, Time: "t2"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "no package",
+			path:   "/api/tests/",
+			status: http.StatusBadRequest,
+			body:   `{"error":"request is invalid"}`,
+		},
+		{
+			name:   "not found",
+			path:   "/api/tests/baz",
+			status: http.StatusNotFound,
+			body:   `{"error":"resource not found"}`,
+		},
+		{
+			name:   "latest",
+			path:   "/api/tests/foo?repository=acme/foo&ref=master",
+			status: http.StatusOK,
+			body:   `[{"pkg":"foo","test":"TestA","status":"pass","elapsed":0.25,"time":"t2"}]`,
+		},
+		{
+			name:   "by commit",
+			path:   "/api/tests/foo?commit=c1",
+			status: http.StatusOK,
+			body:   `[{"pkg":"foo","test":"TestA","status":"fail","elapsed":0.5,"time":"t0"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Repo:   fakerepo{recs: recs},
+				Prefix: "/api/",
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			s.Tests(w, r)
+
+			internal.AssertHttp(t, w, tt.status, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.body)
+		})
+	}
+}
+
+func TestServer_Flaky(t *testing.T) {
+	var recs = []goqa.Record{
+		{
+			Repository: "acme/foo",
+			Ref:        "master",
+			Commit:     "c1",
+			Time:       "2021-01-01T00:00:00Z",
+			Tests: []goqa.TestResult{
+				{Pkg: "foo", Test: "TestA", Status: goqa.TestPass},
+				{Pkg: "foo", Test: "TestB", Status: goqa.TestPass},
+			},
+		},
+		{
+			Repository: "acme/foo",
+			Ref:        "master",
+			Commit:     "c2",
+			Time:       "2021-01-02T00:00:00Z",
+			Tests: []goqa.TestResult{
+				{Pkg: "foo", Test: "TestA", Status: goqa.TestFail},
+				{Pkg: "foo", Test: "TestB", Status: goqa.TestPass},
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "bad min",
+			path:   "/api/flaky?min=foo",
+			status: http.StatusBadRequest,
+			body:   `{"error":"request is invalid"}`,
+		},
+		{
+			name:   "flaky only",
+			path:   "/api/flaky",
+			status: http.StatusOK,
+			body:   `[{"repository":"acme/foo","ref":"master","pkg":"foo","test":"TestA","runs":2,"flips":1,"retried":0,"status":"fail","commit":"c2","score":0.5}]`,
+		},
+		{
+			name:   "min",
+			path:   "/api/flaky?min=0",
+			status: http.StatusOK,
+			body: `[{"repository":"acme/foo","ref":"master","pkg":"foo","test":"TestA","runs":2,"flips":1,"retried":0,"status":"fail","commit":"c2","score":0.5},` +
+				`{"repository":"acme/foo","ref":"master","pkg":"foo","test":"TestB","runs":2,"flips":0,"retried":0,"status":"pass","commit":"c2","score":0}]`,
+		},
+		{
+			name:   "other ref",
+			path:   "/api/flaky?ref=develop",
+			status: http.StatusOK,
+			body:   `[]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Repo:   fakerepo{recs: recs},
+				Prefix: "/api/",
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			s.Flaky(w, r)
+
+			internal.AssertHttp(t, w, tt.status, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.body)
+		})
+	}
+}
+
+func TestServer_Durations(t *testing.T) {
+	var recs = []goqa.Record{
+		{
+			Repository: "acme/foo",
+			Ref:        "master",
+			Commit:     "c1",
+			Time:       "2021-01-01T00:00:00Z",
+			Tests:      []goqa.TestResult{{Pkg: "foo", Test: "TestA", Status: goqa.TestPass, Elapsed: 1}},
+			Packages:   []goqa.PackageResult{{Pkg: "foo", Status: goqa.TestPass, Elapsed: 2}},
+		},
+		{
+			Repository: "acme/foo",
+			Ref:        "master",
+			Commit:     "c2",
+			Time:       "2021-01-02T00:00:00Z",
+			Tests:      []goqa.TestResult{{Pkg: "foo", Test: "TestA", Status: goqa.TestPass, Elapsed: 3}},
+			Packages:   []goqa.PackageResult{{Pkg: "foo", Status: goqa.TestPass, Elapsed: 4}},
+		},
+		{
+			Repository: "acme/bar",
+			Ref:        "master",
+			Commit:     "c3",
+			Time:       "2021-01-03T00:00:00Z",
+			Tests:      []goqa.TestResult{{Pkg: "foo", Test: "TestB", Status: goqa.TestPass, Elapsed: 6}},
+			Packages:   []goqa.PackageResult{{Pkg: "foo", Status: goqa.TestPass, Elapsed: 5}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "no package",
+			path:   "/api/durations/",
+			status: http.StatusBadRequest,
+			body:   `{"error":"request is invalid"}`,
+		},
+		{
+			name:   "bad from",
+			path:   "/api/durations/foo?from=yesterday",
+			status: http.StatusBadRequest,
+			body:   `{"error":"request is invalid"}`,
+		},
+		{
+			name:   "durations",
+			path:   "/api/durations/foo?repository=acme/foo",
+			status: http.StatusOK,
+			body: `[{"repository":"acme/foo","ref":"master","pkg":"foo","test":"","runs":2,"p50":3,"p95":3.9,"latest":4,"points":[{"commit":"c1","time":"2021-01-01T00:00:00Z","elapsed":2},{"commit":"c2","time":"2021-01-02T00:00:00Z","elapsed":4}]},` +
+				`{"repository":"acme/foo","ref":"master","pkg":"foo","test":"TestA","runs":2,"p50":2,"p95":2.9,"latest":3,"points":[{"commit":"c1","time":"2021-01-01T00:00:00Z","elapsed":1},{"commit":"c2","time":"2021-01-02T00:00:00Z","elapsed":3}]}]`,
+		},
+		{
+			name:   "window of every repository",
+			path:   "/api/durations/foo?from=2021-01-02",
+			status: http.StatusOK,
+			body: `[{"repository":"acme/bar","ref":"master","pkg":"foo","test":"","runs":1,"p50":5,"p95":5,"latest":5,"points":[{"commit":"c3","time":"2021-01-03T00:00:00Z","elapsed":5}]},` +
+				`{"repository":"acme/bar","ref":"master","pkg":"foo","test":"TestB","runs":1,"p50":6,"p95":6,"latest":6,"points":[{"commit":"c3","time":"2021-01-03T00:00:00Z","elapsed":6}]},` +
+				`{"repository":"acme/foo","ref":"master","pkg":"foo","test":"","runs":1,"p50":4,"p95":4,"latest":4,"points":[{"commit":"c2","time":"2021-01-02T00:00:00Z","elapsed":4}]},` +
+				`{"repository":"acme/foo","ref":"master","pkg":"foo","test":"TestA","runs":1,"p50":3,"p95":3,"latest":3,"points":[{"commit":"c2","time":"2021-01-02T00:00:00Z","elapsed":3}]}]`,
+		},
+		{
+			name:   "unknown package",
+			path:   "/api/durations/bar",
+			status: http.StatusOK,
+			body:   `[]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Repo:   fakerepo{recs: recs},
+				Prefix: "/api/",
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			s.Durations(w, r)
+
+			internal.AssertHttp(t, w, tt.status, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.body)
+		})
+	}
+}
+
+func TestServer_FileCoverage(t *testing.T) {
+	var file = goqa.FileCoverage{
+		Pkg:        "github.com/acme/foo",
+		File:       "github.com/acme/foo/foo.go",
+		Statements: 3,
+		Covered:    1,
+		Blocks: []goqa.Block{
+			{StartLine: 3, StartCol: 24, EndLine: 5, EndCol: 2, Statements: 1, Count: 1},
+			{StartLine: 7, StartCol: 24, EndLine: 9, EndCol: 2, Statements: 2},
+		},
+		Funcs: []goqa.FuncCoverage{
+			{Name: "Sum", StartLine: 3, EndLine: 5, Statements: 1, Covered: 1},
+			{Name: "Sub", StartLine: 7, EndLine: 9, Statements: 2},
+		},
+	}
+
+	var sourced = file
+	sourced.Source = "package foo\n\nfunc Sum(a, b int) int {\n\treturn a + b\n}\n"
+
+	var recs = []goqa.Record{
+		{
+			Repository: "acme/foo",
+			Ref:        "master",
+			Commit:     "c1",
+			Time:       "2021-01-01T00:00:00Z",
+			Files:      []goqa.FileCoverage{{Pkg: "github.com/acme/foo", File: "github.com/acme/foo/foo.go", Statements: 3}},
+		},
+		{
+			Repository: "acme/foo",
+			Ref:        "master",
+			Commit:     "c2",
+			Time:       "2021-01-02T00:00:00Z",
+			Files:      []goqa.FileCoverage{file, {Pkg: "github.com/acme/foo/bar", File: "github.com/acme/foo/bar/bar.go"}},
+		},
+		{
+			Repository: "acme/foo",
+			Ref:        "master",
+			Commit:     "c3",
+			Time:       "2021-01-03T00:00:00Z",
+			Coverage:   []goqa.Coverage{{Pkg: "github.com/acme/foo", Percentage: 50}}, // summary only
+		},
+		{
+			Repository: "acme/foo",
+			Ref:        "develop",
+			Commit:     "c4",
+			Time:       "2021-01-04T00:00:00Z",
+			Files:      []goqa.FileCoverage{sourced},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "no package",
+			path:   "/api/acme/foo/files",
+			status: http.StatusNotFound,
+			body:   `{"error":"resource not found"}`,
+		},
+		{
+			name:   "files",
+			path:   "/api/acme/foo/github.com/acme/foo/files?ref=master",
+			status: http.StatusOK,
+			body: `{"repository":"acme/foo","ref":"master","commit":"c2","time":"2021-01-02T00:00:00Z","files":[` +
+				`{"pkg":"github.com/acme/foo","file":"github.com/acme/foo/foo.go","statements":3,"covered":1,"percentage":33.3}]}`,
+		},
+		{
+			name:   "files at commit",
+			path:   "/api/acme/foo/github.com/acme/foo/files?commit=c1",
+			status: http.StatusOK,
+			body: `{"repository":"acme/foo","ref":"master","commit":"c1","time":"2021-01-01T00:00:00Z","files":[` +
+				`{"pkg":"github.com/acme/foo","file":"github.com/acme/foo/foo.go","statements":3,"covered":0,"percentage":0}]}`,
+		},
+		{
+			name:   "unknown package",
+			path:   "/api/acme/foo/github.com/acme/baz/files",
+			status: http.StatusNotFound,
+			body:   `{"error":"resource not found"}`,
+		},
+		{
+			name:   "file",
+			path:   "/api/acme/foo/github.com/acme/foo/files/foo.go?ref=master",
+			status: http.StatusOK,
+			body: `{"repository":"acme/foo","ref":"master","commit":"c2","time":"2021-01-02T00:00:00Z",` +
+				`"pkg":"github.com/acme/foo","file":"github.com/acme/foo/foo.go","statements":3,"covered":1,"percentage":33.3,` +
+				`"covered_blocks":[{"start_line":3,"start_col":24,"end_line":5,"end_col":2,"statements":1,"count":1}],` +
+				`"uncovered_blocks":[{"start_line":7,"start_col":24,"end_line":9,"end_col":2,"statements":2,"count":0}],` +
+				`"uncovered_lines":[7,8,9],` +
+				`"funcs":[{"name":"Sum","start_line":3,"end_line":5,"statements":1,"covered":1,"percentage":100},` +
+				`{"name":"Sub","start_line":7,"end_line":9,"statements":2,"covered":0,"percentage":0}]}`,
+		},
+		{
+			name:   "file with source",
+			path:   "/api/acme/foo/github.com/acme/foo/files/foo.go?ref=develop",
+			status: http.StatusOK,
+			body: `{"repository":"acme/foo","ref":"develop","commit":"c4","time":"2021-01-04T00:00:00Z",` +
+				`"pkg":"github.com/acme/foo","file":"github.com/acme/foo/foo.go","statements":3,"covered":1,"percentage":33.3,` +
+				`"covered_blocks":[{"start_line":3,"start_col":24,"end_line":5,"end_col":2,"statements":1,"count":1}],` +
+				`"uncovered_blocks":[{"start_line":7,"start_col":24,"end_line":9,"end_col":2,"statements":2,"count":0}],` +
+				`"uncovered_lines":[7,8,9],` +
+				`"funcs":[{"name":"Sum","start_line":3,"end_line":5,"statements":1,"covered":1,"percentage":100},` +
+				`{"name":"Sub","start_line":7,"end_line":9,"statements":2,"covered":0,"percentage":0}],` +
+				`"lines":[{"number":1,"text":"package foo","status":"none","count":0},` +
+				`{"number":2,"text":"","status":"none","count":0},` +
+				`{"number":3,"text":"func Sum(a, b int) int {","status":"covered","count":1},` +
+				`{"number":4,"text":"\treturn a + b","status":"covered","count":1},` +
+				`{"number":5,"text":"}","status":"covered","count":1}]}`,
+		},
+		{
+			name:   "other repository",
+			path:   "/api/acme/bar/github.com/acme/foo/files?ref=master",
+			status: http.StatusNotFound,
+			body:   `{"error":"resource not found"}`,
+		},
+		{
+			name:   "file in a directory",
+			path:   "/api/acme/foo/github.com/acme/foo/files/bar/bar.go",
+			status: http.StatusNotFound,
+			body:   `{"error":"resource not found"}`,
+		},
+		{
+			name:   "unknown file",
+			path:   "/api/acme/foo/github.com/acme/foo/files/baz.go",
+			status: http.StatusNotFound,
+			body:   `{"error":"resource not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Cache:  memory.New(),
+				Repo:   fakerepo{recs: recs},
+				Prefix: "/api/",
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			s.Get(w, r) // as routed
+
+			internal.AssertHttp(t, w, tt.status, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.body)
+		})
+	}
+}
+
+func TestServer_Badge(t *testing.T) {
+	var cache = memory.New()
+	cache.Reset(
+		goqa.Coverage{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo", Percentage: 90, Time: "2021-01-02T00:00:00Z"},
+		goqa.Coverage{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo/bar", Percentage: 40, Time: "2021-01-02T00:00:00Z"},
+		goqa.Coverage{Repository: "acme/foo", Ref: "develop", Pkg: "github.com/acme/foo", Percentage: 20, Time: "2021-01-01T00:00:00Z"},
+	)
+
+	var bands = []badge.Band{{Min: 0, Color: "red"}, {Min: 60, Color: "green"}}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		ctype  string
+		body   string
+	}{
+		{
+			name:   "bad extension",
+			path:   "/badge/acme/foo.png",
+			status: http.StatusBadRequest,
+			ctype:  web.ContentTypeJSON,
+			body:   `{"error":"request is invalid"}`,
+		},
+		{
+			name:   "no repository",
+			path:   "/badge/acme.svg",
+			status: http.StatusBadRequest,
+			ctype:  web.ContentTypeJSON,
+			body:   `{"error":"request is invalid"}`,
+		},
+		{
+			name:   "package, latest ref",
+			path:   "/badge/acme/foo/github.com/acme/foo.json",
+			status: http.StatusOK,
+			ctype:  web.ContentTypeJSON,
+			body:   `{"schemaVersion":1,"label":"coverage","message":"90%","color":"green"}`,
+		},
+		{
+			name:   "package, given ref",
+			path:   "/badge/acme/foo/github.com/acme/foo.json?ref=develop",
+			status: http.StatusOK,
+			ctype:  web.ContentTypeJSON,
+			body:   `{"schemaVersion":1,"label":"coverage","message":"20%","color":"red"}`,
+		},
+		{
+			name:   "repository",
+			path:   "/badge/acme/foo.json",
+			status: http.StatusOK,
+			ctype:  web.ContentTypeJSON,
+			body:   `{"schemaVersion":1,"label":"coverage","message":"65%","color":"green"}`,
+		},
+		{
+			name:   "unknown package",
+			path:   "/badge/acme/foo/github.com/acme/baz.json",
+			status: http.StatusOK,
+			ctype:  web.ContentTypeJSON,
+			body:   `{"schemaVersion":1,"label":"coverage","message":"unknown","color":"lightgrey"}`,
+		},
+		{
+			name:   "svg",
+			path:   "/badge/acme/foo/github.com/acme/foo.svg",
+			status: http.StatusOK,
+			ctype:  web.ContentTypeSVG,
+			body:   string(badge.SVG("coverage", "90%", "green")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Cache:      cache,
+				Prefix:     "/api/",
+				BadgeBands: bands,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			s.Badge(w, r)
+
+			var header = http.Header{"Content-Type": []string{tt.ctype}}
+			if tt.status == http.StatusOK {
+				header.Set("Cache-Control", "no-cache, max-age=0")
+				header.Set("ETag", w.Header().Get("ETag"))
+			}
+
+			internal.AssertHttp(t, w, tt.status, header, tt.body)
+		})
+	}
+
+	t.Run("not modified", func(t *testing.T) {
+		s := &Server{Cache: cache}
+
+		w := httptest.NewRecorder()
+		s.Badge(w, httptest.NewRequest(http.MethodGet, "/badge/acme/foo.svg", nil))
+
+		var etag = w.Header().Get("ETag")
+		if !strings.HasPrefix(etag, `"`) {
+			t.Errorf("ETag = %s", etag)
+			return
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/badge/acme/foo.svg", nil)
+		r.Header.Set("If-None-Match", etag)
+
+		w = httptest.NewRecorder()
+		s.Badge(w, r)
+
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("status want = %d, got = %d; body = %s", http.StatusNotModified, w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestServer_Aggregates(t *testing.T) {
+	var cache = memory.New()
+	cache.Reset(
+		goqa.Coverage{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo", Percentage: 50, Statements: 10, Covered: 5},
+		goqa.Coverage{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo/bar", Percentage: 100, Statements: 30, Covered: 30},
+		goqa.Coverage{Repository: "acme/foo", Ref: "develop", Pkg: "github.com/acme/foo", Percentage: 20},
+	)
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "no ref",
+			path:   "/api/aggregates?repository=acme/foo",
+			status: http.StatusBadRequest,
+			body:   `{"error":"request is invalid"}`,
+		},
+		{
+			name:   "unknown repository",
+			path:   "/api/aggregates?repository=acme/bar&ref=master",
+			status: http.StatusNotFound,
+			body:   `{"error":"resource not found"}`,
+		},
+		{
+			name:   "subtrees",
+			path:   "/api/aggregates?repository=acme/foo&ref=master",
+			status: http.StatusOK,
+			body: `[{"repository":"acme/foo","ref":"master","path":"","packages":2,"weighted":2,"statements":40,"covered":35,"percentage":87,"precise":87.5},` +
+				`{"repository":"acme/foo","ref":"master","path":"github.com/acme/foo","packages":2,"weighted":2,"statements":40,"covered":35,"percentage":87,"precise":87.5},` +
+				`{"repository":"acme/foo","ref":"master","path":"github.com/acme/foo/bar","packages":1,"weighted":1,"statements":30,"covered":30,"percentage":100,"precise":100}]`,
+		},
+		{
+			name:   "path",
+			path:   "/api/aggregates?repository=acme/foo&ref=master&path=github.com/acme/foo/bar",
+			status: http.StatusOK,
+			body:   `{"repository":"acme/foo","ref":"master","path":"github.com/acme/foo/bar","packages":1,"weighted":1,"statements":30,"covered":30,"percentage":100,"precise":100}`,
+		},
+		{
+			name:   "whole repository",
+			path:   "/api/aggregates?repository=acme/foo&ref=develop&path=",
+			status: http.StatusOK,
+			body:   `{"repository":"acme/foo","ref":"develop","path":"","packages":1,"weighted":0,"statements":0,"covered":0,"percentage":20,"precise":20}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Cache:  cache,
+				Prefix: "/api/",
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			s.Aggregates(w, r)
+
+			internal.AssertHttp(t, w, tt.status, http.Header{"Content-Type": []string{web.ContentTypeJSON}}, tt.body)
+		})
+	}
+}
+
+func TestServer_AggregatesDashboard(t *testing.T) {
+	var cache = memory.New()
+	cache.Reset(goqa.Coverage{Repository: "acme/foo", Ref: "master", Pkg: "github.com/acme/foo", Percentage: 50, Precise: 50, Statements: 10, Covered: 5})
+
+	// the request of the dashboard for the aggregate of the whole repository
+	const call = "get(`/api/aggregates?${query({repository: state.repository, ref: state.ref})}&path=`)"
+	if !strings.Contains(string(goqa.AssetIndexHtml), call) {
+		t.Fatalf("dashboard does not request aggregates with %s", call)
+	}
+
+	var (
+		s = &Server{Cache: cache, Prefix: "/api/"}
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodGet, "/api/aggregates?repository=acme%2Ffoo&ref=master&path=", nil)
+	)
+
+	s.Aggregates(w, r)
+
+	internal.AssertHttp(t, w, http.StatusOK, http.Header{"Content-Type": []string{web.ContentTypeJSON}},
+		`{"repository":"acme/foo","ref":"master","path":"","packages":1,"weighted":1,"statements":10,"covered":5,"percentage":50,"precise":50}`)
+}
+
+func TestFilesRoute(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		repository     string
+		wantRepository string
+		wantPkg        string
+		wantFile       string
+		wantOk         bool
+	}{
+		{name: "files", path: "acme/foo/github.com/acme/foo/files", wantRepository: "acme/foo", wantPkg: "github.com/acme/foo", wantOk: true},
+		{name: "file", path: "acme/foo/github.com/acme/foo/files/foo.go", wantRepository: "acme/foo", wantPkg: "github.com/acme/foo", wantFile: "github.com/acme/foo/foo.go", wantOk: true},
+		{name: "package named files", path: "acme/foo/github.com/acme/foo/files/files/foo.go", wantRepository: "acme/foo", wantPkg: "github.com/acme/foo/files", wantFile: "github.com/acme/foo/files/foo.go", wantOk: true},
+		{name: "package", path: "github.com/acme/foo"},
+		{name: "no package", path: "acme/foo/files"},
+		{name: "no file", path: "acme/foo/github.com/acme/foo/files/"},
+		{name: "file in a directory", path: "acme/foo/github.com/acme/foo/files/bar/bar.go"},
+		{name: "subgroup", path: "group/sub/proj/gitlab.com/group/sub/proj/files/foo.go", repository: "group/sub/proj", wantRepository: "group/sub/proj", wantPkg: "gitlab.com/group/sub/proj", wantFile: "gitlab.com/group/sub/proj/foo.go", wantOk: true},
+		{name: "subgroup files", path: "group/sub/proj/gitlab.com/group/sub/proj/files", repository: "group/sub/proj", wantRepository: "group/sub/proj", wantPkg: "gitlab.com/group/sub/proj", wantOk: true},
+		{name: "other repository", path: "group/sub/proj/gitlab.com/group/sub/proj/files", repository: "group/other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var repository, pkg, file, ok = filesRoute(tt.path, tt.repository)
+			if repository != tt.wantRepository || pkg != tt.wantPkg || file != tt.wantFile || ok != tt.wantOk {
+				t.Errorf("filesRoute() = %s, %s, %s, %v; want %s, %s, %s, %v", repository, pkg, file, ok, tt.wantRepository, tt.wantPkg, tt.wantFile, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestServer_FilesDashboard(t *testing.T) {
+	var recs = []goqa.Record{{
+		Repository: "group/sub/proj",
+		Ref:        "master",
+		Commit:     "c1",
+		Time:       "2021-01-01T00:00:00Z",
+		Files:      []goqa.FileCoverage{{Pkg: "gitlab.com/group/sub/proj", File: "gitlab.com/group/sub/proj/foo.go", Statements: 2, Covered: 1}},
+	}}
+
+	// the requests of the dashboard for the files of a package and the detail of one
+	for _, call := range []string{
+		"api(`/${state.repository}/${pkg}/files`, {repository: state.repository, ref: state.ref})",
+		"api(`/${state.repository}/${state.pkg}/files/${file.slice(state.pkg.length + 1)}`, {repository: state.repository, ref: state.ref})",
+	} {
+		if !strings.Contains(string(goqa.AssetIndexHtml), call) {
+			t.Fatalf("dashboard does not request files with %s", call)
+		}
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string // in the body
+	}{
+		{
+			name: "files",
+			path: "/api/group/sub/proj/gitlab.com/group/sub/proj/files?repository=group%2Fsub%2Fproj&ref=master",
+			want: `"files":[{"pkg":"gitlab.com/group/sub/proj","file":"gitlab.com/group/sub/proj/foo.go"`,
+		},
+		{
+			name: "file",
+			path: "/api/group/sub/proj/gitlab.com/group/sub/proj/files/foo.go?repository=group%2Fsub%2Fproj&ref=master",
+			want: `"file":"gitlab.com/group/sub/proj/foo.go"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				s = &Server{Cache: memory.New(), Repo: fakerepo{recs: recs}, Prefix: "/api/"}
+				w = httptest.NewRecorder()
+				r = httptest.NewRequest(http.MethodGet, tt.path, nil)
+			)
+
+			s.Get(w, r) // as routed
+
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("%s = %d %s; want %s", tt.path, w.Code, w.Body.String(), tt.want)
+			}
+		})
+	}
+}
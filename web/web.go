@@ -3,9 +3,12 @@ package web
 import (
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"hash"
 	"io"
 	"net/http"
 	"strings"
@@ -22,6 +25,9 @@ const (
 
 	// ContentTypeEventStream used for SSE
 	ContentTypeEventStream = "text/event-stream"
+
+	// ContentTypeSVG is the content type for SVG images
+	ContentTypeSVG = "image/svg+xml"
 )
 
 var (
@@ -36,6 +42,9 @@ var (
 
 	// ErrPayloadUnverified payload could not be verified wrt signature
 	ErrPayloadUnverified = errors.New("payload could not be verified")
+
+	// ErrSHA1Rejected payload is signed with sha1 only while it is not trusted anymore
+	ErrSHA1Rejected = errors.New("sha1 signatures are rejected")
 )
 
 // Send data to the browser
@@ -69,27 +78,57 @@ func JsonError(w http.ResponseWriter, status int, err error) {
 	Print(w, status, ContentTypeJSON, []byte(`{"error":"`+m+`"}`))
 }
 
-// VerifyBody payload
+// VerifyBody payload signed with HMAC-SHA1 as in X-Hub-Signature
 func VerifyBody(b []byte, sig, key string) error {
-	if len(sig) != 45 || !strings.HasPrefix(sig, "sha1=") {
+	return VerifySignature(b, sig, false, key)
+}
+
+// VerifySignature of a payload as in X-Hub-Signature-256 (sha256=) or X-Hub-Signature (sha1=), made with any of keys
+// so that they can be rotated; sha1 signatures are refused when noSHA1
+func VerifySignature(b []byte, sig string, noSHA1 bool, keys ...string) error {
+	switch {
+	case strings.HasPrefix(sig, "sha256="):
+		return verifyHMAC(b, sig[7:], sha256.New, keys)
+	case strings.HasPrefix(sig, "sha1=") && noSHA1:
+		return ErrSHA1Rejected
+	case strings.HasPrefix(sig, "sha1="):
+		return verifyHMAC(b, sig[5:], sha1.New, keys)
+	default:
 		return ErrPayloadUnverified
 	}
+}
 
-	var (
-		got    = make([]byte, 20)
-		_, err = hex.Decode(got, []byte(sig[5:]))
-	)
+// VerifyBodySHA256 payload against the hex encoded HMAC-SHA256 of the body e.g. X-Gitea-Signature
+func VerifyBodySHA256(b []byte, sig, key string) error {
+	return verifyHMAC(b, sig, sha256.New, []string{key})
+}
 
-	if err != nil {
+// verifyHMAC encoded in hex against that of the body by any of keys; empty keys are never valid
+func verifyHMAC(b []byte, encoded string, fn func() hash.Hash, keys []string) error {
+	var got, err = hex.DecodeString(encoded)
+	if err != nil || len(got) != fn().Size() {
 		return ErrPayloadUnverified
 	}
 
-	var h = hmac.New(sha1.New, []byte(key))
-	h.Write(b)
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		var h = hmac.New(fn, []byte(key))
+		h.Write(b)
 
-	var want = h.Sum(nil)
+		if hmac.Equal(got, h.Sum(nil)) {
+			return nil
+		}
+	}
+
+	return ErrPayloadUnverified
+}
 
-	if !hmac.Equal(got, want) {
+// VerifyToken sent as is e.g. X-Gitlab-Token; an empty token is never valid
+func VerifyToken(got, want string) error {
+	if want == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
 		return ErrPayloadUnverified
 	}
 
@@ -409,3 +409,131 @@ func TestVerifyBody(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyBodySHA256(t *testing.T) {
+	type args struct {
+		b   []byte
+		sig string
+		key string
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want error
+	}{
+		{
+			name: "good",
+			args: args{
+				b:   []byte(`The quick brown fox jumps over the lazy dog`),
+				sig: "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+				key: "key",
+			},
+			want: nil,
+		},
+		{
+			name: "sha1",
+			args: args{
+				b:   []byte(`The quick brown fox jumps over the lazy dog`),
+				sig: "de7c9b85b8b78aa6bc8a7a36f70a90701c9db4d9",
+				key: "key",
+			},
+			want: ErrPayloadUnverified,
+		},
+		{
+			name: "not hex",
+			args: args{
+				b:   []byte(`The quick brown fox jumps over the lazy dog`),
+				sig: "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3czz",
+				key: "key",
+			},
+			want: ErrPayloadUnverified,
+		},
+		{
+			name: "bad value",
+			args: args{
+				b:   []byte(`The quick brown fox jumps over the lazy dog`),
+				sig: "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd0",
+				key: "key",
+			},
+			want: ErrPayloadUnverified,
+		},
+		{
+			name: "no key",
+			args: args{
+				b:   []byte(`The quick brown fox jumps over the lazy dog`),
+				sig: "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+			},
+			want: ErrPayloadUnverified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyBodySHA256(tt.args.b, tt.args.sig, tt.args.key); err != tt.want {
+				t.Errorf("VerifyBodySHA256() error = %v, wantErr %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+		err  error
+	}{
+		{name: "good", got: "t0ken", want: "t0ken"},
+		{name: "bad", got: "t0kem", want: "t0ken", err: ErrPayloadUnverified},
+		{name: "missing", got: "", want: "t0ken", err: ErrPayloadUnverified},
+		{name: "not configured", got: "", want: "", err: ErrPayloadUnverified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyToken(tt.got, tt.want); err != tt.err {
+				t.Errorf("VerifyToken() error = %v, wantErr %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	const (
+		sha1Sig   = "sha1=de7c9b85b8b78aa6bc8a7a36f70a90701c9db4d9"
+		sha256Sig = "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+	)
+
+	type args struct {
+		sig    string
+		noSHA1 bool
+		keys   []string
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want error
+	}{
+		{name: "sha256", args: args{sig: sha256Sig, keys: []string{"key"}}},
+		{name: "sha256 with sha1 rejected", args: args{sig: sha256Sig, noSHA1: true, keys: []string{"key"}}},
+		{name: "sha1", args: args{sig: sha1Sig, keys: []string{"key"}}},
+		{name: "sha1 rejected", args: args{sig: sha1Sig, noSHA1: true, keys: []string{"key"}}, want: ErrSHA1Rejected},
+		{name: "rotated key", args: args{sig: sha256Sig, keys: []string{"new", "key"}}},
+		{name: "unknown key", args: args{sig: sha256Sig, keys: []string{"new", "old"}}, want: ErrPayloadUnverified},
+		{name: "no keys", args: args{sig: sha256Sig}, want: ErrPayloadUnverified},
+		{name: "empty key", args: args{sig: "sha256=" + strings.Repeat("0", 64), keys: []string{""}}, want: ErrPayloadUnverified},
+		{name: "sha1 digest as sha256", args: args{sig: "sha256=de7c9b85b8b78aa6bc8a7a36f70a90701c9db4d9", keys: []string{"key"}}, want: ErrPayloadUnverified},
+		{name: "unknown algorithm", args: args{sig: "md5=80070713463e7749b90c2dc24911e275", keys: []string{"key"}}, want: ErrPayloadUnverified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b = []byte(`The quick brown fox jumps over the lazy dog`)
+			if err := VerifySignature(b, tt.args.sig, tt.args.noSHA1, tt.args.keys...); err != tt.want {
+				t.Errorf("VerifySignature() error = %v, wantErr %v", err, tt.want)
+			}
+		})
+	}
+}